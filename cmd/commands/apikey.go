@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/exiaohu/go-demo/config"
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/internal/repository"
+	"github.com/exiaohu/go-demo/internal/service"
+	"github.com/exiaohu/go-demo/pkg/database"
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+func newAPIKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage API keys",
+	}
+	for _, sub := range []*cobra.Command{newAPIKeyCreateCmd(), newAPIKeyListCmd(), newAPIKeyRevokeCmd()} {
+		// 业务错误无需打印用法，错误信息由 Execute 统一输出
+		sub.SilenceUsage = true
+		sub.SilenceErrors = true
+		cmd.AddCommand(sub)
+	}
+	return cmd
+}
+
+func newAPIKeyCreateCmd() *cobra.Command {
+	var (
+		name   string
+		scopes []string
+	)
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key and print it once",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return withAPIKeyService(func(ctx context.Context, keys *service.APIKeyService) error {
+				plaintext, key, err := keys.Create(ctx, name, scopes)
+				if err != nil {
+					return err
+				}
+				out := cmd.OutOrStdout()
+				fmt.Fprintf(out, "ID:     %d\n", key.ID)
+				fmt.Fprintf(out, "Name:   %s\n", key.Name)
+				fmt.Fprintf(out, "Scopes: %s\n", key.Scopes)
+				fmt.Fprintf(out, "Key:    %s\n", plaintext)
+				fmt.Fprintln(out, "Store this key now, it cannot be shown again.")
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "human readable name of the key")
+	cmd.Flags().StringSliceVar(&scopes, "scopes", []string{auth.ScopeCalculate},
+		"comma separated scopes ("+strings.Join(auth.Scopes, ", ")+")")
+	_ = cmd.MarkFlagRequired("name")
+	return cmd
+}
+
+func newAPIKeyListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return withAPIKeyService(func(ctx context.Context, keys *service.APIKeyService) error {
+				list, err := keys.List(ctx)
+				if err != nil {
+					return err
+				}
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tLAST USED\tREVOKED")
+				for _, key := range list {
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+						key.ID, key.Name, key.Prefix, key.Scopes,
+						key.CreatedAt.Format(time.RFC3339), formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+				}
+				return w.Flush()
+			})
+		},
+	}
+}
+
+func newAPIKeyRevokeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid id %q: %w", args[0], err)
+			}
+			return withAPIKeyService(func(ctx context.Context, keys *service.APIKeyService) error {
+				if err := keys.Revoke(ctx, uint(id)); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "API key %d revoked\n", id)
+				return nil
+			})
+		},
+	}
+}
+
+// withAPIKeyService 只打开数据库并执行 fn，结束后关闭连接
+// 不组装完整应用，避免 Redis、JWKS、IP 过滤等与密钥管理无关的配置影响离线操作
+func withAPIKeyService(fn func(ctx context.Context, keys *service.APIKeyService) error) error {
+	cfg, err := loadConfigHelper()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	switch cfg.Storage {
+	case config.StorageSQLite, "":
+	case config.StorageMemory:
+		return errors.New("api keys cannot be managed with memory storage: keys would be lost on exit")
+	default:
+		return fmt.Errorf("unsupported storage: %q", cfg.Storage)
+	}
+
+	db, err := database.New(cfg.Database)
+	if err != nil {
+		return err
+	}
+	if err := database.AutoMigrate(db, &model.APIKey{}); err != nil {
+		_ = database.Close(db)
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	keys := service.NewAPIKeyService(repository.NewAPIKeyRepository(db))
+	return errors.Join(fn(context.Background(), keys), database.Close(db))
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
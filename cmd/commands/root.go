@@ -23,7 +23,8 @@ var (
 func Execute(gitCommit, buildTime string) {
 	// 注入版本信息
 	rootCmd.AddCommand(newVersionCmd(gitCommit, buildTime))
-	rootCmd.AddCommand(newServerCmd())
+	rootCmd.AddCommand(newServerCmd(gitCommit, buildTime))
+	rootCmd.AddCommand(newAPIKeyCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
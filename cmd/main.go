@@ -19,6 +19,15 @@ import (
 // @host localhost:8080
 // @BasePath /
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT: "Bearer <token>"
+
 var (
 	// 编译时注入
 	GitCommit = "unknown"
@@ -2,6 +2,8 @@ package config
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -9,83 +11,520 @@ import (
 // Config 应用程序配置
 
 type Config struct {
-	AppName string `json:"app_name" yaml:"app_name"`
-	Version string `json:"version"  yaml:"version"`
-	Port    int    `json:"port"     yaml:"port"`
-	Debug   bool   `json:"debug"    yaml:"debug"`
+	AppName string `json:"app_name" mapstructure:"app_name" yaml:"app_name"`
+	Version string `json:"version"  mapstructure:"version"  yaml:"version"`
+	Port    int    `json:"port"     mapstructure:"port"     yaml:"port"`
+	Debug   bool   `json:"debug"    mapstructure:"debug"    yaml:"debug"`
+	// 存储后端：sqlite（默认）或 memory（仅内存，不持久化）
+	Storage string `json:"storage" mapstructure:"storage" yaml:"storage"`
+	// 可信反向代理的 CIDR 或 IP，只有来自这些地址的请求才读取 ClientIPHeader 获取客户端 IP
+	TrustedProxies []string `json:"trusted_proxies" mapstructure:"trusted_proxies" yaml:"trusted_proxies"`
+	// 可信代理设置的转发头：x-forwarded-for（默认）、forwarded 或 x-real-ip，只读取这一种
+	ClientIPHeader string `json:"client_ip_header" mapstructure:"client_ip_header" yaml:"client_ip_header"`
 	// 数据库配置
-	Database struct {
-		Host         string `json:"host"           yaml:"host"`
-		Port         int    `json:"port"           yaml:"port"`
-		Name         string `json:"name"           yaml:"name"`
-		User         string `json:"user"           yaml:"user"`
-		Password     string `json:"password"       yaml:"password"`
-		MaxIdleConns int    `json:"max_idle_conns" yaml:"max_idle_conns"`
-		MaxOpenConns int    `json:"max_open_conns" yaml:"max_open_conns"`
-		MaxLifeTime  int    `json:"max_life_time"  yaml:"max_life_time"`
-	} `json:"database" yaml:"database"`
+	Database DatabaseConfig `json:"database" mapstructure:"database" yaml:"database"`
+	// IP 黑白名单
+	IPFilter IPFilterConfig `json:"ip_filter" mapstructure:"ip_filter" yaml:"ip_filter"`
+	// 自适应并发限制（过载保护）
+	LoadShed LoadShedConfig `json:"load_shed" mapstructure:"load_shed" yaml:"load_shed"`
 	// 限流配置
-	RateLimit struct {
-		Enabled bool    `json:"enabled" yaml:"enabled"`
-		RPS     float64 `json:"rps"     yaml:"rps"`
-		Burst   int     `json:"burst"   yaml:"burst"`
-	} `json:"rate_limit" yaml:"rate_limit"`
+	RateLimit RateLimitConfig `json:"rate_limit" mapstructure:"rate_limit" yaml:"rate_limit"`
+	// 链路追踪配置
+	Tracing TracingConfig `json:"tracing" mapstructure:"tracing" yaml:"tracing"`
+	// HTTP 指标配置
+	Metrics MetricsConfig `json:"metrics" mapstructure:"metrics" yaml:"metrics"`
+	// SLO 配置
+	SLO SLOConfig `json:"slo" mapstructure:"slo" yaml:"slo"`
+	// 响应压缩配置
+	Compression CompressionConfig `json:"compression" mapstructure:"compression" yaml:"compression"`
+	// 请求体解压配置
+	RequestDecompression RequestDecompressionConfig `json:"request_decompression" mapstructure:"request_decompression" yaml:"request_decompression"`
+	// 计算结果缓存配置
+	Cache CacheConfig `json:"cache" mapstructure:"cache" yaml:"cache"`
+	// 幂等键配置
+	Idempotency IdempotencyConfig `json:"idempotency" mapstructure:"idempotency" yaml:"idempotency"`
+	// 认证配置
+	Auth AuthConfig `json:"auth" mapstructure:"auth" yaml:"auth"`
+	// 基于角色的访问控制
+	RBAC RBACConfig `json:"rbac" mapstructure:"rbac" yaml:"rbac"`
+	// 调用方配额
+	Quota QuotaConfig `json:"quota" mapstructure:"quota" yaml:"quota"`
 }
 
-// C 全局配置实例
-var C *Config
+// 存储后端类型
+const (
+	StorageSQLite = "sqlite"
+	StorageMemory = "memory"
+)
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	Host         string `json:"host"           mapstructure:"host"           yaml:"host"`
+	Port         int    `json:"port"           mapstructure:"port"           yaml:"port"`
+	Name         string `json:"name"           mapstructure:"name"           yaml:"name"`
+	User         string `json:"user"           mapstructure:"user"           yaml:"user"`
+	Password     string `json:"password"       mapstructure:"password"       yaml:"password"`
+	MaxIdleConns int    `json:"max_idle_conns" mapstructure:"max_idle_conns" yaml:"max_idle_conns"`
+	MaxOpenConns int    `json:"max_open_conns" mapstructure:"max_open_conns" yaml:"max_open_conns"`
+	MaxLifeTime  int    `json:"max_life_time"  mapstructure:"max_life_time"  yaml:"max_life_time"`
+}
+
+// IPFilterConfig IP 黑白名单配置
+type IPFilterConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// 全局名单与路由组名单
+	IPFilterRules `mapstructure:",squash" yaml:",inline"`
+	// File 名单文件（YAML 或 JSON，结构同本节的 allow/deny/groups），与上述名单合并，修改后自动重新加载
+	File string `json:"file" mapstructure:"file" yaml:"file"`
+	// ReloadInterval 检查名单文件是否变化的间隔
+	ReloadInterval time.Duration `json:"reload_interval" mapstructure:"reload_interval" yaml:"reload_interval"`
+}
+
+// IPFilterRules CIDR 或 IP 黑白名单：命中黑名单即拒绝，配置了白名单时只放行其中的地址
+type IPFilterRules struct {
+	// Allow、Deny 对所有路由生效
+	Allow []string `json:"allow" mapstructure:"allow" yaml:"allow"`
+	Deny  []string `json:"deny"  mapstructure:"deny"  yaml:"deny"`
+	// Groups 按路由组追加的名单，请求按最具体的路由模式匹配
+	Groups []IPFilterGroup `json:"groups" mapstructure:"groups" yaml:"groups"`
+}
+
+// IPFilterGroup 一个路由组的黑白名单
+type IPFilterGroup struct {
+	// Pattern ServeMux 模式语法，例如 "/debug/"
+	Pattern string   `json:"pattern" mapstructure:"pattern" yaml:"pattern"`
+	Allow   []string `json:"allow"   mapstructure:"allow"   yaml:"allow"`
+	Deny    []string `json:"deny"    mapstructure:"deny"    yaml:"deny"`
+}
+
+// LoadShedConfig 自适应并发限制配置
+type LoadShedConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// Algorithm 上限调整算法：aimd（默认）或 gradient
+	Algorithm string `json:"algorithm" mapstructure:"algorithm" yaml:"algorithm"`
+	// InitialLimit、MinLimit、MaxLimit 初始、最小与最大并发上限
+	InitialLimit int `json:"initial_limit" mapstructure:"initial_limit" yaml:"initial_limit"`
+	MinLimit     int `json:"min_limit"     mapstructure:"min_limit"     yaml:"min_limit"`
+	MaxLimit     int `json:"max_limit"     mapstructure:"max_limit"     yaml:"max_limit"`
+	// Timeout aimd 的延迟阈值，超过视为过载
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout" yaml:"timeout"`
+	// Backoff aimd 过载时上限的收缩比例
+	Backoff float64 `json:"backoff" mapstructure:"backoff" yaml:"backoff"`
+	// Tolerance gradient 允许近期延迟超出长期延迟的倍数
+	Tolerance float64 `json:"tolerance" mapstructure:"tolerance" yaml:"tolerance"`
+	// RetryAfter 被拒绝请求的 Retry-After
+	RetryAfter time.Duration `json:"retry_after" mapstructure:"retry_after" yaml:"retry_after"`
+	// Exempt 不受并发限制的路由模式，优先保证健康检查与监控可用
+	Exempt []string `json:"exempt" mapstructure:"exempt" yaml:"exempt"`
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// 默认规则
+	RateLimitRule `mapstructure:",squash" yaml:",inline"`
+	// MaxClients 每条规则最多同时跟踪的 key 数，超出时淘汰最久未访问的
+	MaxClients int `json:"max_clients" mapstructure:"max_clients" yaml:"max_clients"`
+	// IdleTTL key 空闲多久后释放其限流状态
+	IdleTTL time.Duration `json:"idle_ttl" mapstructure:"idle_ttl" yaml:"idle_ttl"`
+	// Routes 按路由覆盖默认规则
+	Routes []RateLimitRoute `json:"routes" mapstructure:"routes" yaml:"routes"`
+	// Store 限流状态存储：memory（默认，各副本独立计数）或 redis（多副本共享额度）
+	Store string `json:"store" mapstructure:"store" yaml:"store"`
+	// Redis store 为 redis 时的连接配置
+	Redis RedisConfig `json:"redis" mapstructure:"redis" yaml:"redis"`
+	// FailOpen 限流状态存储不可用时放行请求；为 false 时返回 503
+	FailOpen bool `json:"fail_open" mapstructure:"fail_open" yaml:"fail_open"`
+}
+
+// 限流状态存储类型
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// RedisConfig Redis 连接配置
+type RedisConfig struct {
+	Addr     string `json:"addr"   mapstructure:"addr"     yaml:"addr"`
+	Password string `json:"-"      mapstructure:"password" yaml:"password"`
+	DB       int    `json:"db"     mapstructure:"db"       yaml:"db"`
+	Prefix   string `json:"prefix" mapstructure:"prefix"   yaml:"prefix"`
+	// Timeout 连接与读写超时，限流位于请求路径上，应当保持较短
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout" yaml:"timeout"`
+}
+
+// RateLimitRule 限流算法、参数与 key，零值字段在路由规则中继承默认规则
+type RateLimitRule struct {
+	// Algorithm 限流算法：token_bucket（默认）、sliding_log、sliding_window、gcra
+	Algorithm string  `json:"algorithm" mapstructure:"algorithm" yaml:"algorithm"`
+	RPS       float64 `json:"rps"       mapstructure:"rps"       yaml:"rps"`
+	Burst     int     `json:"burst"     mapstructure:"burst"     yaml:"burst"`
+	// Window 滑动窗口算法的窗口长度，窗口内最多允许 rps×window 个请求
+	Window time.Duration `json:"window" mapstructure:"window" yaml:"window"`
+	// Key 限流 key：ip（默认）、cidr、apikey、header、route
+	Key string `json:"key" mapstructure:"key" yaml:"key"`
+	// Header key 为 header 时使用的请求头
+	Header string `json:"header" mapstructure:"header" yaml:"header"`
+	// IPv4Prefix、IPv6Prefix key 为 cidr 时的网段前缀长度
+	IPv4Prefix int `json:"ipv4_prefix" mapstructure:"ipv4_prefix" yaml:"ipv4_prefix"`
+	IPv6Prefix int `json:"ipv6_prefix" mapstructure:"ipv6_prefix" yaml:"ipv6_prefix"`
+}
+
+// RateLimitRoute 单个路由的限流规则
+type RateLimitRoute struct {
+	// Pattern ServeMux 模式语法，可带方法，例如 "GET /api/v1/divide"
+	Pattern string `json:"pattern" mapstructure:"pattern" yaml:"pattern"`
+	// Unlimited 命中该路由的请求不限流
+	Unlimited     bool `json:"unlimited" mapstructure:"unlimited" yaml:"unlimited"`
+	RateLimitRule `mapstructure:",squash" yaml:",inline"`
+}
+
+// 链路追踪导出方式
+const (
+	TracingExporterNone     = "none"
+	TracingExporterStdout   = "stdout"
+	TracingExporterOTLPGRPC = "otlp-grpc"
+	TracingExporterOTLPHTTP = "otlp-http"
+	TracingExporterFile     = "file"
+)
+
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	// Exporter 导出方式：none（只生成 trace 上下文，不导出）、stdout、otlp-grpc、otlp-http 或 file
+	Exporter string `json:"exporter" mapstructure:"exporter" yaml:"exporter"`
+	// Endpoint OTLP 接收端地址，host:port 或完整 URL（如 https://collector:4318/v1/traces）
+	Endpoint string `json:"endpoint" mapstructure:"endpoint" yaml:"endpoint"`
+	// Insecure 使用 host:port 形式的地址时不启用 TLS
+	Insecure bool `json:"insecure" mapstructure:"insecure" yaml:"insecure"`
+	// Headers 随 OTLP 请求发送的头，例如认证信息
+	Headers map[string]string `json:"headers" mapstructure:"headers" yaml:"headers"`
+	// Timeout 单次导出的超时时间
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout" yaml:"timeout"`
+	// File file 导出方式写入的文件，每行一个 JSON 格式的 span
+	File string `json:"file" mapstructure:"file" yaml:"file"`
+	// SampleRatio 根 span 的采样比例，取值 [0, 1]；有上游 span 时沿用上游的采样决定
+	SampleRatio float64 `json:"sample_ratio" mapstructure:"sample_ratio" yaml:"sample_ratio"`
+	// ResourceAttributes 附加到所有 span 的资源属性，格式为 key=value，例如 deployment.environment=production
+	// 使用列表而不是映射，因为 viper 会把键中的点号当作层级分隔符
+	ResourceAttributes []string `json:"resource_attributes" mapstructure:"resource_attributes" yaml:"resource_attributes"`
+}
+
+// MetricsConfig HTTP 指标配置
+type MetricsConfig struct {
+	// DurationBuckets 请求耗时直方图的桶（秒）
+	DurationBuckets []float64 `json:"duration_buckets" mapstructure:"duration_buckets" yaml:"duration_buckets"`
+	// SizeBuckets 请求与响应大小直方图的桶（字节）
+	SizeBuckets []float64 `json:"size_buckets" mapstructure:"size_buckets" yaml:"size_buckets"`
+}
+
+// SLOConfig 服务等级目标配置，根据 HTTP 指标计算错误预算与 burn rate
+type SLOConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// Interval 采样请求指标的间隔
+	Interval time.Duration `json:"interval" mapstructure:"interval" yaml:"interval"`
+	// Period 错误预算的统计周期
+	Period time.Duration `json:"period" mapstructure:"period" yaml:"period"`
+	// Windows 计算 burn rate 的窗口
+	Windows []time.Duration `json:"windows" mapstructure:"windows" yaml:"windows"`
+	// Objectives 各组路由的目标
+	Objectives []SLOObjective `json:"objectives" mapstructure:"objectives" yaml:"objectives"`
+}
+
+// SLOObjective 一组路由的服务等级目标
+type SLOObjective struct {
+	Name string `json:"name" mapstructure:"name" yaml:"name"`
+	// Routes 路由模式，与 HTTP 指标的 route 标签一致，为空时计入全部请求
+	Routes []string `json:"routes" mapstructure:"routes" yaml:"routes"`
+	// Availability 可用性目标（状态码小于 500 的请求占比），0 表示不跟踪
+	Availability float64 `json:"availability" mapstructure:"availability" yaml:"availability"`
+	// Latency 延迟目标
+	Latency SLOLatency `json:"latency" mapstructure:"latency" yaml:"latency"`
+}
+
+// SLOLatency 延迟目标：耗时不超过 Threshold 的请求占比不低于 Target
+type SLOLatency struct {
+	// Threshold 必须是 metrics.duration_buckets 中的桶边界，0 表示不跟踪
+	Threshold time.Duration `json:"threshold" mapstructure:"threshold" yaml:"threshold"`
+	Target    float64       `json:"target"    mapstructure:"target"    yaml:"target"`
+}
+
+// CompressionConfig 响应压缩配置
+type CompressionConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// Encodings 启用的编码（br、zstd、gzip、deflate），按服务端偏好排序，客户端 q 值相同时靠前的优先
+	Encodings []string `json:"encodings" mapstructure:"encodings" yaml:"encodings"`
+	// MinSize 响应体小于该字节数时不压缩
+	MinSize int `json:"min_size" mapstructure:"min_size" yaml:"min_size"`
+	// ContentTypes 压缩的内容类型，以 "/" 结尾的条目按前缀匹配
+	ContentTypes []string `json:"content_types" mapstructure:"content_types" yaml:"content_types"`
+}
+
+// RequestDecompressionConfig 请求体解压配置
+type RequestDecompressionConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// Encodings 接受的请求体编码（gzip、deflate、zstd）
+	Encodings []string `json:"encodings" mapstructure:"encodings" yaml:"encodings"`
+	// MaxSize 解压后请求体的最大字节数，超出返回 413
+	MaxSize int64 `json:"max_size" mapstructure:"max_size" yaml:"max_size"`
+}
+
+// 缓存后端类型
+const (
+	CacheBackendMemory = "memory"
+)
+
+// CacheConfig 计算结果缓存配置
+type CacheConfig struct {
+	Enabled bool          `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	Backend string        `json:"backend" mapstructure:"backend" yaml:"backend"`
+	Size    int           `json:"size"    mapstructure:"size"    yaml:"size"`
+	TTL     time.Duration `json:"ttl"     mapstructure:"ttl"     yaml:"ttl"`
+}
+
+// IdempotencyConfig 幂等键配置
+type IdempotencyConfig struct {
+	Enabled bool          `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	TTL     time.Duration `json:"ttl"     mapstructure:"ttl"     yaml:"ttl"`
+	// 并发的重复请求等待首个请求完成的最长时间，为 0 时立即返回 409
+	WaitTimeout time.Duration `json:"wait_timeout" mapstructure:"wait_timeout" yaml:"wait_timeout"`
+}
+
+// AuthConfig 认证配置
+type AuthConfig struct {
+	// 启用后计算接口需要 calculate scope，/history 需要 read-history scope
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// JWT Bearer Token 认证
+	JWT JWTConfig `json:"jwt" mapstructure:"jwt" yaml:"jwt"`
+}
+
+// JWTConfig JWT Bearer Token 认证配置
+type JWTConfig struct {
+	Enabled  bool   `json:"enabled"  mapstructure:"enabled"  yaml:"enabled"`
+	Issuer   string `json:"issuer"   mapstructure:"issuer"   yaml:"issuer"`
+	Audience string `json:"audience" mapstructure:"audience" yaml:"audience"`
+	// HS256 共享密钥
+	Secret string `json:"-" mapstructure:"secret" yaml:"secret"`
+	// RS256/ES256 公钥来源：JWKS 文件路径或 URL
+	JWKS        string        `json:"jwks"         mapstructure:"jwks"         yaml:"jwks"`
+	JWKSRefresh time.Duration `json:"jwks_refresh" mapstructure:"jwks_refresh" yaml:"jwks_refresh"`
+	// 校验 exp/nbf/iat 时容忍的时钟偏差
+	ClockSkew time.Duration `json:"clock_skew" mapstructure:"clock_skew" yaml:"clock_skew"`
+	// 映射为角色的声明名称
+	RolesClaim string `json:"roles_claim" mapstructure:"roles_claim" yaml:"roles_claim"`
+}
+
+// RBACConfig 基于角色的访问控制配置
+// 启用后由策略统一授权，取代各路由固定的 scope 校验；API 密钥的 scope 作为角色参与评估
+type RBACConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// 角色 → 权限列表，"*" 表示全部权限
+	Roles map[string][]string `json:"roles" mapstructure:"roles" yaml:"roles"`
+	// 权限 → 路由模式列表（ServeMux 模式语法，可带方法，例如 "DELETE /api/v1/history/{id}"）
+	Permissions map[string][]string `json:"permissions" mapstructure:"permissions" yaml:"permissions"`
+	// 无需认证即可访问的路由模式
+	Public []string `json:"public" mapstructure:"public" yaml:"public"`
+	// 未被任何模式覆盖的路由与方法的处理方式：deny（默认）或 allow
+	Default string `json:"default" mapstructure:"default" yaml:"default"`
+}
+
+// RBAC 未覆盖路由的处理方式
+const (
+	RBACDefaultDeny  = "deny"
+	RBACDefaultAllow = "allow"
+)
+
+// QuotaConfig 调用方配额配置
+// 已认证的调用方按其 ID（如 apikey:1、jwt:alice）计数，匿名请求按客户端 IP 计数
+type QuotaConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" yaml:"enabled"`
+	// 默认配额，0 表示不限制
+	QuotaLimits `mapstructure:",squash" yaml:",inline"`
+	// 按调用方 ID 覆盖默认配额
+	Overrides []QuotaOverride `json:"overrides" mapstructure:"overrides" yaml:"overrides"`
+	// 清理已结束窗口用量记录的间隔
+	PruneInterval time.Duration `json:"prune_interval" mapstructure:"prune_interval" yaml:"prune_interval"`
+}
+
+// QuotaOverride 单个调用方的配额
+// 使用列表而非以 ID 为键的映射，因为 viper 会把键转为小写并按 "." 拆分
+type QuotaOverride struct {
+	Subject     string `json:"subject" mapstructure:"subject" yaml:"subject"`
+	QuotaLimits `mapstructure:",squash" yaml:",inline"`
+}
+
+// QuotaLimits 每日/每月的计算次数上限
+type QuotaLimits struct {
+	Daily   int64 `json:"daily"   mapstructure:"daily"   yaml:"daily"`
+	Monthly int64 `json:"monthly" mapstructure:"monthly" yaml:"monthly"`
+}
 
 // LoadConfig 加载配置文件
+// 每次调用都使用独立的 viper 实例，不会修改任何包级状态
 func LoadConfig(configPath string) (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(configPath)
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("../")
-	viper.AddConfigPath("../../")
+	v := viper.New()
+	v.SetConfigName("config")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(configPath)
+	v.AddConfigPath(".")
+	v.AddConfigPath("../")
+	v.AddConfigPath("../../")
 
 	// 设置默认值
-	viper.SetDefault("app_name", "playground")
-	viper.SetDefault("version", "1.0.0")
-	viper.SetDefault("port", 8080)
-	viper.SetDefault("debug", false)
-	viper.SetDefault("database.host", "localhost")
-	viper.SetDefault("database.port", 5432)
-	viper.SetDefault("database.name", "playground.db")
-	viper.SetDefault("database.user", "postgres")
-	viper.SetDefault("database.password", "password")
-	viper.SetDefault("database.max_idle_conns", 10)
-	viper.SetDefault("database.max_open_conns", 100)
-	viper.SetDefault("database.max_life_time", 3600)
-
-	// 限流默认值
-	viper.SetDefault("rate_limit.enabled", true)
-	viper.SetDefault("rate_limit.rps", 100.0)
-	viper.SetDefault("rate_limit.burst", 20)
+	setDefaults(v)
 
 	// 设置环境变量前缀
-	viper.SetEnvPrefix("APP")
-	viper.AutomaticEnv()
+	v.SetEnvPrefix("APP")
+	// 嵌套配置项通过下划线映射，例如 APP_RATE_LIMIT_RPS
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
 
 	// 读取配置文件
-	if err := viper.ReadInConfig(); err != nil {
-		// 如果配置文件不存在，返回默认配置
+	if err := v.ReadInConfig(); err != nil {
+		// 如果配置文件不存在，使用默认配置
 		var configFileNotFoundError viper.ConfigFileNotFoundError
-		if errors.As(err, &configFileNotFoundError) {
-			C = &Config{}
-			if unmarshalErr := viper.Unmarshal(C); unmarshalErr != nil {
-				return nil, unmarshalErr
-			}
-			return C, nil
+		if !errors.As(err, &configFileNotFoundError) {
+			return nil, err
 		}
-		return nil, err
 	}
 
 	// 解析配置到结构体
-	C = &Config{}
-	if err := viper.Unmarshal(C); err != nil {
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg); err != nil {
 		return nil, err
 	}
 
-	return C, nil
+	return cfg, nil
+}
+
+// Default 返回仅包含默认值的配置，不读取配置文件和环境变量
+func Default() *Config {
+	v := viper.New()
+	setDefaults(v)
+
+	cfg := &Config{}
+	// 默认值均为基础类型，解析不会失败
+	_ = v.Unmarshal(cfg)
+	return cfg
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("app_name", "playground")
+	v.SetDefault("version", "1.0.0")
+	v.SetDefault("port", 8080)
+	v.SetDefault("debug", false)
+	v.SetDefault("storage", StorageSQLite)
+	v.SetDefault("trusted_proxies", []string{})
+	v.SetDefault("client_ip_header", "x-forwarded-for")
+	v.SetDefault("database.host", "localhost")
+	v.SetDefault("database.port", 5432)
+	v.SetDefault("database.name", "playground.db")
+	v.SetDefault("database.user", "postgres")
+	v.SetDefault("database.password", "password")
+	v.SetDefault("database.max_idle_conns", 10)
+	v.SetDefault("database.max_open_conns", 100)
+	v.SetDefault("database.max_life_time", 3600)
+
+	// IP 黑白名单默认值
+	v.SetDefault("ip_filter.enabled", false)
+	v.SetDefault("ip_filter.allow", []string{})
+	v.SetDefault("ip_filter.deny", []string{})
+	v.SetDefault("ip_filter.file", "")
+	v.SetDefault("ip_filter.reload_interval", 10*time.Second)
+
+	// 自适应并发限制默认值
+	v.SetDefault("load_shed.enabled", false)
+	v.SetDefault("load_shed.algorithm", "aimd")
+	v.SetDefault("load_shed.initial_limit", 100)
+	v.SetDefault("load_shed.min_limit", 10)
+	v.SetDefault("load_shed.max_limit", 1000)
+	v.SetDefault("load_shed.timeout", time.Second)
+	v.SetDefault("load_shed.backoff", 0.9)
+	v.SetDefault("load_shed.tolerance", 1.5)
+	v.SetDefault("load_shed.retry_after", time.Second)
+	v.SetDefault("load_shed.exempt", []string{"/healthz", "/metrics"})
+
+	// 链路追踪默认值
+	v.SetDefault("tracing.exporter", TracingExporterNone)
+	v.SetDefault("tracing.timeout", 10*time.Second)
+	v.SetDefault("tracing.file", "traces.jsonl")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	// HTTP 指标默认值
+	v.SetDefault("metrics.duration_buckets", []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+	v.SetDefault("metrics.size_buckets", []float64{100, 1000, 10000, 100000, 1e6, 1e7, 1e8})
+
+	// SLO 默认值
+	v.SetDefault("slo.enabled", false)
+	v.SetDefault("slo.interval", time.Minute)
+	v.SetDefault("slo.period", 30*24*time.Hour)
+	v.SetDefault("slo.windows", []time.Duration{
+		5 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 6 * time.Hour, 24 * time.Hour, 72 * time.Hour,
+	})
+
+	// 响应压缩默认值
+	v.SetDefault("compression.enabled", true)
+	v.SetDefault("compression.encodings", []string{"br", "zstd", "gzip", "deflate"})
+	v.SetDefault("compression.min_size", 1024)
+	v.SetDefault("compression.content_types", []string{
+		"text/", "application/json", "application/problem+json", "application/javascript",
+		"application/xml", "application/x-ndjson", "image/svg+xml",
+	})
+
+	// 请求体解压默认值
+	v.SetDefault("request_decompression.enabled", true)
+	v.SetDefault("request_decompression.encodings", []string{"gzip", "deflate", "zstd"})
+	v.SetDefault("request_decompression.max_size", 1<<20)
+
+	// 限流默认值
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.rps", 100.0)
+	v.SetDefault("rate_limit.burst", 20)
+	v.SetDefault("rate_limit.algorithm", "token_bucket")
+	v.SetDefault("rate_limit.window", time.Second)
+	v.SetDefault("rate_limit.key", "ip")
+	v.SetDefault("rate_limit.header", "")
+	v.SetDefault("rate_limit.ipv4_prefix", 24)
+	v.SetDefault("rate_limit.ipv6_prefix", 64)
+	v.SetDefault("rate_limit.store", RateLimitStoreMemory)
+	v.SetDefault("rate_limit.fail_open", true)
+	v.SetDefault("rate_limit.redis.addr", "localhost:6379")
+	v.SetDefault("rate_limit.redis.password", "")
+	v.SetDefault("rate_limit.redis.db", 0)
+	v.SetDefault("rate_limit.redis.prefix", "playground:ratelimit:")
+	v.SetDefault("rate_limit.redis.timeout", 100*time.Millisecond)
+	v.SetDefault("rate_limit.max_clients", 100000)
+	v.SetDefault("rate_limit.idle_ttl", 10*time.Minute)
+
+	// 缓存默认值
+	v.SetDefault("cache.enabled", true)
+	v.SetDefault("cache.backend", CacheBackendMemory)
+	v.SetDefault("cache.size", 10000)
+	v.SetDefault("cache.ttl", 10*time.Minute)
+
+	// 幂等键默认值
+	v.SetDefault("idempotency.enabled", true)
+	v.SetDefault("idempotency.ttl", 24*time.Hour)
+	v.SetDefault("idempotency.wait_timeout", 5*time.Second)
+
+	// 认证默认关闭，保持现有客户端可用
+	v.SetDefault("auth.enabled", false)
+	v.SetDefault("auth.jwt.enabled", false)
+	// 空字符串默认值让 APP_AUTH_JWT_SECRET 等环境变量能够覆盖
+	v.SetDefault("auth.jwt.issuer", "")
+	v.SetDefault("auth.jwt.audience", "")
+	v.SetDefault("auth.jwt.secret", "")
+	v.SetDefault("auth.jwt.jwks", "")
+	v.SetDefault("auth.jwt.jwks_refresh", time.Hour)
+	v.SetDefault("auth.jwt.clock_skew", 30*time.Second)
+	v.SetDefault("auth.jwt.roles_claim", "roles")
+
+	// RBAC 默认关闭，策略在配置文件中声明
+	v.SetDefault("rbac.enabled", false)
+	v.SetDefault("rbac.default", RBACDefaultDeny)
+
+	// 配额默认值
+	v.SetDefault("quota.enabled", false)
+	v.SetDefault("quota.daily", 10000)
+	v.SetDefault("quota.monthly", 0)
+	v.SetDefault("quota.prune_interval", time.Hour)
 }
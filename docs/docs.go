@@ -26,6 +26,14 @@ const docTemplate = `{
     "paths": {
         "/add": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "get sum of two integers",
                 "consumes": [
                     "application/json"
@@ -51,6 +59,18 @@ const docTemplate = `{
                         "name": "b",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "no-cache to bypass the result cache",
+                        "name": "Cache-Control",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retries with the same key replay the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -75,8 +95,268 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/v1/batch": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "run several operations atomically; history is recorded for all of them or none",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "math"
+                ],
+                "summary": "Batch calculation",
+                "parameters": [
+                    {
+                        "description": "Operations (add, subtract, multiply, divide)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "no-cache to bypass the result cache",
+                        "name": "Cache-Control",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retries with the same key replay the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "gzip, deflate or zstd compressed request body",
+                        "name": "Content-Encoding",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Results",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/service.BatchResult"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "405": {
+                        "description": "Method Not Allowed",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Encoding",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/history/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "soft-delete a history record by id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "Delete a calculation history record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "History ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/usage": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "show the caller's consumption for each quota window; anonymous callers are identified by client IP",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "usage"
+                ],
+                "summary": "Get quota usage",
+                "responses": {
+                    "200": {
+                        "description": "Usage",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handler.UsageResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/slo": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "show remaining error budget and multi-window burn rates for each service level objective",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Get SLO status",
+                "responses": {
+                    "200": {
+                        "description": "SLO report",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/slo.Report"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
         "/divide": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "get quotient of two integers",
                 "consumes": [
                     "application/json"
@@ -102,6 +382,18 @@ const docTemplate = `{
                         "name": "b",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "no-cache to bypass the result cache",
+                        "name": "Cache-Control",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retries with the same key replay the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -128,6 +420,14 @@ const docTemplate = `{
         },
         "/history": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "get latest calculation history",
                 "consumes": [
                     "application/json"
@@ -180,6 +480,14 @@ const docTemplate = `{
         },
         "/multiply": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "get product of two integers",
                 "consumes": [
                     "application/json"
@@ -205,6 +513,18 @@ const docTemplate = `{
                         "name": "b",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "no-cache to bypass the result cache",
+                        "name": "Cache-Control",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retries with the same key replay the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -231,6 +551,14 @@ const docTemplate = `{
         },
         "/subtract": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "get difference of two integers",
                 "consumes": [
                     "application/json"
@@ -256,6 +584,18 @@ const docTemplate = `{
                         "name": "b",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "no-cache to bypass the result cache",
+                        "name": "Cache-Control",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retries with the same key replay the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -282,6 +622,31 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "handler.BatchRequest": {
+            "type": "object",
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.BatchOperation"
+                    }
+                }
+            }
+        },
+        "handler.UsageResponse": {
+            "type": "object",
+            "properties": {
+                "quotas": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quota.Status"
+                    }
+                },
+                "subject": {
+                    "type": "string"
+                }
+            }
+        },
         "model.CalculationHistory": {
             "type": "object",
             "properties": {
@@ -311,6 +676,40 @@ const docTemplate = `{
                 }
             }
         },
+        "quota.Period": {
+            "type": "string",
+            "enum": [
+                "day",
+                "month"
+            ],
+            "x-enum-varnames": [
+                "PeriodDay",
+                "PeriodMonth"
+            ]
+        },
+        "quota.Status": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "period": {
+                    "$ref": "#/definitions/quota.Period"
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "reset_at": {
+                    "type": "string"
+                },
+                "used": {
+                    "type": "integer"
+                },
+                "window_start": {
+                    "type": "string"
+                }
+            }
+        },
         "response.Response": {
             "type": "object",
             "properties": {
@@ -330,6 +729,136 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "service.BatchOperation": {
+            "type": "object",
+            "properties": {
+                "a": {
+                    "type": "integer"
+                },
+                "b": {
+                    "type": "integer"
+                },
+                "operation": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.BatchResult": {
+            "type": "object",
+            "properties": {
+                "a": {
+                    "type": "integer"
+                },
+                "b": {
+                    "type": "integer"
+                },
+                "operation": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "integer"
+                }
+            }
+        },
+        "slo.ObjectiveReport": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "routes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "slis": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/slo.SLIReport"
+                    }
+                }
+            }
+        },
+        "slo.Report": {
+            "type": "object",
+            "properties": {
+                "objectives": {
+                    "description": "Objectives 各目标的状态",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/slo.ObjectiveReport"
+                    }
+                },
+                "period": {
+                    "description": "Period 错误预算的统计周期",
+                    "type": "string"
+                }
+            }
+        },
+        "slo.SLI": {
+            "type": "string",
+            "enum": [
+                "availability",
+                "latency"
+            ],
+            "x-enum-varnames": [
+                "SLIAvailability",
+                "SLILatency"
+            ]
+        },
+        "slo.SLIReport": {
+            "type": "object",
+            "properties": {
+                "burn_rates": {
+                    "description": "BurnRates 各窗口的错误预算消耗速率，1 表示恰好在统计周期结束时耗尽",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number",
+                        "format": "float64"
+                    }
+                },
+                "error_budget_remaining": {
+                    "description": "ErrorBudgetRemaining 剩余错误预算占比，预算耗尽后为负数",
+                    "type": "number"
+                },
+                "good": {
+                    "type": "number"
+                },
+                "objective": {
+                    "description": "Objective 目标占比",
+                    "type": "number"
+                },
+                "ratio": {
+                    "description": "Ratio 达标请求占比，没有请求时为 1",
+                    "type": "number"
+                },
+                "sli": {
+                    "$ref": "#/definitions/slo.SLI"
+                },
+                "threshold": {
+                    "description": "Threshold 延迟阈值，仅延迟指标",
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total、Good 统计周期内的请求数与达标请求数",
+                    "type": "number"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT: \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
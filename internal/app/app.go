@@ -0,0 +1,644 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/cors"
+	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/exiaohu/go-demo/config"
+	_ "github.com/exiaohu/go-demo/docs" // swagger docs
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/internal/cache"
+	"github.com/exiaohu/go-demo/internal/handler"
+	"github.com/exiaohu/go-demo/internal/ipfilter"
+	"github.com/exiaohu/go-demo/internal/loadshed"
+	"github.com/exiaohu/go-demo/internal/middleware"
+	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/internal/quota"
+	"github.com/exiaohu/go-demo/internal/ratelimit"
+	"github.com/exiaohu/go-demo/internal/repository"
+	"github.com/exiaohu/go-demo/internal/service"
+	"github.com/exiaohu/go-demo/internal/slo"
+	"github.com/exiaohu/go-demo/pkg/database"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+// App 应用程序容器
+// 通过构造函数显式组装配置、数据库、仓储、服务和中间件，
+// 不依赖任何包级全局状态，因此同一进程中可以创建多个互相隔离的实例
+type App struct {
+	Config      *config.Config
+	DB          *gorm.DB
+	HistoryRepo repository.HistoryRepository
+	UnitOfWork  repository.UnitOfWork
+	APIKeyRepo  repository.APIKeyRepository
+	QuotaRepo   repository.QuotaRepository
+	ResultCache cache.Cache
+	CalcService *service.StandardCalculatorService
+	APIKeys     *service.APIKeyService
+	Quotas      *quota.Service
+	RateLimits  *ratelimit.Rules
+
+	authenticators []auth.Authenticator
+	policy         *auth.Policy
+	rateLimitStore ratelimit.Store
+	clientIP       *ip.Resolver
+	ipFilter       *ipfilter.Filter
+	loadShed       *loadshed.Limiter
+	slo            *slo.Tracker
+	compress       func(http.Handler) http.Handler
+	decompress     func(http.Handler) http.Handler
+	handler        http.Handler
+	registerer     prometheus.Registerer
+	gatherer       prometheus.Gatherer
+}
+
+// Option 自定义 App 的依赖
+type Option func(*App)
+
+// WithRegistry 指定注册指标的 Registerer 与 /metrics、SLO 读取指标的 Gatherer，
+// 默认使用 prometheus.DefaultRegisterer 与 prometheus.DefaultGatherer
+func WithRegistry(reg prometheus.Registerer, gatherer prometheus.Gatherer) Option {
+	return func(a *App) {
+		a.registerer, a.gatherer = reg, gatherer
+	}
+}
+
+// New 根据配置创建应用实例
+// 任一步骤失败时通过 Close 释放已创建的资源
+func New(cfg *config.Config, opts ...Option) (_ *App, err error) {
+	a := &App{Config: cfg, registerer: prometheus.DefaultRegisterer, gatherer: prometheus.DefaultGatherer}
+	for _, opt := range opts {
+		opt(a)
+	}
+	defer func() {
+		if err != nil {
+			_ = a.Close()
+		}
+	}()
+
+	// 依赖注入
+	if err := a.initStorage(); err != nil {
+		return nil, err
+	}
+	resultCache, err := newResultCache(cfg.Cache, a.registerer)
+	if err != nil {
+		return nil, err
+	}
+	a.ResultCache = resultCache
+	a.CalcService = service.NewCalculatorService(a.HistoryRepo, a.UnitOfWork, a.ResultCache, service.NewMetrics(a.registerer))
+	a.APIKeys = service.NewAPIKeyService(a.APIKeyRepo)
+	if cfg.Quota.Enabled {
+		a.Quotas = newQuotaService(cfg.Quota, a.QuotaRepo, a.UnitOfWork)
+		a.Quotas.StartPruning(cfg.Quota.PruneInterval)
+	}
+	if err := a.initAuth(); err != nil {
+		return nil, err
+	}
+	if err := a.initRBAC(); err != nil {
+		return nil, err
+	}
+	if a.clientIP, err = ip.NewResolver(cfg.TrustedProxies, cfg.ClientIPHeader); err != nil {
+		return nil, err
+	}
+	if a.loadShed, err = newLoadShed(cfg.LoadShed, a.registerer); err != nil {
+		return nil, err
+	}
+	if a.compress, err = newCompress(cfg.Compression); err != nil {
+		return nil, err
+	}
+	if a.decompress, err = newDecompress(cfg.RequestDecompression); err != nil {
+		return nil, err
+	}
+	if err := a.initIPFilter(); err != nil {
+		return nil, err
+	}
+	if err := a.initRateLimit(); err != nil {
+		return nil, err
+	}
+	if err := a.initSLO(); err != nil {
+		return nil, err
+	}
+	a.handler = a.buildHandler(handler.NewHandler(a.CalcService))
+
+	return a, nil
+}
+
+// initStorage 根据配置选择存储后端并创建仓储
+func (a *App) initStorage() error {
+	switch a.Config.Storage {
+	case config.StorageMemory:
+		logger.Info("Using in-memory storage, data will not be persisted")
+		a.HistoryRepo = repository.NewMemoryHistoryRepository()
+		a.UnitOfWork = repository.NewMemoryUnitOfWork()
+		a.APIKeyRepo = repository.NewMemoryAPIKeyRepository()
+		a.QuotaRepo = repository.NewMemoryQuotaRepository()
+		return nil
+	case config.StorageSQLite, "":
+		db, err := database.New(a.Config.Database)
+		if err != nil {
+			return err
+		}
+		// 自动迁移
+		if err := database.AutoMigrate(db, &model.CalculationHistory{}, &model.APIKey{}, &model.QuotaUsage{}); err != nil {
+			_ = database.Close(db)
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+		a.DB = db
+		a.HistoryRepo = repository.NewHistoryRepository(db)
+		a.UnitOfWork = repository.NewUnitOfWork(db)
+		a.APIKeyRepo = repository.NewAPIKeyRepository(db)
+		a.QuotaRepo = repository.NewQuotaRepository(db)
+		return nil
+	default:
+		return fmt.Errorf("unsupported storage: %q", a.Config.Storage)
+	}
+}
+
+// newQuotaService 根据配置创建配额服务
+func newQuotaService(cfg config.QuotaConfig, repo repository.QuotaRepository, uow repository.UnitOfWork) *quota.Service {
+	overrides := make(map[string]quota.Limits, len(cfg.Overrides))
+	for _, o := range cfg.Overrides {
+		overrides[o.Subject] = quota.Limits{Daily: o.Daily, Monthly: o.Monthly}
+	}
+	return quota.NewService(repo, uow, quota.Limits{Daily: cfg.Daily, Monthly: cfg.Monthly}, overrides)
+}
+
+// newLoadShed 根据配置创建自适应并发限制器，未启用时返回 nil
+func newLoadShed(cfg config.LoadShedConfig, reg prometheus.Registerer) (*loadshed.Limiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	return loadshed.New(loadshed.Options{
+		Algorithm:    loadshed.Algorithm(cfg.Algorithm),
+		InitialLimit: cfg.InitialLimit,
+		MinLimit:     cfg.MinLimit,
+		MaxLimit:     cfg.MaxLimit,
+		Timeout:      cfg.Timeout,
+		Backoff:      cfg.Backoff,
+		Tolerance:    cfg.Tolerance,
+		RetryAfter:   cfg.RetryAfter,
+		Exempt:       cfg.Exempt,
+		Metrics:      loadshed.NewMetrics(reg),
+	})
+}
+
+// newCompress 根据配置创建响应压缩中间件，未启用时原样返回 handler
+func newCompress(cfg config.CompressionConfig) (func(http.Handler) http.Handler, error) {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }, nil
+	}
+	return middleware.Compress(middleware.CompressOptions{
+		Encodings:    cfg.Encodings,
+		MinSize:      cfg.MinSize,
+		ContentTypes: cfg.ContentTypes,
+	})
+}
+
+// newDecompress 根据配置创建请求体解压中间件，未启用时原样返回 handler
+func newDecompress(cfg config.RequestDecompressionConfig) (func(http.Handler) http.Handler, error) {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }, nil
+	}
+	return middleware.Decompress(middleware.DecompressOptions{
+		Encodings: cfg.Encodings,
+		MaxSize:   cfg.MaxSize,
+	})
+}
+
+// initIPFilter 根据配置创建 IP 黑白名单过滤器
+func (a *App) initIPFilter() error {
+	cfg := a.Config.IPFilter
+	if !cfg.Enabled {
+		return nil
+	}
+
+	rules := ipfilter.Rules{Allow: cfg.Allow, Deny: cfg.Deny}
+	for _, g := range cfg.Groups {
+		rules.Groups = append(rules.Groups, ipfilter.Group{Pattern: g.Pattern, Allow: g.Allow, Deny: g.Deny})
+	}
+	filter, err := ipfilter.New(rules, ipfilter.Options{File: cfg.File, ReloadInterval: cfg.ReloadInterval})
+	if err != nil {
+		return err
+	}
+	a.ipFilter = filter
+	return nil
+}
+
+// initSLO 根据配置创建 SLO 跟踪器，从 Gatherer 读取的 HTTP 指标计算错误预算
+func (a *App) initSLO() error {
+	cfg := a.Config.SLO
+	if !cfg.Enabled {
+		return nil
+	}
+
+	objectives := make([]slo.Objective, 0, len(cfg.Objectives))
+	for _, o := range cfg.Objectives {
+		objectives = append(objectives, slo.Objective{
+			Name:             o.Name,
+			Routes:           o.Routes,
+			Availability:     o.Availability,
+			LatencyThreshold: o.Latency.Threshold,
+			LatencyTarget:    o.Latency.Target,
+		})
+	}
+	tracker, err := slo.New(objectives, slo.Options{
+		Interval:        cfg.Interval,
+		Period:          cfg.Period,
+		Windows:         cfg.Windows,
+		DurationBuckets: a.Config.Metrics.DurationBuckets,
+		Gatherer:        a.gatherer,
+		Metrics:         slo.NewMetrics(a.registerer),
+	})
+	if err != nil {
+		return err
+	}
+	a.slo = tracker
+	return nil
+}
+
+// initRateLimit 根据配置创建限流状态存储与限流规则
+func (a *App) initRateLimit() error {
+	cfg := a.Config.RateLimit
+	if !cfg.Enabled {
+		return nil
+	}
+
+	store, err := newRateLimitStore(cfg)
+	if err != nil {
+		return err
+	}
+	rules, err := newRateLimitRules(cfg, store)
+	if err != nil {
+		_ = store.Close()
+		return err
+	}
+	a.rateLimitStore, a.RateLimits = store, rules
+	return nil
+}
+
+// newRateLimitStore 根据配置创建限流状态存储
+func newRateLimitStore(cfg config.RateLimitConfig) (ratelimit.Store, error) {
+	switch cfg.Store {
+	case config.RateLimitStoreMemory, "":
+		return ratelimit.NewMemoryStore(ratelimit.Options{MaxKeys: cfg.MaxClients, IdleTTL: cfg.IdleTTL}), nil
+	case config.RateLimitStoreRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr:         cfg.Redis.Addr,
+			Password:     cfg.Redis.Password,
+			DB:           cfg.Redis.DB,
+			DialTimeout:  cfg.Redis.Timeout,
+			ReadTimeout:  cfg.Redis.Timeout,
+			WriteTimeout: cfg.Redis.Timeout,
+		})
+		// Redis 暂时不可用时仍然启动，请求按 fail_open 处理
+		ctx, cancel := context.WithTimeout(context.Background(), max(cfg.Redis.Timeout, time.Second))
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			logger.Warn("Rate limit store is not reachable", zap.String("addr", cfg.Redis.Addr), zap.Error(err))
+		}
+		return ratelimit.NewRedisStore(client, ratelimit.RedisOptions{Prefix: cfg.Redis.Prefix, FailOpen: cfg.FailOpen}), nil
+	default:
+		return nil, fmt.Errorf("unsupported rate limit store: %q", cfg.Store)
+	}
+}
+
+// newRateLimitRules 根据配置创建限流规则，路由规则中未设置的字段继承默认规则
+func newRateLimitRules(cfg config.RateLimitConfig, store ratelimit.Store) (*ratelimit.Rules, error) {
+	def, err := newRateLimitRule(store, "", cfg.RateLimitRule)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]ratelimit.Rule, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		var rule ratelimit.Rule
+		if route.Unlimited {
+			rule = ratelimit.Rule{Pattern: route.Pattern}
+		} else {
+			rule, err = newRateLimitRule(store, route.Pattern, inheritRateLimitRule(route.RateLimitRule, cfg.RateLimitRule))
+		}
+		if err != nil {
+			for _, r := range append(routes, def) {
+				if r.Limiter != nil {
+					r.Limiter.Stop()
+				}
+			}
+			return nil, fmt.Errorf("rate limit route %q: %w", route.Pattern, err)
+		}
+		routes = append(routes, rule)
+	}
+	return ratelimit.NewRules(def, routes)
+}
+
+// newRateLimitRule 创建一条限流规则，默认规则的 pattern 为空
+func newRateLimitRule(store ratelimit.Store, pattern string, cfg config.RateLimitRule) (ratelimit.Rule, error) {
+	key, err := ratelimit.NewKeyFunc(ratelimit.KeyOptions{
+		Kind:       cfg.Key,
+		Header:     cfg.Header,
+		IPv4Prefix: cfg.IPv4Prefix,
+		IPv6Prefix: cfg.IPv6Prefix,
+		Route:      pattern,
+	})
+	if err != nil {
+		return ratelimit.Rule{}, err
+	}
+	limiter, err := store.Limiter(cmp.Or(pattern, "default"), ratelimit.Algorithm(cfg.Algorithm), ratelimit.Limit{
+		Rate:   cfg.RPS,
+		Burst:  cfg.Burst,
+		Window: cfg.Window,
+	})
+	if err != nil {
+		return ratelimit.Rule{}, err
+	}
+	return ratelimit.Rule{Pattern: pattern, Limiter: limiter, Key: key}, nil
+}
+
+// inheritRateLimitRule 用默认规则补全路由规则中的零值字段
+func inheritRateLimitRule(rule, def config.RateLimitRule) config.RateLimitRule {
+	rule.Algorithm = cmp.Or(rule.Algorithm, def.Algorithm)
+	rule.RPS = cmp.Or(rule.RPS, def.RPS)
+	rule.Burst = cmp.Or(rule.Burst, def.Burst)
+	rule.Window = cmp.Or(rule.Window, def.Window)
+	rule.Key = cmp.Or(rule.Key, def.Key)
+	rule.Header = cmp.Or(rule.Header, def.Header)
+	rule.IPv4Prefix = cmp.Or(rule.IPv4Prefix, def.IPv4Prefix)
+	rule.IPv6Prefix = cmp.Or(rule.IPv6Prefix, def.IPv6Prefix)
+	return rule
+}
+
+// newResultCache 根据配置创建计算结果缓存，未启用时返回 nil
+func newResultCache(cfg config.CacheConfig, reg prometheus.Registerer) (cache.Cache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	switch cfg.Backend {
+	case config.CacheBackendMemory, "":
+		metrics := cache.NewMetrics(reg)
+		return cache.WithMetrics(cache.NewLRU(cfg.Size, cfg.TTL, metrics), metrics), nil
+	default:
+		// 外部缓存实现 cache.Cache 接口后在此处接入
+		return nil, fmt.Errorf("unsupported cache backend: %q", cfg.Backend)
+	}
+}
+
+// Handler 返回已应用全部中间件的 HTTP Handler
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// Close 停止 SLO 采样、名单热加载与限流器并关闭其存储，等待异步任务完成并释放数据库连接（如有）
+// 只释放已创建的资源，New 失败时同样通过它清理
+func (a *App) Close() error {
+	if a.Quotas != nil {
+		a.Quotas.Stop()
+	}
+	if a.slo != nil {
+		a.slo.Stop()
+	}
+	if a.ipFilter != nil {
+		a.ipFilter.Stop()
+	}
+	var storeErr error
+	if a.RateLimits != nil {
+		a.RateLimits.Stop()
+		storeErr = a.rateLimitStore.Close()
+	}
+	var calcErr error
+	if a.CalcService != nil {
+		calcErr = a.CalcService.Close()
+	}
+	return errors.Join(
+		storeErr,
+		calcErr,
+		database.Close(a.DB),
+	)
+}
+
+// idempotency 返回幂等中间件，未启用时原样返回 handler
+func (a *App) idempotency() func(http.Handler) http.Handler {
+	cfg := a.Config.Idempotency
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.Idempotency(
+		middleware.NewMemoryIdempotencyStore(cfg.TTL),
+		middleware.IdempotencyOptions{WaitTimeout: cfg.WaitTimeout},
+	)
+}
+
+// initAuth 根据配置创建认证方式，API 密钥始终可用，JWT 按需启用
+func (a *App) initAuth() error {
+	cfg := a.Config.Auth
+	if !cfg.Enabled {
+		return nil
+	}
+	a.authenticators = []auth.Authenticator{auth.NewAPIKeyAuthenticator(a.APIKeys)}
+	if !cfg.JWT.Enabled {
+		return nil
+	}
+
+	opts := auth.JWTOptions{
+		Issuer:     cfg.JWT.Issuer,
+		Audience:   cfg.JWT.Audience,
+		HMACSecret: []byte(cfg.JWT.Secret),
+		ClockSkew:  cfg.JWT.ClockSkew,
+		RolesClaim: cfg.JWT.RolesClaim,
+	}
+	if cfg.JWT.JWKS != "" {
+		opts.Keys = auth.NewJWKS(cfg.JWT.JWKS, cfg.JWT.JWKSRefresh, nil)
+	}
+	jwtAuth, err := auth.NewJWTAuthenticator(opts)
+	if err != nil {
+		return fmt.Errorf("invalid jwt config: %w", err)
+	}
+	a.authenticators = append(a.authenticators, jwtAuth)
+	return nil
+}
+
+// initRBAC 根据配置加载访问控制策略
+func (a *App) initRBAC() error {
+	cfg := a.Config.RBAC
+	if !cfg.Enabled {
+		return nil
+	}
+	if !a.Config.Auth.Enabled {
+		return errors.New("rbac requires auth to be enabled")
+	}
+	if len(cfg.Permissions) == 0 {
+		// 策略为空时 scope 校验已被取代且没有任何路由可以授权，拒绝启动
+		return errors.New("rbac is enabled but no permissions are configured")
+	}
+	var allowUncovered bool
+	switch cfg.Default {
+	case config.RBACDefaultDeny, "":
+	case config.RBACDefaultAllow:
+		allowUncovered = true
+	default:
+		return fmt.Errorf("unsupported rbac default %q", cfg.Default)
+	}
+
+	policy, err := auth.NewPolicy(auth.PolicyOptions{
+		Roles:          cfg.Roles,
+		Permissions:    cfg.Permissions,
+		Public:         cfg.Public,
+		AllowUncovered: allowUncovered,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid rbac policy: %w", err)
+	}
+	a.policy = policy
+	return nil
+}
+
+// authorize 返回 RBAC 授权中间件，未启用时原样返回 handler
+func (a *App) authorize() func(http.Handler) http.Handler {
+	if a.policy == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.Authorize(a.policy)
+}
+
+// authenticate 返回认证中间件，未启用时原样返回 handler
+func (a *App) authenticate() func(http.Handler) http.Handler {
+	if !a.Config.Auth.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.Authenticate(a.authenticators...)
+}
+
+// requireScope 返回 scope 校验中间件，未启用认证或由 RBAC 策略统一授权时原样返回 handler
+func (a *App) requireScope(scope string) func(http.Handler) http.Handler {
+	if !a.Config.Auth.Enabled || a.policy != nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.RequireScope(scope)
+}
+
+func (a *App) buildHandler(h *handler.Handler) http.Handler {
+	// 创建路由
+	router := http.NewServeMux()
+	router.HandleFunc("/", h.HomeHandler)
+	router.HandleFunc("/healthz", h.HealthCheckHandler)
+	// 启用 OpenMetrics 格式，抓取端协商后可以获得附带 trace_id 的 exemplar
+	router.Handle("/metrics", promhttp.InstrumentMetricHandler(a.registerer,
+		promhttp.HandlerFor(a.gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+
+	// Pprof (Debug 模式开启)
+	if a.Config.Debug {
+		logger.Info("Pprof enabled at /debug/pprof/")
+		router.HandleFunc("/debug/pprof/", pprof.Index)
+		router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	// SLO 状态页
+	if a.slo != nil {
+		router.HandleFunc("GET /debug/slo", handler.NewSLOHandler(a.slo).SLO)
+	}
+
+	// Swagger 文档
+	router.Handle("/swagger/", httpSwagger.WrapHandler)
+
+	// 计算接口会写入历史记录，属于变更操作，支持 Idempotency-Key
+	// 先校验 scope 再进入幂等处理，未授权的请求不会占用或重放幂等键；
+	// 配额在幂等处理之后扣减，重放的响应不重复计数
+	idempotent := a.idempotency()
+	requireCalculate := a.requireScope(auth.ScopeCalculate)
+	consumeQuota := middleware.Quota(a.Quotas)
+	mutating := func(next http.Handler) http.Handler {
+		return requireCalculate(idempotent(consumeQuota(next)))
+	}
+
+	// 避免把 nil 指针包装成非 nil 接口
+	usage := handler.NewUsageHandler(nil)
+	if a.Quotas != nil {
+		usage = handler.NewUsageHandler(a.Quotas)
+	}
+	readHistory := a.requireScope(auth.ScopeReadHistory)
+	deleteHistory := a.requireScope(auth.ScopeAdmin)
+
+	// API v1 路由组
+	v1 := http.NewServeMux()
+	v1.Handle("/add", mutating(http.HandlerFunc(h.AddHandler)))
+	v1.Handle("/subtract", mutating(http.HandlerFunc(h.SubtractHandler)))
+	v1.Handle("/multiply", mutating(http.HandlerFunc(h.MultiplyHandler)))
+	v1.Handle("/divide", mutating(http.HandlerFunc(h.DivideHandler)))
+	v1.Handle("/history", readHistory(http.HandlerFunc(h.HistoryHandler)))
+	v1.Handle("DELETE /history/{id}", deleteHistory(http.HandlerFunc(h.DeleteHistoryHandler)))
+	v1.Handle("/batch", mutating(http.HandlerFunc(h.BatchHandler)))
+	v1.HandleFunc("GET /usage", usage.Usage)
+
+	// 注册 v1 路由，同时保留根路径以兼容旧版本（可选）
+	router.Handle("/api/v1/", http.StripPrefix("/api/v1", middleware.RoutePattern(v1)))
+	// 兼容旧路由
+	router.Handle("/add", mutating(http.HandlerFunc(h.AddHandler)))
+	router.Handle("/subtract", mutating(http.HandlerFunc(h.SubtractHandler)))
+	router.Handle("/multiply", mutating(http.HandlerFunc(h.MultiplyHandler)))
+	router.Handle("/divide", mutating(http.HandlerFunc(h.DivideHandler)))
+	router.Handle("/history", readHistory(http.HandlerFunc(h.HistoryHandler)))
+
+	// 配置 CORS
+	corsHandler := cors.New(cors.Options{
+		AllowedOrigins:   []string{"*"}, // 生产环境请修改为具体域名
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"*"},
+		AllowCredentials: true,
+		Debug:            a.Config.Debug,
+	})
+
+	// 应用中间件
+	// 中间件执行顺序（从外到内）：
+	// 1. ClientIP: 按可信代理解析客户端 IP，后续日志、限流与历史记录都使用该结果
+	// 2. RequestID: 生成请求 ID，方便追踪
+	// 3. WithLogger: 注入带有 Request ID、客户端 IP 与 trace 信息的请求级 logger，通过 logger.FromContext 取出
+	// 4. Logger: 记录请求日志（包括 panic 后的 500）
+	// 5. Metrics: 按路由模式记录监控指标（包括 panic 后的 500），路由模式由包装在 ServeMux 外层的 RoutePattern 写入
+	// 6. Recovery: 捕获 panic，记录堆栈并返回 JSON 错误响应，防止服务崩溃
+	// 7. LoadShed: 自适应并发限制，过载时尽早拒绝请求，健康检查与监控端点豁免
+	// 8. IPFilter: 按客户端 IP 黑白名单拒绝请求，早于认证以免为被拒绝的来源做额外工作
+	// 9. Authenticate: 识别调用方（启用认证时），各路由再按 scope 授权
+	// 10. RateLimit: 按路由规则限流，位于认证之后以便按调用方限流
+	// 11. Authorize: 按 RBAC 策略授权（启用时取代 scope 校验）
+	// 12. Decompress: 解码压缩的请求体，位于认证与限流之后，避免为被拒绝的请求解压
+	// 13. Compress: 按 Accept-Encoding 协商编码压缩响应
+	metrics := middleware.NewHTTPMetrics(a.registerer, middleware.MetricsOptions{
+		DurationBuckets: a.Config.Metrics.DurationBuckets,
+		SizeBuckets:     a.Config.Metrics.SizeBuckets,
+	})
+	chained := middleware.Chain(middleware.RoutePattern(router),
+		corsHandler.Handler,
+		middleware.ClientIP(a.clientIP),
+		middleware.RequestID,
+		middleware.WithLogger,
+		middleware.LoggerMiddleware,
+		metrics.Middleware,
+		middleware.Recovery,
+		middleware.LoadShed(a.loadShed),
+		middleware.IPFilter(a.ipFilter),
+		a.authenticate(),
+		middleware.RateLimit(a.RateLimits),
+		a.authorize(),
+		a.decompress,
+		a.compress,
+	)
+
+	// 包装 Tracer Middleware
+	return otelhttp.NewHandler(chained, "http-server")
+}
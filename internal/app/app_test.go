@@ -0,0 +1,667 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/config"
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+func init() {
+	_ = logger.Initialize(true)
+}
+
+func newTestApp(t *testing.T, mutate func(cfg *config.Config)) *App {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Database.Name = filepath.Join(t.TempDir(), "test.db")
+	if mutate != nil {
+		mutate(cfg)
+	}
+
+	// 每个实例使用独立的注册表，指标互不影响
+	reg := prometheus.NewRegistry()
+	a, err := New(cfg, WithRegistry(reg, reg))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, a.Close())
+	})
+	return a
+}
+
+func serve(a *App, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestApp_IsolatedInstances(t *testing.T) {
+	t.Parallel()
+
+	a1 := newTestApp(t, nil)
+	a2 := newTestApp(t, nil)
+
+	assert.Equal(t, http.StatusOK, serve(a1, "/api/v1/add?a=1&b=2").Code)
+	assert.Equal(t, http.StatusOK, serve(a1, "/add?a=3&b=4").Code)
+	assert.Equal(t, http.StatusOK, serve(a2, "/api/v1/multiply?a=2&b=3").Code)
+
+	// 等待异步历史记录写入完成
+	require.NoError(t, a1.CalcService.Close())
+	require.NoError(t, a2.CalcService.Close())
+
+	h1, err := a1.HistoryRepo.List(context.Background(), 10)
+	require.NoError(t, err)
+	h2, err := a2.HistoryRepo.List(context.Background(), 10)
+	require.NoError(t, err)
+
+	assert.Len(t, h1, 2)
+	assert.Len(t, h2, 1)
+	assert.Equal(t, "multiply", h2[0].Operation)
+}
+
+func TestApp_RateLimitPerInstance(t *testing.T) {
+	t.Parallel()
+
+	limited := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.RPS = 1
+		cfg.RateLimit.Burst = 1
+	})
+	unlimited := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = false
+	})
+
+	assert.Equal(t, http.StatusOK, serve(limited, "/healthz").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(limited, "/healthz").Code)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve(unlimited, "/healthz").Code)
+	}
+}
+
+func TestApp_RateLimitRoutes(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.RPS = 1
+		cfg.RateLimit.Burst = 1
+		cfg.RateLimit.Routes = []config.RateLimitRoute{
+			{Pattern: "/healthz", Unlimited: true},
+			{Pattern: "/api/v1/divide", RateLimitRule: config.RateLimitRule{Algorithm: "gcra", Burst: 2}},
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve(a, "/healthz").Code)
+	}
+
+	// 路由规则只覆盖 burst，其余字段继承默认规则
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/divide?a=4&b=2").Code)
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/divide?a=4&b=2").Code)
+	limited := serve(a, "/api/v1/divide?a=4&b=2")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "1", limited.Header().Get("Retry-After"))
+
+	// 其他路由使用默认规则，额度与 divide 相互独立
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/add?a=1&b=2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(a, "/api/v1/add?a=1&b=2").Code)
+}
+
+func TestApp_RateLimitRedisStore(t *testing.T) {
+	t.Parallel()
+
+	m := miniredis.RunT(t)
+	useRedis := func(failOpen bool) func(cfg *config.Config) {
+		return func(cfg *config.Config) {
+			cfg.RateLimit.Enabled = true
+			cfg.RateLimit.RPS = 1
+			cfg.RateLimit.Burst = 2
+			cfg.RateLimit.Store = config.RateLimitStoreRedis
+			cfg.RateLimit.Redis.Addr = m.Addr()
+			cfg.RateLimit.FailOpen = failOpen
+		}
+	}
+
+	// 两个副本共享 Redis 中的额度
+	replica1 := newTestApp(t, useRedis(true))
+	replica2 := newTestApp(t, useRedis(false))
+	assert.Equal(t, http.StatusOK, serve(replica1, "/healthz").Code)
+	assert.Equal(t, http.StatusOK, serve(replica2, "/healthz").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(replica1, "/healthz").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(replica2, "/healthz").Code)
+
+	// Redis 不可用时按 fail_open 放行或拒绝
+	m.Close()
+	assert.Equal(t, http.StatusOK, serve(replica1, "/healthz").Code)
+	unavailable := serve(replica2, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, unavailable.Code)
+	assert.Equal(t, "1", unavailable.Header().Get("Retry-After"))
+}
+
+func TestApp_TrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	limited := func(trusted ...string) *App {
+		return newTestApp(t, func(cfg *config.Config) {
+			cfg.TrustedProxies = trusted
+			cfg.RateLimit.Enabled = true
+			cfg.RateLimit.RPS = 1
+			cfg.RateLimit.Burst = 1
+		})
+	}
+	serveFrom := func(a *App, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 对端不是可信代理时伪造 X-Forwarded-For 无法绕过按 IP 限流
+	direct := limited()
+	assert.Equal(t, http.StatusOK, serveFrom(direct, "203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(direct, "203.0.113.2"))
+
+	// 经可信代理转发时按真实客户端限流
+	proxied := limited("10.0.0.0/8")
+	assert.Equal(t, http.StatusOK, serveFrom(proxied, "203.0.113.1"))
+	assert.Equal(t, http.StatusOK, serveFrom(proxied, "203.0.113.2"))
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(proxied, "198.51.100.1, 203.0.113.2"))
+}
+
+func TestApp_InvalidTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Database.Name = filepath.Join(t.TempDir(), "test.db")
+	cfg.TrustedProxies = []string{"10.0.0.0/40"}
+
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "invalid trusted proxy")
+}
+
+func TestApp_IPFilter(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.TrustedProxies = []string{"10.0.0.0/8"}
+		cfg.IPFilter.Enabled = true
+		cfg.IPFilter.Deny = []string{"198.51.100.0/24"}
+		cfg.IPFilter.Groups = []config.IPFilterGroup{{Pattern: "/api/v1/history", Allow: []string{"192.0.2.0/24"}}}
+	})
+	serveFrom := func(path, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 按可信代理解析出的客户端 IP 过滤
+	assert.Equal(t, http.StatusOK, serveFrom("/healthz", "203.0.113.1"))
+	assert.Equal(t, http.StatusForbidden, serveFrom("/healthz", "198.51.100.1"))
+	assert.Equal(t, http.StatusForbidden, serveFrom("/api/v1/history", "203.0.113.1"))
+	assert.Equal(t, http.StatusOK, serveFrom("/api/v1/history", "192.0.2.1"))
+}
+
+func TestApp_InvalidRateLimitConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Database.Name = filepath.Join(t.TempDir(), "test.db")
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Routes = []config.RateLimitRoute{{Pattern: "/api/v1/add", RateLimitRule: config.RateLimitRule{Algorithm: "leaky"}}}
+
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, `rate limit route "/api/v1/add"`)
+}
+
+func TestApp_LoadShed(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.LoadShed.Enabled = true
+	})
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/add?a=1&b=2").Code)
+	assert.Equal(t, http.StatusOK, serve(a, "/healthz").Code)
+	assert.Equal(t, 100, a.loadShed.Limit())
+
+	cfg := config.Default()
+	cfg.Database.Name = filepath.Join(t.TempDir(), "test.db")
+	cfg.LoadShed.Enabled = true
+	cfg.LoadShed.Algorithm = "vegas"
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "unsupported algorithm")
+}
+
+func TestApp_MetricsRouteLabels(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, nil)
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/add?a=1&b=2").Code)
+	assert.Equal(t, http.StatusOK, serve(a, "/add?a=1&b=2").Code)
+	assert.Equal(t, http.StatusNotFound, serve(a, "/wp-login.php").Code)
+
+	body := serve(a, "/metrics").Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/add",status="200"}`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/",status="404"}`)
+	assert.Contains(t, body, "http_request_size_bytes_bucket")
+	assert.Contains(t, body, "http_requests_in_flight")
+	assert.NotContains(t, body, "/wp-login.php")
+	assert.NotContains(t, body, `route="/api/v1/add"`)
+}
+
+func TestApp_SLO(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.SLO.Enabled = true
+		cfg.SLO.Objectives = []config.SLOObjective{{
+			Name:         "app-test-calculate",
+			Routes:       []string{"/add"},
+			Availability: 0.999,
+			Latency:      config.SLOLatency{Threshold: 250 * time.Millisecond, Target: 0.99},
+		}}
+	})
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/add?a=1&b=2").Code)
+
+	w := serve(a, "/debug/slo")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data struct {
+			Period     string `json:"period"`
+			Objectives []struct {
+				Name string `json:"name"`
+				SLIs []struct {
+					SLI       string             `json:"sli"`
+					Total     float64            `json:"total"`
+					BurnRates map[string]float64 `json:"burn_rates"`
+				} `json:"slis"`
+			} `json:"objectives"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "30d", resp.Data.Period)
+	require.Len(t, resp.Data.Objectives, 1)
+	require.Len(t, resp.Data.Objectives[0].SLIs, 2)
+	assert.Equal(t, "availability", resp.Data.Objectives[0].SLIs[0].SLI)
+	assert.GreaterOrEqual(t, resp.Data.Objectives[0].SLIs[0].Total, float64(1))
+	assert.Len(t, resp.Data.Objectives[0].SLIs[0].BurnRates, 7)
+	assert.Contains(t, serve(a, "/metrics").Body.String(), `slo_objective_ratio{sli="availability",slo="app-test-calculate"} 0.999`)
+
+	// 延迟阈值必须落在耗时直方图的桶边界上
+	cfg := config.Default()
+	cfg.Database.Name = filepath.Join(t.TempDir(), "test.db")
+	cfg.SLO.Enabled = true
+	cfg.SLO.Objectives = []config.SLOObjective{{Name: "api", Latency: config.SLOLatency{Threshold: 300 * time.Millisecond, Target: 0.99}}}
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "not a duration histogram bucket boundary")
+
+	// 未启用时不注册状态页
+	assert.Equal(t, http.StatusNotFound, serve(newTestApp(t, nil), "/debug/slo").Code)
+}
+
+func TestApp_MemoryStorage(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Storage = config.StorageMemory
+	})
+	assert.Nil(t, a.DB)
+
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/subtract?a=5&b=3").Code)
+	require.NoError(t, a.CalcService.Close())
+
+	history, err := a.HistoryRepo.List(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "subtract", history[0].Operation)
+}
+
+func TestApp_ReleasesResourcesOnError(t *testing.T) {
+	// 不并行执行，避免其他测试的 goroutine 影响计数；先完整创建并关闭一次，排除驱动等一次性启动的 goroutine
+	cfg := config.Default()
+	cfg.Database.Name = filepath.Join(t.TempDir(), "warmup.db")
+	reg := prometheus.NewRegistry()
+	a, err := New(cfg, WithRegistry(reg, reg))
+	require.NoError(t, err)
+	require.NoError(t, a.Close())
+	before := runtime.NumGoroutine()
+
+	cfg = config.Default()
+	cfg.Database.Name = filepath.Join(t.TempDir(), "test.db")
+	cfg.Quota.Enabled = true
+	cfg.RateLimit.Enabled = true
+	cfg.SLO.Enabled = true
+	cfg.SLO.Objectives = []config.SLOObjective{{Name: "api", Latency: config.SLOLatency{Threshold: 300 * time.Millisecond, Target: 0.99}}}
+	reg = prometheus.NewRegistry()
+	_, err = New(cfg, WithRegistry(reg, reg))
+	require.Error(t, err)
+
+	// 失败前已启动的配额清理、限流器清理与异步写入 goroutine 全部退出
+	assert.Eventually(t, func() bool { return runtime.NumGoroutine() <= before }, time.Second, 10*time.Millisecond)
+}
+
+func TestApp_UnsupportedStorage(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Storage = "cassandra"
+	_, err := New(cfg)
+	assert.Error(t, err)
+}
+
+func TestApp_BatchIsAtomic(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, nil)
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post(`{"operations":[{"operation":"add","a":1,"b":2},{"operation":"multiply","a":3,"b":4}]}`))
+	assert.Equal(t, http.StatusBadRequest, post(`{"operations":[{"operation":"add","a":1,"b":2},{"operation":"divide","a":1,"b":0}]}`))
+
+	history, err := a.HistoryRepo.List(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestApp_CompressedBatch(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, nil)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, err := gz.Write([]byte(`{"operations":[{"operation":"add","a":1,"b":2}]}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader("{}"))
+	req.Header.Set("Content-Encoding", "br")
+	w = httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestApp_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, nil)
+
+	add := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/add?a=1&b=2", nil)
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	first := add("retry-1")
+	retry := add("retry-1")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+
+	// 重试不会产生重复的历史记录
+	require.NoError(t, a.CalcService.Close())
+	history, err := a.HistoryRepo.List(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestApp_AuthEnabled(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Auth.Enabled = true
+	})
+
+	ctx := context.Background()
+	calcKey, _, err := a.APIKeys.Create(ctx, "calc", []string{auth.ScopeCalculate})
+	require.NoError(t, err)
+	adminKey, _, err := a.APIKeys.Create(ctx, "admin", []string{auth.ScopeAdmin})
+	require.NoError(t, err)
+
+	do := func(target, key string) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			req.Header.Set(auth.HeaderAPIKey, key)
+		}
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 公共端点无需认证
+	assert.Equal(t, http.StatusOK, do("/healthz", ""))
+
+	assert.Equal(t, http.StatusUnauthorized, do("/api/v1/add?a=1&b=2", ""))
+	assert.Equal(t, http.StatusUnauthorized, do("/api/v1/add?a=1&b=2", "pg_000000000000_invalid"))
+	assert.Equal(t, http.StatusOK, do("/api/v1/add?a=1&b=2", calcKey))
+	assert.Equal(t, http.StatusOK, do("/add?a=1&b=2", calcKey))
+
+	assert.Equal(t, http.StatusForbidden, do("/api/v1/history", calcKey))
+	assert.Equal(t, http.StatusForbidden, do("/history", calcKey))
+	assert.Equal(t, http.StatusOK, do("/api/v1/history", adminKey))
+}
+
+func TestApp_JWTAuth(t *testing.T) {
+	t.Parallel()
+
+	secret := "0123456789abcdef0123456789abcdef"
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Auth.Enabled = true
+		cfg.Auth.JWT.Enabled = true
+		cfg.Auth.JWT.Issuer = "https://sso.example.com"
+		cfg.Auth.JWT.Secret = secret
+	})
+
+	token := func(scope string) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":   "https://sso.example.com",
+			"sub":   "alice",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": scope,
+		}).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return s
+	}
+	do := func(target, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	anonymous := do("/api/v1/add?a=1&b=2", "")
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.Contains(t, anonymous.Header().Get("WWW-Authenticate"), "Bearer")
+	assert.Contains(t, anonymous.Header().Get("WWW-Authenticate"), "ApiKey")
+
+	assert.Equal(t, http.StatusOK, do("/api/v1/add?a=1&b=2", "Bearer "+token("calculate")).Code)
+	assert.Equal(t, http.StatusForbidden, do("/api/v1/history", "Bearer "+token("calculate")).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("/api/v1/add?a=1&b=2", "Bearer "+token("calculate")+"x").Code)
+}
+
+func TestApp_InvalidJWTConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Storage = config.StorageMemory
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWT.Enabled = true
+
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "jwt")
+}
+
+func TestApp_RBAC(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		// 使用仓库自带 config.yaml 中声明的策略
+		fileCfg, err := config.LoadConfig("../../config")
+		require.NoError(t, err)
+		cfg.RBAC = fileCfg.RBAC
+		cfg.RBAC.Enabled = true
+		cfg.Auth.Enabled = true
+	})
+
+	ctx := context.Background()
+	key := func(scopes ...string) string {
+		k, _, err := a.APIKeys.Create(ctx, strings.Join(scopes, "+"), scopes)
+		require.NoError(t, err)
+		return k
+	}
+	calcKey := key(auth.ScopeCalculate)
+	readerKey := key(auth.ScopeReadHistory)
+	adminKey := key(auth.ScopeAdmin)
+
+	do := func(method, target, key string) int {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set(auth.HeaderAPIKey, key)
+		}
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/healthz", ""))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/", ""))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/metrics", ""))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/debug/slo", ""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/debug/slo", calcKey))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/usage", ""))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/usage", calcKey))
+	// 未被策略覆盖的路由默认拒绝
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/v1/unknown", adminKey))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/metrics", calcKey))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/metrics", adminKey))
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/add?a=1&b=2", calcKey))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/v1/add?a=1&b=2", readerKey))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/history", readerKey))
+
+	require.NoError(t, a.CalcService.Close())
+	history, err := a.HistoryRepo.List(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	target := "/api/v1/history/" + strconv.FormatUint(uint64(history[0].ID), 10)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, target, readerKey))
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, target, adminKey))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, target, adminKey))
+}
+
+func TestApp_RBACRequiresAuth(t *testing.T) {
+	cfg := config.Default()
+	cfg.Storage = config.StorageMemory
+	cfg.RBAC.Enabled = true
+	cfg.RBAC.Permissions = map[string][]string{"metrics": {"/metrics"}}
+
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "rbac")
+}
+
+func TestApp_RBACInvalidDefault(t *testing.T) {
+	cfg := config.Default()
+	cfg.Storage = config.StorageMemory
+	cfg.Auth.Enabled = true
+	cfg.RBAC.Enabled = true
+	cfg.RBAC.Default = "maybe"
+	cfg.RBAC.Permissions = map[string][]string{"metrics": {"/metrics"}}
+
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "rbac default")
+}
+
+func TestApp_Quota(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "quota.db")
+	newApp := func() *App {
+		cfg := config.Default()
+		cfg.Database.Name = dbPath
+		cfg.Quota.Enabled = true
+		cfg.Quota.Daily = 2
+		a, err := New(cfg)
+		require.NoError(t, err)
+		return a
+	}
+
+	a := newApp()
+	assert.Equal(t, http.StatusOK, serve(a, "/api/v1/add?a=1&b=2").Code)
+	w := serve(a, "/add?a=1&b=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	require.NoError(t, a.Close())
+
+	// 用量持久化，重启后仍然生效
+	a = newApp()
+	t.Cleanup(func() { assert.NoError(t, a.Close()) })
+	assert.Equal(t, http.StatusTooManyRequests, serve(a, "/api/v1/multiply?a=1&b=2").Code)
+
+	// 非计算接口不消耗配额
+	assert.Equal(t, http.StatusOK, serve(a, "/healthz").Code)
+
+	w = serve(a, "/api/v1/usage")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data struct {
+			Subject string `json:"subject"`
+			Quotas  []struct {
+				Period    string `json:"period"`
+				Limit     int64  `json:"limit"`
+				Used      int64  `json:"used"`
+				Remaining int64  `json:"remaining"`
+			} `json:"quotas"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ip:10.0.0.1", body.Data.Subject)
+	require.Len(t, body.Data.Quotas, 1)
+	assert.Equal(t, "day", body.Data.Quotas[0].Period)
+	assert.Equal(t, int64(2), body.Data.Quotas[0].Used)
+	assert.Equal(t, int64(0), body.Data.Quotas[0].Remaining)
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	// HeaderAPIKey 携带 API 密钥的请求头
+	HeaderAPIKey = "X-API-Key"
+	// SchemeAPIKey Authorization 请求头中的 API 密钥认证方案
+	SchemeAPIKey = "ApiKey"
+)
+
+// APIKeyVerifier 校验明文 API 密钥并返回对应的调用方
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// APIKeyAuthenticator 基于 API 密钥的认证方式
+// 支持 X-API-Key 请求头和 Authorization: ApiKey <key>
+type APIKeyAuthenticator struct {
+	verifier APIKeyVerifier
+}
+
+// NewAPIKeyAuthenticator 创建 API 密钥认证器
+func NewAPIKeyAuthenticator(verifier APIKeyVerifier) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{verifier: verifier}
+}
+
+// Challenge 返回 401 响应中的 WWW-Authenticate 值
+func (a *APIKeyAuthenticator) Challenge() string {
+	return SchemeAPIKey
+}
+
+// Authenticate 实现 Authenticator 接口
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	if key == "" {
+		scheme, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, SchemeAPIKey) {
+			key = strings.TrimSpace(credentials)
+		}
+	}
+	if key == "" {
+		return nil, nil
+	}
+	return a.verifier.VerifyAPIKey(r.Context(), key)
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+const (
+	// defaultJWKSRefresh JWKS 缓存的默认刷新间隔
+	defaultJWKSRefresh = time.Hour
+	// jwksMissRefreshInterval 遇到未知 kid 时强制刷新的最小间隔，防止伪造 kid 打满密钥服务
+	jwksMissRefreshInterval = 10 * time.Second
+	// jwksFetchTimeout 单次加载 JWKS 的超时时间
+	jwksFetchTimeout = 10 * time.Second
+	// maxJWKSSize JWKS 文档的最大字节数
+	maxJWKSSize = 1 << 20
+)
+
+// ErrKeyNotFound 密钥集中不存在指定 kid 的密钥
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// KeySet 按 kid 查找验签公钥
+// 返回值为 *rsa.PublicKey 或 *ecdsa.PublicKey
+type KeySet interface {
+	Key(ctx context.Context, kid string) (any, error)
+}
+
+// JWKS 从文件或 URL 加载的 JSON Web Key Set
+// 密钥按 refresh 间隔缓存；遇到未知 kid 时提前刷新，以支持签发方轮换密钥
+type JWKS struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+	// group 合并并发的加载，加载期间不持有 mu
+	group singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]any
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// lastErr 最近一次加载的错误，刷新间隔内的调用直接返回它
+	lastErr error
+}
+
+// NewJWKS 创建 JWKS 密钥集
+// source 为 http(s) URL 或本地文件路径（可带 file:// 前缀），refresh 为 0 时使用默认间隔
+func NewJWKS(source string, refresh time.Duration, client *http.Client) *JWKS {
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+	return &JWKS{source: source, refresh: refresh, client: client, now: time.Now}
+}
+
+// Key 实现 KeySet 接口
+func (s *JWKS) Key(ctx context.Context, kid string) (any, error) {
+	s.mu.Lock()
+	keys := s.keys
+	due := keys == nil || s.now().Sub(s.fetchedAt) >= s.refresh
+	s.mu.Unlock()
+
+	if due {
+		// 刷新失败时继续使用旧密钥，避免密钥服务抖动导致全部请求认证失败
+		var err error
+		if keys, err = s.reload(ctx); keys == nil {
+			return nil, err
+		}
+	}
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	keys, err := s.reload(ctx)
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if keys == nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrKeyNotFound, kid)
+}
+
+// reload 重新加载密钥集，返回加载后的密钥，加载失败时返回旧密钥与错误
+// 并发调用共享同一次加载；距上次尝试不足 jwksMissRefreshInterval 时直接返回上次的结果，
+// 避免密钥服务不可用或伪造 kid 时每个请求都访问密钥服务。
+// 加载使用脱离请求的 context 与独立超时，发起加载的请求被取消不影响其他等待者
+func (s *JWKS) reload(ctx context.Context) (map[string]any, error) {
+	ch := s.group.DoChan("", func() (any, error) {
+		s.mu.Lock()
+		now := s.now()
+		if !s.attemptedAt.IsZero() && now.Sub(s.attemptedAt) < jwksMissRefreshInterval {
+			keys, err := s.keys, s.lastErr
+			s.mu.Unlock()
+			return keys, err
+		}
+		s.attemptedAt = now
+		s.mu.Unlock()
+
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+		defer cancel()
+		keys, err := s.load(loadCtx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.lastErr = err
+		if err != nil {
+			if s.keys != nil {
+				logger.FromContext(ctx).Warn("Failed to refresh JWKS, using cached keys",
+					zap.String("source", s.source), zap.Error(err))
+			}
+			return s.keys, err
+		}
+		s.keys, s.fetchedAt = keys, now
+		return keys, nil
+	})
+
+	select {
+	case res := <-ch:
+		keys, _ := res.Val.(map[string]any)
+		return keys, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load 读取并解析密钥集
+func (s *JWKS) load(ctx context.Context) (map[string]any, error) {
+	data, err := s.read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS from %s: %w", s.source, err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS from %s: %w", s.source, err)
+	}
+	return keys, nil
+}
+
+func (s *JWKS) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(s.source, "file://"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// jwk RFC 7517 JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS 解析 JWKS 文档，返回 kid 到密钥的映射
+// 仅保留用于签名的 RSA（不少于 2048 位）和 EC（P-256/P-384/P-521）公钥，其余密钥被忽略；
+// 格式错误的密钥使整个文档解析失败
+func ParseJWKS(data []byte) (map[string]any, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey 将 JWK 转换为验签公钥，不支持的密钥类型、曲线以及过短的 RSA 密钥返回 nil
+func (k *jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, nil
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		key, err := k.ecdsaKey()
+		if key == nil {
+			// 直接返回 nil 指针会得到非 nil 的 any
+			return nil, err
+		}
+		return key, err
+	default:
+		return nil, nil
+	}
+}
+
+// ecdsaKey 将 EC JWK 转换为公钥，不支持的曲线返回 nil
+func (k *jwk) ecdsaKey() (*ecdsa.PublicKey, error) {
+	var (
+		curve elliptic.Curve
+		check ecdh.Curve
+	)
+	switch k.Crv {
+	case "P-256":
+		curve, check = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, check = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, check = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, nil
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, errors.New("invalid x coordinate")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, errors.New("invalid y coordinate")
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) != size || len(y) != size {
+		return nil, errors.New("invalid coordinate length")
+	}
+
+	// 借助 crypto/ecdh 校验点在曲线上，拒绝无效曲线攻击
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := check.NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid curve point: %w", err)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/exiaohu/go-demo/pkg/errors"
+)
+
+const (
+	// SchemeBearer Authorization 请求头中的 Bearer 认证方案
+	SchemeBearer = "Bearer"
+	// defaultRolesClaim 默认的角色声明名称
+	defaultRolesClaim = "roles"
+)
+
+// JWTOptions JWT 校验选项
+type JWTOptions struct {
+	// Issuer 期望的 iss，为空时不校验
+	Issuer string
+	// Audience 期望的 aud，为空时不校验
+	Audience string
+	// HMACSecret HS256 共享密钥
+	HMACSecret []byte
+	// Keys RS256/ES256 验签公钥，通常为 JWKS
+	Keys KeySet
+	// ClockSkew 校验 exp/nbf/iat 时容忍的时钟偏差
+	ClockSkew time.Duration
+	// RolesClaim 映射为角色的声明名称，默认 roles
+	RolesClaim string
+}
+
+// JWTAuthenticator 基于 Authorization: Bearer JWT 的认证方式
+type JWTAuthenticator struct {
+	opts   JWTOptions
+	parser *jwt.Parser
+}
+
+// NewJWTAuthenticator 创建 JWT 认证器，至少需要配置 HMACSecret 或 Keys 之一
+func NewJWTAuthenticator(opts JWTOptions) (*JWTAuthenticator, error) {
+	var methods []string
+	if len(opts.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if opts.Keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, stderrors.New("jwt: either an HMAC secret or a key set is required")
+	}
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = defaultRolesClaim
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(opts.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	return &JWTAuthenticator{opts: opts, parser: jwt.NewParser(parserOpts...)}, nil
+}
+
+// Challenge 返回 401 响应中的 WWW-Authenticate 值
+func (a *JWTAuthenticator) Challenge() string {
+	return SchemeBearer
+}
+
+// Authenticate 实现 Authenticator 接口
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, raw, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, SchemeBearer) {
+		return nil, nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(strings.TrimSpace(raw), claims, func(token *jwt.Token) (any, error) {
+		return a.key(r, token)
+	})
+	if err != nil {
+		return nil, errors.NewWithDetails(errors.ErrTypeUnauthorized, "Invalid bearer token", err.Error())
+	}
+
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, errors.NewWithDetails(errors.ErrTypeUnauthorized, "Invalid bearer token", "missing subject")
+	}
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name = sub
+	}
+
+	return &Principal{
+		ID:     "jwt:" + sub,
+		Name:   name,
+		Method: MethodJWT,
+		Scopes: tokenScopes(claims),
+		Roles:  stringList(claims[a.opts.RolesClaim]),
+	}, nil
+}
+
+// key 根据签名算法选择验签密钥
+// HS256 只使用共享密钥，RS256/ES256 只使用密钥集中类型匹配的公钥，防止算法混淆攻击
+func (a *JWTAuthenticator) key(r *http.Request, token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.opts.HMACSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := a.opts.Keys.Key(r.Context(), kid)
+	if err != nil {
+		return nil, err
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		if pub, ok := key.(*rsa.PublicKey); ok {
+			return pub, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if pub, ok := key.(*ecdsa.PublicKey); ok {
+			return pub, nil
+		}
+	}
+	return nil, fmt.Errorf("key %q cannot be used with %s", kid, token.Method.Alg())
+}
+
+// tokenScopes 读取 OAuth 2.0 的 scope（空格分隔）或 scp（数组）声明，仅保留本服务认识的 scope
+func tokenScopes(claims jwt.MapClaims) []string {
+	raw := stringList(claims["scope"])
+	if len(raw) == 0 {
+		raw = stringList(claims["scp"])
+	}
+	return slices.DeleteFunc(raw, func(scope string) bool {
+		return !ValidScope(scope)
+	})
+}
+
+// stringList 将字符串数组或空格分隔的字符串声明转换为切片
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,403 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+func init() {
+	_ = logger.Initialize(true)
+}
+
+const (
+	testIssuer   = "https://sso.example.com"
+	testAudience = "playground"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": b64(key.N.Bytes()),
+		"e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return map[string]string{
+		"kty": "EC", "kid": kid, "crv": key.Curve.Params().Name,
+		"x": b64(key.X.FillBytes(make([]byte, size))),
+		"y": b64(key.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+func jwksJSON(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "alice",
+		"name":  "Alice",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": []string{"history-admin", "viewer"},
+		"scope": "calculate read-history unknown",
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestJWTAuthenticator_HS256(t *testing.T) {
+	a, err := NewJWTAuthenticator(JWTOptions{
+		Issuer:     testIssuer,
+		Audience:   testAudience,
+		HMACSecret: testSecret,
+		ClockSkew:  30 * time.Second,
+	})
+	require.NoError(t, err)
+
+	t.Run("Valid", func(t *testing.T) {
+		p, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, "", testSecret, validClaims())))
+		require.NoError(t, err)
+		assert.Equal(t, "jwt:alice", p.ID)
+		assert.Equal(t, "Alice", p.Name)
+		assert.Equal(t, MethodJWT, p.Method)
+		assert.Equal(t, []string{"history-admin", "viewer"}, p.Roles)
+		// 未知 scope 被丢弃
+		assert.Equal(t, []string{ScopeCalculate, ScopeReadHistory}, p.Scopes)
+	})
+
+	t.Run("NoCredentials", func(t *testing.T) {
+		p, err := a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+	})
+
+	tests := []struct {
+		name   string
+		mutate func(c jwt.MapClaims)
+		ok     bool
+	}{
+		{"ExpiredWithinSkew", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() }, true},
+		{"Expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, false},
+		{"MissingExpiry", func(c jwt.MapClaims) { delete(c, "exp") }, false},
+		{"NotYetValid", func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Minute).Unix() }, false},
+		{"IssuedInFuture", func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Minute).Unix() }, false},
+		{"WrongIssuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, false},
+		{"WrongAudience", func(c jwt.MapClaims) { c["aud"] = "other" }, false},
+		{"AudienceList", func(c jwt.MapClaims) { c["aud"] = []string{"other", testAudience} }, true},
+		{"MissingSubject", func(c jwt.MapClaims) { delete(c, "sub") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+			p, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, "", testSecret, claims)))
+			if tt.ok {
+				assert.NoError(t, err)
+				assert.NotNil(t, p)
+				return
+			}
+			assert.True(t, errors.IsUnauthorizedError(err), "got %v", err)
+			assert.Nil(t, p)
+		})
+	}
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		_, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, "", []byte("another-secret-another-secret!!!"), validClaims())))
+		assert.True(t, errors.IsUnauthorizedError(err))
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := a.Authenticate(bearer("not-a-jwt"))
+		assert.True(t, errors.IsUnauthorizedError(err))
+	})
+}
+
+func TestJWTAuthenticator_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var (
+		mu      sync.Mutex
+		doc     = jwksJSON(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey))
+		fetches atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc)
+	}))
+	t.Cleanup(srv.Close)
+
+	jwks := NewJWKS(srv.URL, time.Hour, srv.Client())
+	a, err := NewJWTAuthenticator(JWTOptions{Issuer: testIssuer, Audience: testAudience, Keys: jwks})
+	require.NoError(t, err)
+
+	t.Run("RS256", func(t *testing.T) {
+		p, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims())))
+		require.NoError(t, err)
+		assert.Equal(t, "jwt:alice", p.ID)
+	})
+
+	t.Run("ES256", func(t *testing.T) {
+		p, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims())))
+		require.NoError(t, err)
+		assert.Equal(t, "jwt:alice", p.ID)
+	})
+
+	t.Run("KeysAreCached", func(t *testing.T) {
+		before := fetches.Load()
+		_, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims())))
+		require.NoError(t, err)
+		assert.Equal(t, before, fetches.Load())
+	})
+
+	t.Run("KidKeyTypeMismatch", func(t *testing.T) {
+		// RS256 Token 指向 EC 公钥
+		_, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, "ec-1", rsaKey, validClaims())))
+		assert.True(t, errors.IsUnauthorizedError(err))
+	})
+
+	t.Run("HS256Rejected", func(t *testing.T) {
+		// 算法混淆：以 RSA 公钥作为 HMAC 密钥签名
+		pub := rsaKey.PublicKey.N.Bytes()
+		_, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, "rsa-1", pub, validClaims())))
+		assert.True(t, errors.IsUnauthorizedError(err))
+	})
+
+	t.Run("KeyRotation", func(t *testing.T) {
+		mu.Lock()
+		doc = jwksJSON(t, rsaJWK("rsa-2", &rotated.PublicKey))
+		mu.Unlock()
+		// 绕过未知 kid 的刷新间隔
+		jwks.mu.Lock()
+		jwks.attemptedAt = time.Time{}
+		jwks.mu.Unlock()
+
+		p, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, "rsa-2", rotated, validClaims())))
+		require.NoError(t, err)
+		assert.Equal(t, "jwt:alice", p.ID)
+
+		// 刷新间隔内未知 kid 不会再次请求密钥服务
+		before := fetches.Load()
+		_, err = a.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, "missing", rotated, validClaims())))
+		assert.True(t, errors.IsUnauthorizedError(err))
+		assert.Equal(t, before, fetches.Load())
+	})
+}
+
+func TestRequireRole(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, errors.IsUnauthorizedError(RequireRole(ctx, "viewer")))
+
+	ctx = NewContext(ctx, &Principal{ID: "jwt:alice", Roles: []string{"viewer"}})
+	assert.NoError(t, RequireRole(ctx, "admin", "viewer"))
+	assert.True(t, errors.IsForbiddenError(RequireRole(ctx, "admin")))
+}
+
+func TestJWKS_File(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksJSON(t, ecJWK("ec-384", &ecKey.PublicKey)), 0o600))
+
+	jwks := NewJWKS("file://"+path, 0, nil)
+	key, err := jwks.Key(context.Background(), "ec-384")
+	require.NoError(t, err)
+	assert.True(t, ecKey.PublicKey.Equal(key))
+
+	_, err = jwks.Key(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestJWKS_StaleKeysOnRefreshFailure(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(jwksJSON(t, rsaJWK("rsa-1", &rsaKey.PublicKey)))
+	}))
+	t.Cleanup(srv.Close)
+
+	now := time.Now()
+	jwks := NewJWKS(srv.URL, time.Minute, srv.Client())
+	jwks.now = func() time.Time { return now }
+
+	_, err = jwks.Key(context.Background(), "rsa-1")
+	require.NoError(t, err)
+
+	// 缓存过期后密钥服务不可用，继续使用旧密钥
+	fail.Store(true)
+	now = now.Add(2 * time.Minute)
+	key, err := jwks.Key(context.Background(), "rsa-1")
+	require.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(key))
+}
+
+func TestParseJWKS_InvalidKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	offCurve := ecJWK("bad", &ecKey.PublicKey)
+	offCurve["y"] = offCurve["x"]
+
+	tests := map[string][]byte{
+		"OffCurvePoint": jwksJSON(t, offCurve),
+		"NotJSON":       []byte("{"),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseJWKS(data)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("IgnoresEncryptionKeys", func(t *testing.T) {
+		enc := ecJWK("enc", &ecKey.PublicKey)
+		enc["use"] = "enc"
+		keys, err := ParseJWKS(jwksJSON(t, enc, map[string]string{"kty": "OKP", "kid": "ed"}))
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("SkipsUnsupportedKeys", func(t *testing.T) {
+		small, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+		secp := ecJWK("secp256k1", &ecKey.PublicKey)
+		secp["crv"] = "secp256k1"
+
+		keys, err := ParseJWKS(jwksJSON(t, rsaJWK("small", &small.PublicKey), secp, ecJWK("ec-1", &ecKey.PublicKey)))
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+		assert.Contains(t, keys, "ec-1")
+	})
+}
+
+func TestJWKS_ThrottlesFailedLoads(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	now := time.Now()
+	jwks := NewJWKS(srv.URL, time.Minute, srv.Client())
+	jwks.now = func() time.Time { return now }
+
+	// 尚无可用密钥时，刷新间隔内的失败同样不会再次请求密钥服务
+	for range 3 {
+		_, err := jwks.Key(context.Background(), "rsa-1")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+
+	now = now.Add(jwksMissRefreshInterval)
+	_, err := jwks.Key(context.Background(), "rsa-1")
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestJWKS_ConcurrentLoadsShareFetch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		<-release
+		_, _ = w.Write(jwksJSON(t, rsaJWK("rsa-1", &rsaKey.PublicKey)))
+	}))
+	t.Cleanup(srv.Close)
+	jwks := NewJWKS(srv.URL, time.Minute, srv.Client())
+
+	// 发起加载的请求被取消后，加载继续进行，其他等待者仍拿到密钥
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(ctx, "rsa-1")
+		first <- err
+	}()
+	require.Eventually(t, func() bool { return fetches.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := jwks.Key(context.Background(), "rsa-1")
+			errs <- err
+		}()
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+}
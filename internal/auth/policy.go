@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PermissionAll 授予全部权限的通配符
+const PermissionAll = "*"
+
+// Decision 一次授权评估的结果
+type Decision struct {
+	// Allowed 是否放行
+	Allowed bool
+	// Pattern 命中的路由模式，未被任何模式覆盖时为空
+	Pattern string
+	// Required 命中路由所需的权限，具备其中任意一个即可；为空表示该路由与方法未被策略覆盖
+	Required []string
+}
+
+// routeRule 路由模式下某个权限覆盖的方法，methods 为空表示全部方法
+// permission 为空表示公开路由，无需认证即可访问
+type routeRule struct {
+	permission string
+	methods    []string
+}
+
+// PolicyOptions 访问控制策略配置
+type PolicyOptions struct {
+	// Roles 角色 → 权限列表，PermissionAll 表示全部权限
+	Roles map[string][]string
+	// Permissions 权限 → 路由模式列表
+	Permissions map[string][]string
+	// Public 无需认证即可访问的路由模式
+	Public []string
+	// AllowUncovered 放行未被任何模式覆盖的路由与方法，默认拒绝
+	AllowUncovered bool
+}
+
+// Policy 基于角色的访问控制策略：角色 → 权限 → 路由模式与方法
+//
+// 路由使用 Go 1.22 ServeMux 的模式语法，例如 "GET /api/v1/history"、
+// "DELETE /api/v1/history/{id}"、"/debug/"。同一请求只按最具体的路由模式评估，
+// 未被覆盖的路由与方法（包括模式只约束了其他方法的情况）默认拒绝。
+type Policy struct {
+	roles          map[string][]string
+	mux            *http.ServeMux
+	rules          map[string][]routeRule
+	allowUncovered bool
+}
+
+// NewPolicy 根据角色权限表、权限路由表与公开路由创建策略
+func NewPolicy(opts PolicyOptions) (policy *Policy, err error) {
+	p := &Policy{
+		roles:          make(map[string][]string, len(opts.Roles)),
+		mux:            http.NewServeMux(),
+		rules:          make(map[string][]routeRule),
+		allowUncovered: opts.AllowUncovered,
+	}
+	for role, perms := range opts.Roles {
+		for _, perm := range perms {
+			if perm != PermissionAll {
+				if _, ok := opts.Permissions[perm]; !ok {
+					return nil, fmt.Errorf("role %q references unknown permission %q", role, perm)
+				}
+			}
+		}
+		p.roles[role] = slices.Clone(perms)
+	}
+
+	// ServeMux 在模式非法或冲突时 panic，转换为配置错误
+	defer func() {
+		if r := recover(); r != nil {
+			policy, err = nil, fmt.Errorf("invalid route pattern: %v", r)
+		}
+	}()
+
+	for _, pattern := range opts.Public {
+		if err := p.add("", pattern); err != nil {
+			return nil, fmt.Errorf("public: %w", err)
+		}
+	}
+
+	// 按权限名排序，保证错误信息和 Required 顺序稳定
+	names := make([]string, 0, len(opts.Permissions))
+	for name := range opts.Permissions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, pattern := range opts.Permissions[name] {
+			if err := p.add(name, pattern); err != nil {
+				return nil, fmt.Errorf("permission %q: %w", name, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// add 为路由模式添加一条规则，permission 为空表示公开路由
+func (p *Policy) add(permission, pattern string) error {
+	method, path := splitPattern(pattern)
+	if path == "" || path[0] != '/' {
+		return fmt.Errorf("route pattern %q must start with a path", pattern)
+	}
+	if _, ok := p.rules[path]; !ok {
+		p.mux.Handle(path, http.NotFoundHandler())
+	}
+	rule := routeRule{permission: permission}
+	if method != "" {
+		rule.methods = []string{method}
+		if method == http.MethodGet {
+			rule.methods = append(rule.methods, http.MethodHead)
+		}
+	}
+	p.rules[path] = append(p.rules[path], rule)
+	return nil
+}
+
+// Decide 评估调用方是否可以访问该请求
+func (p *Policy) Decide(r *http.Request, principal *Principal) Decision {
+	_, pattern := p.mux.Handler(r)
+	d := Decision{Pattern: pattern}
+
+	public := false
+	for _, rule := range p.rules[pattern] {
+		if len(rule.methods) > 0 && !slices.Contains(rule.methods, r.Method) {
+			continue
+		}
+		if rule.permission == "" {
+			public = true
+		} else if !slices.Contains(d.Required, rule.permission) {
+			d.Required = append(d.Required, rule.permission)
+		}
+	}
+
+	switch {
+	case public:
+		d.Allowed = true
+	case len(d.Required) == 0:
+		d.Allowed = p.allowUncovered
+	default:
+		d.Allowed = principal != nil && slices.ContainsFunc(d.Required, func(perm string) bool {
+			return p.granted(principal, perm)
+		})
+	}
+	return d
+}
+
+// granted 判断调用方的任一角色是否授予了该权限
+func (p *Policy) granted(principal *Principal, permission string) bool {
+	for _, role := range principal.Roles {
+		perms := p.roles[role]
+		if slices.Contains(perms, PermissionAll) || slices.Contains(perms, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPattern 拆分 "METHOD /path" 形式的模式，方法统一转为大写
+func splitPattern(pattern string) (method, path string) {
+	pattern = strings.TrimSpace(pattern)
+	if m, rest, ok := strings.Cut(pattern, " "); ok {
+		return strings.ToUpper(m), strings.TrimSpace(rest)
+	}
+	return "", pattern
+}
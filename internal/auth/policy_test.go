@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPolicy(t *testing.T) *Policy {
+	t.Helper()
+	p, err := NewPolicy(PolicyOptions{
+		Roles: map[string][]string{
+			"admin":    {PermissionAll},
+			"operator": {"history:read", "history:delete", "metrics"},
+			"viewer":   {"history:read"},
+			"user":     {"calculate"},
+		},
+		Permissions: map[string][]string{
+			"calculate":      {"/api/v1/add", "POST /api/v1/batch"},
+			"history:read":   {"GET /api/v1/history"},
+			"history:delete": {"DELETE /api/v1/history/{id}"},
+			"metrics":        {"/metrics"},
+			"debug":          {"/debug/"},
+		},
+		Public: []string{"GET /{$}", "GET /healthz"},
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestPolicy_Decide(t *testing.T) {
+	policy := testPolicy(t)
+
+	principal := func(roles ...string) *Principal {
+		return &Principal{ID: "test", Roles: roles}
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		principal *Principal
+		allowed   bool
+		pattern   string
+	}{
+		{"PublicRouteAnonymous", http.MethodGet, "/healthz", nil, true, "/healthz"},
+		{"PublicRouteOtherMethod", http.MethodPost, "/healthz", principal("admin"), false, "/healthz"},
+		{"PublicHomeExactOnly", http.MethodGet, "/", nil, true, "/{$}"},
+		// 未被任何模式覆盖的路由默认拒绝，即使调用方拥有全部权限
+		{"UncoveredRouteAnonymous", http.MethodGet, "/api/v1/usage", nil, false, ""},
+		{"UncoveredRouteAdmin", http.MethodGet, "/swagger/index.html", principal("admin"), false, ""},
+		{"ProtectedRouteAnonymous", http.MethodGet, "/metrics", nil, false, "/metrics"},
+		{"MetricsOperator", http.MethodGet, "/metrics", principal("operator"), true, "/metrics"},
+		{"MetricsViewer", http.MethodGet, "/metrics", principal("viewer"), false, "/metrics"},
+		{"MetricsAnyMethod", http.MethodPost, "/metrics", principal("viewer"), false, "/metrics"},
+		{"HistoryReadViewer", http.MethodGet, "/api/v1/history", principal("viewer"), true, "/api/v1/history"},
+		{"HistoryHeadViewer", http.MethodHead, "/api/v1/history", principal("viewer"), true, "/api/v1/history"},
+		{"HistoryReadUser", http.MethodGet, "/api/v1/history", principal("user"), false, "/api/v1/history"},
+		// 模式只约束 GET，其他方法未被覆盖，默认拒绝
+		{"HistoryOtherMethod", http.MethodPost, "/api/v1/history", principal("admin"), false, "/api/v1/history"},
+		{"HistoryDeleteViewer", http.MethodDelete, "/api/v1/history/1", principal("viewer"), false, "/api/v1/history/{id}"},
+		{"HistoryDeleteOperator", http.MethodDelete, "/api/v1/history/1", principal("operator"), true, "/api/v1/history/{id}"},
+		{"HistoryDeleteAdmin", http.MethodDelete, "/api/v1/history/1", principal("admin"), true, "/api/v1/history/{id}"},
+		{"DebugSubtree", http.MethodGet, "/debug/pprof/heap", principal("operator"), false, "/debug/"},
+		{"DebugAdmin", http.MethodGet, "/debug/pprof/heap", principal("admin"), true, "/debug/"},
+		{"DebugSLO", http.MethodGet, "/debug/slo", nil, false, "/debug/"},
+		{"CalculateUser", http.MethodGet, "/api/v1/add", principal("user"), true, "/api/v1/add"},
+		{"BatchUser", http.MethodPost, "/api/v1/batch", principal("user"), true, "/api/v1/batch"},
+		{"BatchViewer", http.MethodPost, "/api/v1/batch", principal("viewer"), false, "/api/v1/batch"},
+		{"MultipleRoles", http.MethodPost, "/api/v1/batch", principal("viewer", "user"), true, "/api/v1/batch"},
+		{"UnknownRole", http.MethodGet, "/metrics", principal("ghost"), false, "/metrics"},
+		{"NoRoles", http.MethodGet, "/metrics", principal(), false, "/metrics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := policy.Decide(httptest.NewRequest(tt.method, tt.path, nil), tt.principal)
+			assert.Equal(t, tt.allowed, d.Allowed)
+			assert.Equal(t, tt.pattern, d.Pattern)
+		})
+	}
+}
+
+func TestPolicy_RequiredIsAnyOf(t *testing.T) {
+	policy, err := NewPolicy(PolicyOptions{
+		Roles:       map[string][]string{"reader": {"read"}, "auditor": {"audit"}},
+		Permissions: map[string][]string{"read": {"GET /reports"}, "audit": {"/reports"}},
+	})
+	require.NoError(t, err)
+
+	d := policy.Decide(httptest.NewRequest(http.MethodGet, "/reports", nil), &Principal{Roles: []string{"auditor"}})
+	assert.True(t, d.Allowed)
+	assert.Equal(t, []string{"audit", "read"}, d.Required)
+
+	d = policy.Decide(httptest.NewRequest(http.MethodPost, "/reports", nil), &Principal{Roles: []string{"reader"}})
+	assert.False(t, d.Allowed)
+	assert.Equal(t, []string{"audit"}, d.Required)
+}
+
+func TestPolicy_AllowUncovered(t *testing.T) {
+	policy, err := NewPolicy(PolicyOptions{
+		Permissions:    map[string][]string{"metrics": {"GET /metrics"}},
+		AllowUncovered: true,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, policy.Decide(httptest.NewRequest(http.MethodGet, "/healthz", nil), nil).Allowed)
+	assert.True(t, policy.Decide(httptest.NewRequest(http.MethodPost, "/metrics", nil), nil).Allowed)
+	assert.False(t, policy.Decide(httptest.NewRequest(http.MethodGet, "/metrics", nil), nil).Allowed)
+}
+
+func TestNewPolicy_Invalid(t *testing.T) {
+	tests := map[string]PolicyOptions{
+		"UnknownPermission": {
+			Roles:       map[string][]string{"admin": {"missing"}},
+			Permissions: map[string][]string{"read": {"/a"}},
+		},
+		"MissingPath": {
+			Permissions: map[string][]string{"read": {"GET"}},
+		},
+		"HostPattern": {
+			Permissions: map[string][]string{"read": {"example.com/a"}},
+		},
+		"InvalidWildcard": {
+			Permissions: map[string][]string{"read": {"/a/{id"}},
+		},
+		"InvalidPublicPattern": {
+			Public: []string{"healthz"},
+		},
+		"ConflictingPatterns": {
+			Permissions: map[string][]string{"read": {"/a/{x}/c"}, "write": {"/a/b/{y}"}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewPolicy(tt)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/exiaohu/go-demo/pkg/errors"
+)
+
+// 认证方式
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// API 密钥 scope
+const (
+	// ScopeCalculate 调用计算接口
+	ScopeCalculate = "calculate"
+	// ScopeReadHistory 读取计算历史
+	ScopeReadHistory = "read-history"
+	// ScopeAdmin 管理权限，隐含其他全部 scope
+	ScopeAdmin = "admin"
+)
+
+// Scopes 全部合法的 scope
+var Scopes = []string{ScopeCalculate, ScopeReadHistory, ScopeAdmin}
+
+// ValidScope 判断 scope 是否合法
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// Principal 已认证的调用方
+type Principal struct {
+	// ID 调用方唯一标识，例如 "apikey:42"
+	ID string `json:"id"`
+	// Name 便于阅读的名称
+	Name string `json:"name"`
+	// Method 认证方式
+	Method string `json:"method"`
+	// Scopes 授予的权限范围
+	Scopes []string `json:"scopes,omitempty"`
+	// Roles 由身份提供方声明的角色，例如 JWT 中的 roles 声明
+	Roles []string `json:"roles,omitempty"`
+}
+
+// HasScope 判断调用方是否具备指定 scope，admin 隐含全部 scope
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// HasRole 判断调用方是否具备指定角色
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	return slices.Contains(p.Roles, role)
+}
+
+// RequireRole 要求 Context 中的调用方至少具备其中一个角色
+// 匿名请求返回 ErrTypeUnauthorized，角色不符返回 ErrTypeForbidden
+func RequireRole(ctx context.Context, roles ...string) error {
+	p := FromContext(ctx)
+	if p == nil {
+		return errors.New(errors.ErrTypeUnauthorized, "Authentication required")
+	}
+	for _, role := range roles {
+		if p.HasRole(role) {
+			return nil
+		}
+	}
+	return errors.NewWithDetails(errors.ErrTypeForbidden, "Insufficient role", strings.Join(roles, ", "))
+}
+
+// Authenticator 从请求中识别调用方
+// 请求未携带该方式的凭证时返回 (nil, nil)，凭证无效时返回 ErrTypeUnauthorized 错误
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Challenger 可选接口，认证方式通过它声明 401 响应中的 WWW-Authenticate 值
+type Challenger interface {
+	Challenge() string
+}
+
+type principalKey struct{}
+
+// NewContext 将调用方注入到 Context
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 从 Context 中获取调用方，匿名请求返回 nil
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
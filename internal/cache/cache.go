@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// Cache 计算结果缓存接口
+// 进程内缓存使用 LRU，外部缓存（如 Redis、Memcached）实现该接口即可接入
+type Cache interface {
+	// Get 查询缓存，未命中时返回 false
+	Get(ctx context.Context, key string) (int, bool, error)
+	// Set 写入缓存，过期策略由实现决定
+	Set(ctx context.Context, key string, value int) error
+}
+
+// Key 生成计算结果的缓存键
+func Key(operation string, a, b int) string {
+	return fmt.Sprintf("%s:%d:%d", operation, a, b)
+}
+
+type bypassKey struct{}
+
+// WithBypass 标记本次请求跳过缓存读取（计算结果仍会写入缓存）
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// IsBypassed 判断本次请求是否跳过缓存读取
+func IsBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     int
+	expiresAt time.Time
+}
+
+// LRU 进程内 LRU 缓存，支持容量上限和 TTL 过期
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+	metrics  *Metrics
+	now      func() time.Time
+}
+
+// NewLRU 创建 LRU 缓存
+// capacity 为最大条目数；ttl <= 0 表示条目永不过期；metrics 可以为 nil
+func NewLRU(capacity int, ttl time.Duration, metrics *Metrics) *LRU {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &LRU{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		metrics:  metrics,
+		now:      time.Now,
+	}
+}
+
+func (c *LRU) Get(_ context.Context, key string) (int, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	entry := elem.Value.(*lruEntry) //nolint:forcetypeassert // list 中只存放 *lruEntry
+	if c.expired(entry) {
+		c.remove(elem, EvictReasonExpired)
+		return 0, false, nil
+	}
+
+	c.ll.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, key string, value int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = c.now().Add(c.ttl)
+	}
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry) //nolint:forcetypeassert // list 中只存放 *lruEntry
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return nil
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back(), EvictReasonCapacity)
+	}
+	return nil
+}
+
+// Len 返回当前条目数（包含尚未被清理的过期条目）
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) expired(entry *lruEntry) bool {
+	return !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt)
+}
+
+func (c *LRU) remove(elem *list.Element, reason string) {
+	entry := c.ll.Remove(elem).(*lruEntry) //nolint:forcetypeassert // list 中只存放 *lruEntry
+	delete(c.items, entry.key)
+	c.metrics.evict(reason)
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU_CapacityEviction(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics(prometheus.NewRegistry())
+	c := NewLRU(2, 0, m)
+
+	require.NoError(t, c.Set(ctx, "a", 1))
+	require.NoError(t, c.Set(ctx, "b", 2))
+
+	// 访问 a 使其成为最近使用，随后插入 c 应淘汰 b
+	_, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+	require.NoError(t, c.Set(ctx, "c", 3))
+
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok)
+	v, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.evictions.WithLabelValues(EvictReasonCapacity)))
+}
+
+func TestLRU_TTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics(prometheus.NewRegistry())
+	c := NewLRU(10, time.Minute, m)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.Set(ctx, "a", 1))
+
+	now = now.Add(59 * time.Second)
+	_, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.evictions.WithLabelValues(EvictReasonExpired)))
+}
+
+func TestWithMetrics(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+	c := WithMetrics(NewLRU(10, 0, m), m)
+
+	_, _, _ = c.Get(ctx, "a")
+	require.NoError(t, c.Set(ctx, "a", 1))
+	_, _, _ = c.Get(ctx, "a")
+	_, _, _ = c.Get(ctx, "a")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.hits))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.misses))
+
+	// 同一 Registerer 上重复创建复用已有指标，不会 panic
+	assert.NotPanics(t, func() {
+		again := NewMetrics(reg)
+		assert.Same(t, m.hits, again.hits)
+	})
+}
+
+func TestBypass(t *testing.T) {
+	ctx := context.Background()
+	assert.False(t, IsBypassed(ctx))
+	assert.True(t, IsBypassed(WithBypass(ctx)))
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/exiaohu/go-demo/pkg/metrics"
+)
+
+// 淘汰原因
+const (
+	EvictReasonCapacity = "capacity"
+	EvictReasonExpired  = "expired"
+)
+
+// Metrics 缓存 Prometheus 指标
+type Metrics struct {
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	evictions *prometheus.CounterVec
+}
+
+// NewMetrics 创建并注册缓存指标
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	return &Metrics{
+		hits: metrics.MustRegister(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "calculation_cache_hits_total",
+			Help: "Total number of calculation cache hits",
+		})),
+		misses: metrics.MustRegister(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "calculation_cache_misses_total",
+			Help: "Total number of calculation cache misses",
+		})),
+		evictions: metrics.MustRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calculation_cache_evictions_total",
+			Help: "Total number of calculation cache evictions",
+		}, []string{"reason"})),
+	}
+}
+
+func (m *Metrics) hit() {
+	if m != nil {
+		m.hits.Inc()
+	}
+}
+
+func (m *Metrics) miss() {
+	if m != nil {
+		m.misses.Inc()
+	}
+}
+
+func (m *Metrics) evict(reason string) {
+	if m != nil {
+		m.evictions.WithLabelValues(reason).Inc()
+	}
+}
+
+// instrumented 为任意 Cache 实现记录命中/未命中指标
+type instrumented struct {
+	Cache
+	metrics *Metrics
+}
+
+// WithMetrics 包装 Cache 以记录命中率指标
+func WithMetrics(c Cache, m *Metrics) Cache {
+	return &instrumented{Cache: c, metrics: m}
+}
+
+func (c *instrumented) Get(ctx context.Context, key string) (int, bool, error) {
+	value, ok, err := c.Cache.Get(ctx, key)
+	if err == nil {
+		if ok {
+			c.metrics.hit()
+		} else {
+			c.metrics.miss()
+		}
+	}
+	return value, ok, err
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/quota"
+	"github.com/exiaohu/go-demo/internal/service"
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+// maxBatchBodyBytes 批量计算请求体的最大字节数
+const maxBatchBodyBytes = 1 << 20
+
+// BatchRequest 批量计算请求
+type BatchRequest struct {
+	Operations []service.BatchOperation `json:"operations"`
+}
+
+// BatchHandler 批量计算
+// @Summary Batch calculation
+// @Description run several operations atomically; history is recorded for all of them or none
+// @Tags math
+// @Accept  json
+// @Produce  json
+// @Param request body BatchRequest true "Operations (add, subtract, multiply, divide)"
+// @Param Cache-Control header string false "no-cache to bypass the result cache"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Param Content-Encoding header string false "gzip, deflate or zstd compressed request body"
+// @Success 200 {object} response.Response{data=[]service.BatchResult} "Results"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 405 {object} response.Response "Method Not Allowed"
+// @Failure 413 {object} response.Response "Request Entity Too Large"
+// @Failure 415 {object} response.Response "Unsupported Content-Encoding"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/batch [post]
+func (h *Handler) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if stderrors.As(err, &maxBytesErr) {
+			response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeRequestTooLarge,
+				"Request body too large", fmt.Sprintf("limit is %d bytes", maxBatchBodyBytes)))
+			return
+		}
+		appErr := errors.NewWithDetails(errors.ErrTypeValidation, "Invalid request body", err.Error())
+		response.Error(w, r, http.StatusBadRequest, appErr.Error())
+		return
+	}
+
+	// 按操作数扣减配额；为空或超出上限的请求由服务层拒绝，不扣减
+	ctx := requestContext(r)
+	if n := len(req.Operations); n > 0 && n <= service.MaxBatchSize {
+		if err := quota.Charge(ctx, int64(n)); err != nil {
+			quotaExceeded(w, r)
+			return
+		}
+	}
+
+	results, err := h.calcService.Batch(ctx, req.Operations, ip.GetClientIP(r))
+	if err != nil {
+		if errors.IsValidationError(err) {
+			response.Error(w, r, http.StatusBadRequest, err.Error())
+		} else {
+			logger.FromContext(r.Context()).Error("Batch calculation failed", zap.Error(err))
+			response.Error(w, r, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	response.Success(w, r, results)
+}
@@ -4,12 +4,17 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 
+	"github.com/exiaohu/go-demo/internal/cache"
+	"github.com/exiaohu/go-demo/internal/quota"
 	"github.com/exiaohu/go-demo/internal/service"
 	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
 	"github.com/exiaohu/go-demo/pkg/response"
 	"github.com/exiaohu/go-demo/pkg/util/ip"
 )
@@ -47,9 +52,13 @@ func (h *Handler) HealthCheckHandler(w http.ResponseWriter, _ *http.Request) {
 // @Produce  json
 // @Param a query int true "First integer"
 // @Param b query int true "Second integer"
+// @Param Cache-Control header string false "no-cache to bypass the result cache"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 200 {string} string "Result"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 500 {string} string "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /add [get]
 func (h *Handler) AddHandler(w http.ResponseWriter, r *http.Request) {
 	h.handleMathRequest(w, r, h.calcService.Add)
@@ -63,9 +72,13 @@ func (h *Handler) AddHandler(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param a query int true "First integer"
 // @Param b query int true "Second integer"
+// @Param Cache-Control header string false "no-cache to bypass the result cache"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 200 {string} string "Result"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 500 {string} string "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /subtract [get]
 func (h *Handler) SubtractHandler(w http.ResponseWriter, r *http.Request) {
 	h.handleMathRequest(w, r, h.calcService.Subtract)
@@ -79,9 +92,13 @@ func (h *Handler) SubtractHandler(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param a query int true "First integer"
 // @Param b query int true "Second integer"
+// @Param Cache-Control header string false "no-cache to bypass the result cache"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 200 {string} string "Result"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 500 {string} string "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /multiply [get]
 func (h *Handler) MultiplyHandler(w http.ResponseWriter, r *http.Request) {
 	h.handleMathRequest(w, r, h.calcService.Multiply)
@@ -95,9 +112,13 @@ func (h *Handler) MultiplyHandler(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param a query int true "First integer"
 // @Param b query int true "Second integer"
+// @Param Cache-Control header string false "no-cache to bypass the result cache"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 200 {string} string "Result"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 500 {string} string "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /divide [get]
 func (h *Handler) DivideHandler(w http.ResponseWriter, r *http.Request) {
 	h.handleMathRequest(w, r, h.calcService.Divide)
@@ -105,7 +126,7 @@ func (h *Handler) DivideHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) handleMathRequest(w http.ResponseWriter, r *http.Request, op func(context.Context, int, int, string) (int, error)) {
 	tr := otel.Tracer("handler")
-	ctx, span := tr.Start(r.Context(), "handleMathRequest")
+	ctx, span := tr.Start(requestContext(r), "handleMathRequest")
 	defer span.End()
 
 	if r.Method != http.MethodGet {
@@ -134,12 +155,19 @@ func (h *Handler) handleMathRequest(w http.ResponseWriter, r *http.Request, op f
 		attribute.Int("b", b),
 	)
 
+	if err := quota.Charge(ctx, 1); err != nil {
+		span.RecordError(err)
+		quotaExceeded(w, r)
+		return
+	}
+
 	result, err := op(ctx, a, b, ip.GetClientIP(r))
 	if err != nil {
 		span.RecordError(err)
 		if errors.IsValidationError(err) {
 			response.Error(w, r, http.StatusBadRequest, err.Error())
 		} else {
+			logger.FromContext(ctx).Error("Calculation failed", zap.Error(err))
 			response.Error(w, r, http.StatusInternalServerError, err.Error())
 		}
 		return
@@ -149,6 +177,24 @@ func (h *Handler) handleMathRequest(w http.ResponseWriter, r *http.Request, op f
 	response.Success(w, r, map[string]int{"result": result})
 }
 
+// quotaExceeded 返回配额用尽的 429 响应，Retry-After 等响应头已由配额中间件设置
+func quotaExceeded(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, r, http.StatusTooManyRequests, "Quota exceeded")
+}
+
+// requestContext 返回请求上下文
+// 请求头 Cache-Control 包含 no-cache 或 no-store 时，标记跳过计算结果缓存
+func requestContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "no-store":
+			return cache.WithBypass(ctx)
+		}
+	}
+	return ctx
+}
+
 func parseIntParam(param string) (int, error) {
 	if param == "" {
 		return 0, errors.New(errors.ErrTypeValidation, "Parameter is required")
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/exiaohu/go-demo/internal/cache"
 	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/internal/service"
 	"github.com/exiaohu/go-demo/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -38,11 +41,21 @@ func (m *MockCalculatorService) Divide(ctx context.Context, a, b int, ip string)
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockCalculatorService) Batch(ctx context.Context, ops []service.BatchOperation, ip string) ([]service.BatchResult, error) {
+	args := m.Called(ctx, ops, ip)
+	return args.Get(0).([]service.BatchResult), args.Error(1)
+}
+
 func (m *MockCalculatorService) GetHistory(ctx context.Context, limit int) ([]model.CalculationHistory, error) {
 	args := m.Called(ctx, limit)
 	return args.Get(0).([]model.CalculationHistory), args.Error(1)
 }
 
+func (m *MockCalculatorService) DeleteHistory(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func (m *MockCalculatorService) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -125,6 +138,33 @@ func TestHistoryHandler(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestDeleteHistoryHandler(t *testing.T) {
+	h, mockService := setupHandler()
+	mockService.On("DeleteHistory", mock.Anything, uint(1)).Return(nil)
+	mockService.On("DeleteHistory", mock.Anything, uint(2)).Return(errors.New(errors.ErrTypeNotFound, "History not found"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /history/{id}", h.DeleteHistoryHandler)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/history/1", http.StatusOK},
+		{"/history/2", http.StatusNotFound},
+		{"/history/abc", http.StatusBadRequest},
+		{"/history/0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, tt.path, nil))
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
 func TestMathHandlers(t *testing.T) {
 	h, mockService := setupHandler()
 
@@ -236,3 +276,85 @@ func TestMathHandlers(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchHandler(t *testing.T) {
+	h, mockService := setupHandler()
+
+	ops := []service.BatchOperation{{Operation: "add", A: 1, B: 2}, {Operation: "divide", A: 8, B: 2}}
+	mockService.On("Batch", mock.Anything, ops, mock.Anything).Return([]service.BatchResult{
+		{Operation: "add", A: 1, B: 2, Result: 3},
+		{Operation: "divide", A: 8, B: 2, Result: 4},
+	}, nil)
+	invalidOps := []service.BatchOperation{{Operation: "divide", A: 1, B: 0}}
+	mockService.On("Batch", mock.Anything, invalidOps, mock.Anything).
+		Return([]service.BatchResult(nil), errors.New(errors.ErrTypeValidation, "Operation failed"))
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Success",
+			method:         "POST",
+			body:           `{"operations":[{"operation":"add","a":1,"b":2},{"operation":"divide","a":8,"b":2}]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Validation error",
+			method:         "POST",
+			body:           `{"operations":[{"operation":"divide","a":1,"b":0}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid JSON",
+			method:         "POST",
+			body:           `{"operations":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Body too large",
+			method:         "POST",
+			body:           `{"operations":[],"padding":"` + strings.Repeat("x", maxBatchBodyBytes) + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Method Not Allowed",
+			method:         "GET",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/batch", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			h.BatchHandler(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestMathHandler_CacheBypass(t *testing.T) {
+	h, mockService := setupHandler()
+
+	bypassed := mock.MatchedBy(func(ctx context.Context) bool { return cache.IsBypassed(ctx) })
+	notBypassed := mock.MatchedBy(func(ctx context.Context) bool { return !cache.IsBypassed(ctx) })
+	mockService.On("Add", bypassed, 1, 2, mock.Anything).Return(3, nil).Twice()
+	mockService.On("Add", notBypassed, 1, 2, mock.Anything).Return(3, nil).Once()
+
+	for _, header := range []string{"no-cache", "max-age=0, No-Store", ""} {
+		req := httptest.NewRequest("GET", "/add?a=1&b=2", nil)
+		if header != "" {
+			req.Header.Set("Cache-Control", header)
+		}
+		rr := httptest.NewRecorder()
+		h.AddHandler(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	mockService.AssertExpectations(t)
+}
@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
 	"github.com/exiaohu/go-demo/pkg/response"
 )
 
@@ -16,6 +19,8 @@ import (
 // @Param limit query int false "Limit number of records"
 // @Success 200 {object} response.Response{data=[]model.CalculationHistory} "History records"
 // @Failure 500 {string} string "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /history [get]
 func (h *Handler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
@@ -28,9 +33,38 @@ func (h *Handler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	history, err := h.calcService.GetHistory(r.Context(), limit)
 	if err != nil {
+		logger.FromContext(r.Context()).Error("Failed to fetch history", zap.Error(err))
 		response.Error(w, r, http.StatusInternalServerError, "Failed to fetch history")
 		return
 	}
 
 	response.Success(w, r, history)
 }
+
+// DeleteHistoryHandler 删除计算历史
+// @Summary Delete a calculation history record
+// @Description soft-delete a history record by id
+// @Tags history
+// @Produce  json
+// @Param id path int true "History ID"
+// @Success 200 {object} response.Response "Deleted"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 404 {object} response.Response "Not Found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/history/{id} [delete]
+func (h *Handler) DeleteHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || id == 0 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid history id")
+		return
+	}
+
+	if err := h.calcService.DeleteHistory(r.Context(), uint(id)); err != nil {
+		response.FromError(w, r, err)
+		return
+	}
+
+	response.Success(w, r, nil)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/slo"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+// SLOReporter 查询各服务等级目标的当前状态
+type SLOReporter interface {
+	Report() (slo.Report, error)
+}
+
+// SLOHandler SLO 状态页
+type SLOHandler struct {
+	tracker SLOReporter
+}
+
+// NewSLOHandler 创建 SLOHandler
+func NewSLOHandler(tracker SLOReporter) *SLOHandler {
+	return &SLOHandler{tracker: tracker}
+}
+
+// SLO 返回各服务等级目标的错误预算剩余比例与各窗口的 burn rate
+// @Summary Get SLO status
+// @Description show remaining error budget and multi-window burn rates for each service level objective
+// @Tags debug
+// @Produce  json
+// @Success 200 {object} response.Response{data=slo.Report} "SLO report"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /debug/slo [get]
+func (h *SLOHandler) SLO(w http.ResponseWriter, r *http.Request) {
+	report, err := h.tracker.Report()
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Failed to compute SLO report", zap.Error(err))
+		response.Error(w, r, http.StatusInternalServerError, "Failed to compute SLO report")
+		return
+	}
+	response.Success(w, r, report)
+}
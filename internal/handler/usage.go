@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/quota"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+// UsageReader 查询调用方的配额使用情况
+type UsageReader interface {
+	Usage(ctx context.Context, subject string) ([]quota.Status, error)
+}
+
+// UsageResponse 配额使用情况
+type UsageResponse struct {
+	Subject string         `json:"subject"`
+	Quotas  []quota.Status `json:"quotas"`
+}
+
+// UsageHandler 配额用量查询
+type UsageHandler struct {
+	quotas UsageReader
+}
+
+// NewUsageHandler 创建 UsageHandler，quotas 为 nil 表示未启用配额
+func NewUsageHandler(quotas UsageReader) *UsageHandler {
+	return &UsageHandler{quotas: quotas}
+}
+
+// Usage 查询当前调用方的配额使用情况
+// @Summary Get quota usage
+// @Description show the caller's consumption for each quota window; anonymous callers are identified by client IP
+// @Tags usage
+// @Produce  json
+// @Success 200 {object} response.Response{data=UsageResponse} "Usage"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/usage [get]
+func (h *UsageHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	resp := UsageResponse{Subject: quota.Subject(r), Quotas: []quota.Status{}}
+	if h.quotas != nil {
+		statuses, err := h.quotas.Usage(r.Context(), resp.Subject)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Failed to fetch usage", zap.String("subject", resp.Subject), zap.Error(err))
+			response.Error(w, r, http.StatusInternalServerError, "Failed to fetch usage")
+			return
+		}
+		if statuses != nil {
+			resp.Quotas = statuses
+		}
+	}
+
+	response.Success(w, r, resp)
+}
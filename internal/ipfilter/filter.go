@@ -0,0 +1,318 @@
+// Package ipfilter 按客户端 IP 的 CIDR 黑白名单过滤请求
+// 名单可以按路由组配置，并支持从文件热加载
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+// defaultReloadInterval 检查名单文件是否变化的默认间隔
+const defaultReloadInterval = 10 * time.Second
+
+// Rules 黑白名单，条目为 CIDR 或单个 IP
+type Rules struct {
+	// Allow、Deny 全局名单，对所有路由生效
+	Allow []string `json:"allow" mapstructure:"allow" yaml:"allow"`
+	Deny  []string `json:"deny"  mapstructure:"deny"  yaml:"deny"`
+	// Groups 按路由组追加的名单
+	Groups []Group `json:"groups" mapstructure:"groups" yaml:"groups"`
+}
+
+// Group 一个路由组的黑白名单
+type Group struct {
+	// Pattern ServeMux 风格的路由模式，例如 "/debug/"、"DELETE /api/v1/history/{id}"
+	Pattern string   `json:"pattern" mapstructure:"pattern" yaml:"pattern"`
+	Allow   []string `json:"allow"   mapstructure:"allow"   yaml:"allow"`
+	Deny    []string `json:"deny"    mapstructure:"deny"    yaml:"deny"`
+}
+
+// Decision 一次过滤的结果
+type Decision struct {
+	// Allowed 是否放行
+	Allowed bool
+	// Pattern 命中的路由组，未命中时为空
+	Pattern string
+	// Reason 拒绝原因
+	Reason string
+}
+
+// 拒绝原因
+const (
+	ReasonDenied     = "deny list"
+	ReasonNotAllowed = "not in allow list"
+)
+
+// Options Filter 的可选配置
+type Options struct {
+	// File 名单文件（YAML 或 JSON，结构同 Rules），内容与静态名单合并
+	File string
+	// ReloadInterval 检查名单文件是否变化的间隔，默认 10 秒
+	ReloadInterval time.Duration
+}
+
+// Filter 按客户端 IP 过滤请求
+//
+// 请求先按全局名单、再按最具体的路由组名单评估：命中任一黑名单即拒绝；
+// 存在白名单时，客户端 IP 必须落在其中。
+// 名单文件变化后自动重新加载，加载失败时保留上一次成功的名单。
+type Filter struct {
+	static Rules
+	opts   Options
+	policy atomic.Pointer[policy]
+
+	// mu 串行化重新加载并保护 fileStamp
+	mu sync.Mutex
+	// fileStamp 上一次加载的名单文件修改时间与大小
+	fileStamp fileStamp
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New 创建过滤器，配置了名单文件时启动后台热加载，使用完毕后需调用 Stop
+func New(static Rules, opts Options) (*Filter, error) {
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = defaultReloadInterval
+	}
+	f := &Filter{
+		static: static,
+		opts:   opts,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+
+	if opts.File == "" {
+		close(f.done)
+		return f, nil
+	}
+	go f.watch()
+	return f, nil
+}
+
+// Decide 评估请求是否放行
+func (f *Filter) Decide(r *http.Request) Decision {
+	return f.policy.Load().decide(r)
+}
+
+// Reload 重新加载静态名单与名单文件
+func (f *Filter) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules := f.static
+	var stamp fileStamp
+	if f.opts.File != "" {
+		info, err := os.Stat(f.opts.File)
+		if err != nil {
+			return fmt.Errorf("ip filter: %w", err)
+		}
+		stamp = fileStamp{modTime: info.ModTime(), size: info.Size()}
+
+		fromFile, err := readRules(f.opts.File)
+		if err != nil {
+			return err
+		}
+		rules = merge(rules, fromFile)
+	}
+
+	p, err := newPolicy(rules)
+	if err != nil {
+		return err
+	}
+	f.policy.Store(p)
+	f.fileStamp = stamp
+	return nil
+}
+
+// Stop 停止后台热加载并等待其退出，可重复调用
+func (f *Filter) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stop)
+	})
+	<-f.done
+}
+
+// watch 定期检查名单文件，修改时间或大小变化时重新加载
+func (f *Filter) watch() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.opts.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(f.opts.File)
+			if err != nil {
+				logger.Error("Failed to stat ip filter file", zap.String("file", f.opts.File), zap.Error(err))
+				continue
+			}
+			f.mu.Lock()
+			unchanged := fileStamp{modTime: info.ModTime(), size: info.Size()} == f.fileStamp
+			f.mu.Unlock()
+			if unchanged {
+				continue
+			}
+			if err := f.Reload(); err != nil {
+				logger.Error("Failed to reload ip filter, keeping previous rules", zap.String("file", f.opts.File), zap.Error(err))
+				continue
+			}
+			logger.Info("IP filter reloaded", zap.String("file", f.opts.File))
+		}
+	}
+}
+
+// readRules 读取名单文件，格式由扩展名决定
+func readRules(file string) (Rules, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return Rules{}, fmt.Errorf("ip filter: read %s: %w", file, err)
+	}
+	var rules Rules
+	if err := v.Unmarshal(&rules); err != nil {
+		return Rules{}, fmt.Errorf("ip filter: decode %s: %w", file, err)
+	}
+	return rules, nil
+}
+
+// merge 合并两份名单，同一路由组的条目合并
+func merge(a, b Rules) Rules {
+	merged := Rules{
+		Allow: append(append([]string(nil), a.Allow...), b.Allow...),
+		Deny:  append(append([]string(nil), a.Deny...), b.Deny...),
+	}
+	index := make(map[string]int)
+	for _, g := range append(append([]Group(nil), a.Groups...), b.Groups...) {
+		if i, ok := index[g.Pattern]; ok {
+			merged.Groups[i].Allow = append(merged.Groups[i].Allow, g.Allow...)
+			merged.Groups[i].Deny = append(merged.Groups[i].Deny, g.Deny...)
+			continue
+		}
+		index[g.Pattern] = len(merged.Groups)
+		merged.Groups = append(merged.Groups, Group{
+			Pattern: g.Pattern,
+			Allow:   append([]string(nil), g.Allow...),
+			Deny:    append([]string(nil), g.Deny...),
+		})
+	}
+	return merged
+}
+
+// lists 编译后的一组黑白名单
+type lists struct {
+	allow, deny trie
+}
+
+func newLists(allow, deny []string) (*lists, error) {
+	l := &lists{}
+	for _, s := range allow {
+		prefix, err := ip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow entry %q: %w", s, err)
+		}
+		l.allow.insert(prefix)
+	}
+	for _, s := range deny {
+		prefix, err := ip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deny entry %q: %w", s, err)
+		}
+		l.deny.insert(prefix)
+	}
+	return l, nil
+}
+
+// check 评估地址，返回拒绝原因，放行时为空；无法解析的地址只能通过没有白名单的名单
+func (l *lists) check(addr netip.Addr, ok bool) string {
+	if ok && l.deny.contains(addr) {
+		return ReasonDenied
+	}
+	if !l.allow.empty() && (!ok || !l.allow.contains(addr)) {
+		return ReasonNotAllowed
+	}
+	return ""
+}
+
+// policy 一次加载得到的不可变名单
+type policy struct {
+	global *lists
+	mux    *http.ServeMux
+	groups map[string]*lists
+}
+
+func newPolicy(rules Rules) (p *policy, err error) {
+	global, err := newLists(rules.Allow, rules.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("ip filter: %w", err)
+	}
+	p = &policy{global: global, mux: http.NewServeMux(), groups: make(map[string]*lists, len(rules.Groups))}
+
+	// ServeMux 在模式非法或冲突时 panic，转换为配置错误
+	defer func() {
+		if r := recover(); r != nil {
+			p, err = nil, fmt.Errorf("ip filter: invalid route pattern: %v", r)
+		}
+	}()
+
+	for _, g := range rules.Groups {
+		if g.Pattern == "" {
+			return nil, errors.New("ip filter: group requires a pattern")
+		}
+		l, err := newLists(g.Allow, g.Deny)
+		if err != nil {
+			return nil, fmt.Errorf("ip filter: group %q: %w", g.Pattern, err)
+		}
+		p.mux.Handle(g.Pattern, http.NotFoundHandler())
+		p.groups[g.Pattern] = l
+	}
+	return p, nil
+}
+
+func (p *policy) decide(r *http.Request) Decision {
+	addr, err := netip.ParseAddr(ip.GetClientIP(r))
+	ok := err == nil
+	if ok {
+		addr = ip.Normalize(addr)
+	}
+
+	if reason := p.global.check(addr, ok); reason != "" {
+		return Decision{Reason: reason}
+	}
+	if len(p.groups) == 0 {
+		return Decision{Allowed: true}
+	}
+	_, pattern := p.mux.Handler(r)
+	group, found := p.groups[pattern]
+	if !found {
+		return Decision{Allowed: true}
+	}
+	if reason := group.check(addr, ok); reason != "" {
+		return Decision{Pattern: pattern, Reason: reason}
+	}
+	return Decision{Allowed: true, Pattern: pattern}
+}
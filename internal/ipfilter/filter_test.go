@@ -0,0 +1,138 @@
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+func init() {
+	_ = logger.Initialize(true)
+}
+
+func request(method, path, clientIP string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	return req.WithContext(ip.NewContext(req.Context(), clientIP))
+}
+
+func TestFilter_Decide(t *testing.T) {
+	f, err := New(Rules{
+		Deny: []string{"198.51.100.0/24"},
+		Groups: []Group{
+			{Pattern: "/debug/", Allow: []string{"10.0.0.0/8", "::1"}},
+			{Pattern: "/debug/pprof/", Allow: []string{"10.1.0.0/16"}},
+			{Pattern: "DELETE /api/v1/history/{id}", Deny: []string{"203.0.113.0/24"}},
+		},
+	}, Options{})
+	require.NoError(t, err)
+	defer f.Stop()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		clientIP string
+		expected Decision
+	}{
+		{"NoGroup", "GET", "/api/v1/add", "203.0.113.7", Decision{Allowed: true}},
+		{"GlobalDeny", "GET", "/api/v1/add", "198.51.100.9", Decision{Reason: ReasonDenied}},
+		{"GlobalDenyBeforeGroup", "GET", "/debug/vars", "198.51.100.9", Decision{Reason: ReasonDenied}},
+		{"GroupAllow", "GET", "/debug/vars", "10.2.3.4", Decision{Allowed: true, Pattern: "/debug/"}},
+		{"GroupAllowIPv6", "GET", "/debug/vars", "::1", Decision{Allowed: true, Pattern: "/debug/"}},
+		{"GroupNotAllowed", "GET", "/debug/vars", "203.0.113.7", Decision{Pattern: "/debug/", Reason: ReasonNotAllowed}},
+		{"MostSpecificGroup", "GET", "/debug/pprof/heap", "10.2.3.4", Decision{Pattern: "/debug/pprof/", Reason: ReasonNotAllowed}},
+		{"MostSpecificGroupAllow", "GET", "/debug/pprof/heap", "10.1.3.4", Decision{Allowed: true, Pattern: "/debug/pprof/"}},
+		{"MethodGroupDeny", "DELETE", "/api/v1/history/1", "203.0.113.7", Decision{Pattern: "DELETE /api/v1/history/{id}", Reason: ReasonDenied}},
+		{"MethodGroupOtherMethod", "GET", "/api/v1/history/1", "203.0.113.7", Decision{Allowed: true}},
+		{"UnparseableNotAllowed", "GET", "/debug/vars", "pipe", Decision{Pattern: "/debug/", Reason: ReasonNotAllowed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, f.Decide(request(tt.method, tt.path, tt.clientIP)))
+		})
+	}
+}
+
+func TestFilter_GlobalAllow(t *testing.T) {
+	f, err := New(Rules{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.9.0.0/16"}}, Options{})
+	require.NoError(t, err)
+	defer f.Stop()
+
+	assert.True(t, f.Decide(request("GET", "/", "10.1.1.1")).Allowed)
+	assert.Equal(t, ReasonDenied, f.Decide(request("GET", "/", "10.9.1.1")).Reason)
+	assert.Equal(t, ReasonNotAllowed, f.Decide(request("GET", "/", "192.0.2.1")).Reason)
+}
+
+func TestFilter_RemoteAddr(t *testing.T) {
+	f, err := New(Rules{Deny: []string{"10.0.0.0/8"}}, Options{})
+	require.NoError(t, err)
+	defer f.Stop()
+
+	// 未经 ClientIP 中间件解析时使用 RemoteAddr，IPv4 映射地址按 IPv4 匹配
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[::ffff:10.0.0.2]:4711"
+	assert.False(t, f.Decide(req).Allowed)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, rules := range map[string]Rules{
+		"Entry":        {Allow: []string{"bogus"}},
+		"GroupEntry":   {Groups: []Group{{Pattern: "/debug/", Deny: []string{"10.0.0.0/33"}}}},
+		"EmptyPattern": {Groups: []Group{{Allow: []string{"10.0.0.0/8"}}}},
+		"BadPattern":   {Groups: []Group{{Pattern: "GET /{", Allow: []string{"10.0.0.0/8"}}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(rules, Options{})
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := New(Rules{}, Options{File: filepath.Join(t.TempDir(), "missing.yaml")})
+	assert.Error(t, err)
+}
+
+func TestFilter_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ipfilter.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+deny: ["192.0.2.0/24"]
+groups:
+  - pattern: "/debug/"
+    allow: ["10.0.0.0/8"]
+`), 0o600))
+
+	// 文件中的路由组与静态名单中的同名路由组合并
+	f, err := New(Rules{Groups: []Group{{Pattern: "/debug/", Allow: []string{"::1"}}}}, Options{File: file, ReloadInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer f.Stop()
+
+	assert.False(t, f.Decide(request("GET", "/", "192.0.2.1")).Allowed)
+	assert.True(t, f.Decide(request("GET", "/debug/vars", "10.0.0.1")).Allowed)
+	assert.True(t, f.Decide(request("GET", "/debug/vars", "::1")).Allowed)
+	assert.False(t, f.Decide(request("GET", "/debug/vars", "203.0.113.7")).Allowed)
+
+	// 修改文件后自动重新加载
+	require.NoError(t, os.WriteFile(file, []byte(`{"deny": ["203.0.113.0/24", "198.51.100.0/24"]}`), 0o600))
+	assert.Eventually(t, func() bool {
+		return !f.Decide(request("GET", "/", "198.51.100.1")).Allowed
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.True(t, f.Decide(request("GET", "/", "192.0.2.1")).Allowed)
+	assert.False(t, f.Decide(request("GET", "/debug/vars", "10.0.0.1")).Allowed, "static group still applies")
+
+	// 加载失败时保留上一次成功的名单
+	require.NoError(t, os.WriteFile(file, []byte(`{"deny": ["not-a-cidr", "192.0.2.0/24"]}`), 0o600))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, f.Decide(request("GET", "/", "198.51.100.1")).Allowed)
+	assert.True(t, f.Decide(request("GET", "/", "192.0.2.1")).Allowed)
+
+	f.Stop()
+	f.Stop()
+}
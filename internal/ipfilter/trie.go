@@ -0,0 +1,73 @@
+package ipfilter
+
+import (
+	"net/netip"
+)
+
+// trie 按位存储 CIDR 的二叉前缀树，IPv4 与 IPv6 各有一棵
+// 查询耗时只与地址长度有关，与范围数量无关，适合数千条以上的黑白名单
+type trie struct {
+	v4, v6 *trieNode
+}
+
+type trieNode struct {
+	child [2]*trieNode
+	// terminal 从根到该节点的路径是一个已插入的前缀
+	terminal bool
+}
+
+// insert 插入前缀，prefix 需要已规范化（IPv4 映射地址已转换为 IPv4 且已按掩码清零）
+func (t *trie) insert(prefix netip.Prefix) {
+	root := &t.v6
+	if prefix.Addr().Is4() {
+		root = &t.v4
+	}
+	if *root == nil {
+		*root = &trieNode{}
+	}
+
+	n := *root
+	bytes := prefix.Addr().AsSlice()
+	for i := range prefix.Bits() {
+		if n.terminal {
+			// 已有更短的前缀覆盖该范围
+			return
+		}
+		b := bit(bytes, i)
+		if n.child[b] == nil {
+			n.child[b] = &trieNode{}
+		}
+		n = n.child[b]
+	}
+	n.terminal = true
+	// 新前缀覆盖了其下的所有更长前缀
+	n.child = [2]*trieNode{}
+}
+
+// contains 判断地址是否落在任一前缀内
+func (t *trie) contains(addr netip.Addr) bool {
+	n := t.v6
+	if addr.Is4() {
+		n = t.v4
+	}
+	bytes := addr.AsSlice()
+	for i := 0; n != nil; i++ {
+		if n.terminal {
+			return true
+		}
+		if i == len(bytes)*8 {
+			return false
+		}
+		n = n.child[bit(bytes, i)]
+	}
+	return false
+}
+
+// empty 判断是否没有插入任何前缀
+func (t *trie) empty() bool {
+	return t.v4 == nil && t.v6 == nil
+}
+
+func bit(bytes []byte, i int) int {
+	return int(bytes[i/8]>>(7-i%8)) & 1
+}
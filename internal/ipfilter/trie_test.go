@@ -0,0 +1,70 @@
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+func newTrie(t *testing.T, entries ...string) *trie {
+	t.Helper()
+	tr := &trie{}
+	for _, s := range entries {
+		prefix, err := ip.ParsePrefix(s)
+		require.NoError(t, err)
+		tr.insert(prefix)
+	}
+	return tr
+}
+
+func TestTrie_Contains(t *testing.T) {
+	tr := newTrie(t, "10.0.0.0/8", "192.168.1.7", "2001:db8::/32", "::ffff:172.16.0.0/108")
+
+	tests := map[string]bool{
+		"10.0.0.1":        true,
+		"10.255.255.255":  true,
+		"11.0.0.1":        false,
+		"192.168.1.7":     true,
+		"192.168.1.8":     false,
+		"172.16.3.4":      true,
+		"172.32.0.1":      false,
+		"2001:db8::1":     true,
+		"2001:db9::1":     false,
+		"::1":             false,
+		"0.0.0.0":         false,
+		"255.255.255.255": false,
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, tr.contains(netip.MustParseAddr(in)), in)
+	}
+}
+
+func TestTrie_Overlapping(t *testing.T) {
+	// 先插入更长前缀，再插入覆盖它的短前缀
+	tr := newTrie(t, "10.1.2.0/24", "10.0.0.0/8", "10.3.0.0/16")
+	assert.True(t, tr.contains(netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, tr.contains(netip.MustParseAddr("10.200.0.1")))
+	assert.False(t, tr.contains(netip.MustParseAddr("11.0.0.1")))
+}
+
+func TestTrie_CatchAll(t *testing.T) {
+	tr := newTrie(t, "0.0.0.0/0")
+	assert.True(t, tr.contains(netip.MustParseAddr("203.0.113.7")))
+	assert.False(t, tr.contains(netip.MustParseAddr("2001:db8::1")))
+	assert.False(t, tr.empty())
+	assert.True(t, (&trie{}).empty())
+}
+
+func TestTrie_ManyRanges(t *testing.T) {
+	tr := &trie{}
+	for i := range 4096 {
+		tr.insert(netip.MustParsePrefix(fmt.Sprintf("100.%d.%d.0/24", i/256, i%256)))
+	}
+	assert.True(t, tr.contains(netip.MustParseAddr("100.15.255.1")))
+	assert.False(t, tr.contains(netip.MustParseAddr("100.16.0.1")))
+}
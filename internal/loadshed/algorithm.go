@@ -0,0 +1,85 @@
+package loadshed
+
+import (
+	"math"
+	"time"
+)
+
+// Algorithm 并发上限的调整算法
+type Algorithm string
+
+// 支持的调整算法
+const (
+	// AlgorithmAIMD 加性增、乘性减：请求成功且延迟低于阈值时上限加一，失败或超时时按比例收缩
+	AlgorithmAIMD Algorithm = "aimd"
+	// AlgorithmGradient 按长期与近期延迟之比（梯度）调整上限，延迟上升时提前收缩
+	AlgorithmGradient Algorithm = "gradient"
+)
+
+// algorithm 根据一次请求的结果计算新的并发上限，由 Limiter 加锁调用
+type algorithm interface {
+	// update rtt 为请求耗时，inflight 为请求开始时的并发数，dropped 表示请求失败
+	update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// aimd 加性增、乘性减
+type aimd struct {
+	timeout time.Duration
+	backoff float64
+}
+
+func (a *aimd) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || rtt > a.timeout {
+		return limit * a.backoff
+	}
+	// 并发远低于上限时说明负载不足，继续增加上限没有意义
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// 梯度算法的平滑参数
+const (
+	// longRTTAlpha 长期延迟的指数移动平均系数，约等于最近 100 个请求
+	longRTTAlpha = 2.0 / 101
+	// shortRTTAlpha 近期延迟的指数移动平均系数，约等于最近 10 个请求
+	shortRTTAlpha = 2.0 / 11
+	// limitSmoothing 新上限的平滑系数，避免单个慢请求导致上限剧烈波动
+	limitSmoothing = 0.2
+	// minGradient 单次调整最多将上限收缩一半
+	minGradient = 0.5
+)
+
+// gradient 比较长期延迟与近期延迟：近期延迟高于长期延迟 × tolerance 时说明请求开始排队，按比例收缩上限；
+// 否则在上限之外留出 sqrt(limit) 的排队余量以探测更高的吞吐
+type gradient struct {
+	tolerance float64
+	longRTT   float64
+	shortRTT  float64
+}
+
+func (g *gradient) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	sample := float64(rtt)
+	if g.longRTT == 0 {
+		g.longRTT, g.shortRTT = sample, sample
+	} else {
+		g.longRTT += longRTTAlpha * (sample - g.longRTT)
+		g.shortRTT += shortRTTAlpha * (sample - g.shortRTT)
+	}
+	// 延迟回落后长期平均值偏高，加速衰减以免上限长期无法收缩
+	if g.longRTT > 2*g.shortRTT {
+		g.longRTT *= 0.95
+	}
+
+	grad := minGradient
+	if !dropped {
+		grad = math.Max(minGradient, math.Min(1, g.tolerance*g.longRTT/math.Max(g.shortRTT, 1)))
+	}
+	// 负载不足且没有排队时保持上限
+	if grad == 1 && float64(inflight)*2 < limit {
+		return limit
+	}
+	next := limit*grad + math.Sqrt(limit)
+	return limit*(1-limitSmoothing) + next*limitSmoothing
+}
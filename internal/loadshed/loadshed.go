@@ -0,0 +1,168 @@
+// Package loadshed 自适应并发限制：根据观测到的请求延迟调整允许同时处理的请求数，
+// 超出上限的请求被直接拒绝，避免全局流量突增时所有请求一起变慢
+package loadshed
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options Limiter 的配置，零值字段使用默认值
+type Options struct {
+	// Algorithm 上限调整算法，默认 AIMD
+	Algorithm Algorithm
+	// InitialLimit、MinLimit、MaxLimit 初始、最小与最大并发上限，默认 100、10、1000
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// Timeout AIMD 的延迟阈值，超过视为过载，默认 1 秒
+	Timeout time.Duration
+	// Backoff AIMD 过载时上限的收缩比例，默认 0.9
+	Backoff float64
+	// Tolerance 梯度算法允许近期延迟超出长期延迟的倍数，默认 1.5
+	Tolerance float64
+	// RetryAfter 建议被拒绝的客户端等待多久后重试，默认 1 秒
+	RetryAfter time.Duration
+	// Exempt 不受并发限制的 ServeMux 路由模式，例如健康检查与监控端点
+	Exempt []string
+	// Metrics 为 nil 时不记录指标
+	Metrics *Metrics
+	// Now 当前时间，用于测量请求耗时，默认 time.Now，测试中可替换为可控时钟
+	Now func() time.Time
+}
+
+// Limiter 自适应并发限制器，可安全地并发使用
+type Limiter struct {
+	alg        algorithm
+	min        float64
+	max        float64
+	retryAfter time.Duration
+	exempt     *http.ServeMux
+	metrics    *Metrics
+	now        func() time.Time
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+}
+
+// New 创建并发限制器
+func New(opts Options) (l *Limiter, err error) {
+	opts.InitialLimit = cmp.Or(opts.InitialLimit, 100)
+	opts.MinLimit = cmp.Or(opts.MinLimit, 10)
+	opts.MaxLimit = cmp.Or(opts.MaxLimit, 1000)
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.MinLimit < 1 || opts.MinLimit > opts.MaxLimit {
+		return nil, fmt.Errorf("load shedding: invalid limits min=%d max=%d", opts.MinLimit, opts.MaxLimit)
+	}
+
+	var alg algorithm
+	switch opts.Algorithm {
+	case AlgorithmAIMD, "":
+		a := &aimd{timeout: cmp.Or(opts.Timeout, time.Second), backoff: cmp.Or(opts.Backoff, 0.9)}
+		if a.backoff <= 0 || a.backoff >= 1 {
+			return nil, fmt.Errorf("load shedding: backoff must be in (0, 1), got %v", a.backoff)
+		}
+		alg = a
+	case AlgorithmGradient:
+		g := &gradient{tolerance: cmp.Or(opts.Tolerance, 1.5)}
+		if g.tolerance < 1 {
+			return nil, fmt.Errorf("load shedding: tolerance must be at least 1, got %v", g.tolerance)
+		}
+		alg = g
+	default:
+		return nil, fmt.Errorf("load shedding: unsupported algorithm %q", opts.Algorithm)
+	}
+
+	l = &Limiter{
+		alg:        alg,
+		min:        float64(opts.MinLimit),
+		max:        float64(opts.MaxLimit),
+		retryAfter: cmp.Or(opts.RetryAfter, time.Second),
+		exempt:     http.NewServeMux(),
+		metrics:    opts.Metrics,
+		now:        opts.Now,
+	}
+	l.limit = l.clamp(float64(opts.InitialLimit))
+	l.metrics.setLimit(int(l.limit))
+
+	// ServeMux 在模式非法或冲突时 panic，转换为配置错误
+	defer func() {
+		if r := recover(); r != nil {
+			l, err = nil, fmt.Errorf("load shedding: invalid exempt pattern: %v", r)
+		}
+	}()
+	for _, pattern := range opts.Exempt {
+		l.exempt.Handle(pattern, http.NotFoundHandler())
+	}
+	return l, nil
+}
+
+// Exempt 判断请求是否不受并发限制
+func (l *Limiter) Exempt(r *http.Request) bool {
+	_, pattern := l.exempt.Handler(r)
+	return pattern != ""
+}
+
+// RetryAfter 返回建议被拒绝的客户端等待的时间
+func (l *Limiter) RetryAfter() time.Duration {
+	return l.retryAfter
+}
+
+// Acquire 尝试占用一个并发名额，超出上限时返回 false
+// 成功时必须调用 release 归还名额，dropped 表示请求失败（如 5xx），会促使上限收缩
+func (l *Limiter) Acquire() (release func(dropped bool), ok bool) {
+	l.mu.Lock()
+	if l.inflight >= int(l.limit) {
+		l.mu.Unlock()
+		l.metrics.shed()
+		return nil, false
+	}
+	l.inflight++
+	inflight := l.inflight
+	l.mu.Unlock()
+	l.metrics.acquire()
+
+	start := l.now()
+	var once sync.Once
+	return func(dropped bool) {
+		once.Do(func() {
+			l.release(l.now().Sub(start), inflight, dropped)
+		})
+	}, true
+}
+
+func (l *Limiter) release(rtt time.Duration, inflight int, dropped bool) {
+	l.mu.Lock()
+	l.inflight--
+	l.limit = l.clamp(l.alg.update(l.limit, rtt, inflight, dropped))
+	limit := int(l.limit)
+	l.mu.Unlock()
+
+	l.metrics.release()
+	l.metrics.setLimit(limit)
+}
+
+// Limit 返回当前并发上限
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight 返回当前正在处理的请求数
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+func (l *Limiter) clamp(limit float64) float64 {
+	return math.Max(l.min, math.Min(l.max, limit))
+}
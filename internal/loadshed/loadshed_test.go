@@ -0,0 +1,171 @@
+package loadshed
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClock 可手动推进的时钟
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestLimiter(t *testing.T, opts Options) (*Limiter, *testClock) {
+	t.Helper()
+	clock := &testClock{t: time.Unix(0, 0)}
+	opts.Now = clock.now
+	l, err := New(opts)
+	require.NoError(t, err)
+	return l, clock
+}
+
+// serve 同时发起 n 个请求，每个耗时 rtt，返回被拒绝的数量
+func serve(l *Limiter, clock *testClock, n int, rtt time.Duration, dropped bool) int {
+	releases := make([]func(bool), 0, n)
+	shed := 0
+	for range n {
+		release, ok := l.Acquire()
+		if !ok {
+			shed++
+			continue
+		}
+		releases = append(releases, release)
+	}
+	clock.advance(rtt)
+	for _, release := range releases {
+		release(dropped)
+	}
+	return shed
+}
+
+func TestLimiter_Shed(t *testing.T) {
+	l, _ := newTestLimiter(t, Options{InitialLimit: 2, MinLimit: 1, MaxLimit: 10})
+
+	r1, ok := l.Acquire()
+	require.True(t, ok)
+	r2, ok := l.Acquire()
+	require.True(t, ok)
+	_, ok = l.Acquire()
+	assert.False(t, ok)
+	assert.Equal(t, 2, l.InFlight())
+
+	// 重复归还只生效一次
+	r1(false)
+	r1(false)
+	assert.Equal(t, 1, l.InFlight())
+	_, ok = l.Acquire()
+	assert.True(t, ok)
+	r2(false)
+}
+
+func TestAIMD(t *testing.T) {
+	l, clock := newTestLimiter(t, Options{InitialLimit: 10, MinLimit: 5, MaxLimit: 20, Timeout: 100 * time.Millisecond, Backoff: 0.5})
+
+	// 请求快速完成且开始时并发不低于上限一半的请求各使上限加一
+	assert.Zero(t, serve(l, clock, 10, 10*time.Millisecond, false))
+	assert.Equal(t, 16, l.Limit())
+	serve(l, clock, 16, 10*time.Millisecond, false)
+	assert.Equal(t, 20, l.Limit(), "capped at max")
+
+	// 负载不足时不增长
+	l2, clock2 := newTestLimiter(t, Options{InitialLimit: 10, MinLimit: 5, MaxLimit: 20})
+	serve(l2, clock2, 2, 10*time.Millisecond, false)
+	assert.Equal(t, 10, l2.Limit())
+
+	// 延迟超过阈值时乘性收缩，不低于下限
+	assert.Equal(t, 0, serve(l, clock, 1, 200*time.Millisecond, false))
+	assert.Equal(t, 10, l.Limit())
+	serve(l, clock, 1, 10*time.Millisecond, true)
+	assert.Equal(t, 5, l.Limit())
+	serve(l, clock, 1, 10*time.Millisecond, true)
+	assert.Equal(t, 5, l.Limit())
+	assert.Equal(t, 5, serve(l, clock, 10, 10*time.Millisecond, false))
+}
+
+func TestGradient(t *testing.T) {
+	l, clock := newTestLimiter(t, Options{Algorithm: AlgorithmGradient, InitialLimit: 20, MinLimit: 5, MaxLimit: 200})
+
+	// 延迟稳定时上限逐步增长
+	for range 20 {
+		serve(l, clock, l.Limit(), 10*time.Millisecond, false)
+	}
+	grown := l.Limit()
+	assert.Greater(t, grown, 20)
+
+	// 延迟上升时上限收缩
+	for range 10 {
+		serve(l, clock, 1, 100*time.Millisecond, false)
+	}
+	assert.Less(t, l.Limit(), grown)
+	assert.GreaterOrEqual(t, l.Limit(), 5)
+
+	// 延迟恢复后上限重新增长
+	shrunk := l.Limit()
+	for range 50 {
+		serve(l, clock, l.Limit(), 10*time.Millisecond, false)
+	}
+	assert.Greater(t, l.Limit(), shrunk)
+}
+
+func TestLimiter_Exempt(t *testing.T) {
+	l, _ := newTestLimiter(t, Options{Exempt: []string{"/healthz", "GET /metrics"}})
+
+	assert.True(t, l.Exempt(httptest.NewRequest("GET", "/healthz", nil)))
+	assert.True(t, l.Exempt(httptest.NewRequest("GET", "/metrics", nil)))
+	assert.False(t, l.Exempt(httptest.NewRequest("POST", "/metrics", nil)))
+	assert.False(t, l.Exempt(httptest.NewRequest("GET", "/api/v1/add", nil)))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"Algorithm": {Algorithm: "vegas"},
+		"Limits":    {MinLimit: 10, MaxLimit: 5},
+		"Backoff":   {Backoff: 1.5},
+		"Tolerance": {Algorithm: AlgorithmGradient, Tolerance: 0.5},
+		"Pattern":   {Exempt: []string{"GET /{"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := NewMetrics(reg)
+	assert.Same(t, metrics.limit, NewMetrics(reg).limit, "reuses registered collectors")
+
+	l, clock := newTestLimiter(t, Options{InitialLimit: 10, MinLimit: 1, Timeout: time.Second, Metrics: metrics})
+	assert.Equal(t, float64(10), testutil.ToFloat64(metrics.limit))
+
+	release, ok := l.Acquire()
+	require.True(t, ok)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.inflight))
+	release(true)
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.inflight))
+	assert.Equal(t, float64(9), testutil.ToFloat64(metrics.limit))
+
+	assert.Equal(t, 1, serve(l, clock, 10, time.Millisecond, false))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.shedded))
+}
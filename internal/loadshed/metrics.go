@@ -0,0 +1,56 @@
+package loadshed
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/exiaohu/go-demo/pkg/metrics"
+)
+
+// Metrics 并发限制 Prometheus 指标
+type Metrics struct {
+	limit    prometheus.Gauge
+	inflight prometheus.Gauge
+	shedded  prometheus.Counter
+}
+
+// NewMetrics 创建并注册并发限制指标
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	return &Metrics{
+		limit: metrics.MustRegister(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_concurrency_limit",
+			Help: "Current adaptive limit of concurrently served HTTP requests",
+		})),
+		inflight: metrics.MustRegister(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_concurrency_in_flight",
+			Help: "Number of HTTP requests currently counted against the concurrency limit",
+		})),
+		shedded: metrics.MustRegister(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_requests_shed_total",
+			Help: "Total number of HTTP requests rejected by load shedding",
+		})),
+	}
+}
+
+func (m *Metrics) setLimit(limit int) {
+	if m != nil {
+		m.limit.Set(float64(limit))
+	}
+}
+
+func (m *Metrics) acquire() {
+	if m != nil {
+		m.inflight.Inc()
+	}
+}
+
+func (m *Metrics) release() {
+	if m != nil {
+		m.inflight.Dec()
+	}
+}
+
+func (m *Metrics) shed() {
+	if m != nil {
+		m.shedded.Inc()
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+// authRealm 401 响应中 WWW-Authenticate 的 realm
+const authRealm = "playground"
+
+type challengesKey struct{}
+
+// Authenticate 认证中间件
+// 依次尝试各认证方式，识别出的调用方放入请求上下文；凭证无效时返回 401。
+// 未携带任何凭证的请求以匿名身份继续，由 RequireScope 等授权中间件决定是否拒绝。
+func Authenticate(authenticators ...auth.Authenticator) func(http.Handler) http.Handler {
+	var challenges []string
+	for _, a := range authenticators {
+		if c, ok := a.(auth.Challenger); ok {
+			challenges = append(challenges, c.Challenge()+` realm="`+authRealm+`"`)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, a := range authenticators {
+				principal, err := a.Authenticate(r)
+				if err != nil {
+					if errors.IsUnauthorizedError(err) {
+						logger.FromContext(r.Context()).Warn("Authentication failed", zap.Error(err))
+					} else {
+						logger.FromContext(r.Context()).Error("Authentication error", zap.Error(err))
+					}
+					unauthorized(w, r, challenges, err)
+					return
+				}
+				if principal != nil {
+					// 之后的请求日志带上调用方标识
+					ctx := logger.With(auth.NewContext(r.Context(), principal), zap.String("principal", principal.ID))
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			}
+			// 记录可用的认证方案，供下游授权中间件返回 401 时提示客户端
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), challengesKey{}, challenges)))
+		})
+	}
+}
+
+// RequireScope 授权中间件，要求调用方已认证且具备指定 scope
+// 匿名请求返回 401，scope 不足返回 403
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.FromContext(r.Context())
+			if principal == nil {
+				challenges, _ := r.Context().Value(challengesKey{}).([]string)
+				unauthorized(w, r, challenges, errors.New(errors.ErrTypeUnauthorized, "Authentication required"))
+				return
+			}
+			if !principal.HasScope(scope) {
+				response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeForbidden, "Insufficient scope", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unauthorized 返回 401 并附带 WWW-Authenticate
+func unauthorized(w http.ResponseWriter, r *http.Request, challenges []string, err error) {
+	if len(challenges) > 0 {
+		w.Header().Set("WWW-Authenticate", strings.Join(challenges, ", "))
+	}
+	response.FromError(w, r, err)
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/pkg/errors"
+)
+
+type stubVerifier map[string]*auth.Principal
+
+func (s stubVerifier) VerifyAPIKey(_ context.Context, key string) (*auth.Principal, error) {
+	if p, ok := s[key]; ok {
+		return p, nil
+	}
+	return nil, errors.New(errors.ErrTypeUnauthorized, "Invalid API key")
+}
+
+func TestAuthenticate(t *testing.T) {
+	verifier := stubVerifier{
+		"calc-key":  {ID: "apikey:1", Method: auth.MethodAPIKey, Scopes: []string{auth.ScopeCalculate}},
+		"admin-key": {ID: "apikey:2", Method: auth.MethodAPIKey, Scopes: []string{auth.ScopeAdmin}},
+	}
+
+	var seen *auth.Principal
+	handler := Authenticate(auth.NewAPIKeyAuthenticator(verifier))(
+		RequireScope(auth.ScopeCalculate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = auth.FromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		})),
+	)
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantID     string
+	}{
+		{"Anonymous", "", "", http.StatusUnauthorized, ""},
+		{"InvalidKey", auth.HeaderAPIKey, "wrong", http.StatusUnauthorized, ""},
+		{"HeaderKey", auth.HeaderAPIKey, "calc-key", http.StatusOK, "apikey:1"},
+		{"AuthorizationScheme", "Authorization", "ApiKey calc-key", http.StatusOK, "apikey:1"},
+		{"AdminImpliesScope", auth.HeaderAPIKey, "admin-key", http.StatusOK, "apikey:2"},
+		{"BearerIgnored", "Authorization", "Bearer calc-key", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest(http.MethodGet, "/add", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+				assert.Contains(t, w.Body.String(), `"code":401`)
+				return
+			}
+			if assert.NotNil(t, seen) {
+				assert.Equal(t, tt.wantID, seen.ID)
+			}
+		})
+	}
+}
+
+func TestRequireScope_Forbidden(t *testing.T) {
+	handler := RequireScope(auth.ScopeReadHistory)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/history", nil)
+	req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{
+		ID:     "apikey:1",
+		Scopes: []string{auth.ScopeCalculate},
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), auth.ScopeReadHistory)
+}
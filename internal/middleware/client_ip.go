@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+// ClientIP 按可信代理配置解析客户端 IP 并注入到 Context，后续的 ip.GetClientIP 直接使用该结果
+// resolver 为 nil 时不解析，ip.GetClientIP 只使用 RemoteAddr
+func ClientIP(resolver *ip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if resolver == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := ip.NewContext(r.Context(), resolver.ClientIP(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
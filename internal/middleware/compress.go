@@ -0,0 +1,392 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 支持的内容编码
+const (
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// DefaultCompressTypes 默认压缩的内容类型，以 "/" 结尾的条目按前缀匹配
+var DefaultCompressTypes = []string{
+	"text/",
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"application/x-ndjson",
+	"image/svg+xml",
+}
+
+// CompressOptions 压缩中间件配置，零值字段使用默认值
+type CompressOptions struct {
+	// Encodings 启用的编码，按服务端偏好排序，客户端 q 值相同时靠前的优先；默认 br、zstd、gzip、deflate
+	Encodings []string
+	// MinSize 响应体小于该字节数时不压缩，默认 1024
+	MinSize int
+	// ContentTypes 压缩的内容类型，默认 DefaultCompressTypes
+	ContentTypes []string
+}
+
+// encoder 各编码的压缩器共同实现的方法
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoderPools 复用压缩器，避免每个响应重新分配压缩窗口
+var encoderPools = map[string]*sync.Pool{
+	EncodingBrotli: {New: func() any {
+		// 动态内容使用中等级别，在压缩率与 CPU 之间折中
+		return brotli.NewWriterLevel(io.Discard, 4)
+	}},
+	EncodingZstd: {New: func() any {
+		// 选项已在 Compress 中校验；仍然失败时返回 nil，响应不压缩
+		enc, err := newZstdEncoder()
+		if err != nil {
+			return nil
+		}
+		return enc
+	}},
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	EncodingDeflate: {New: func() any {
+		// HTTP 的 deflate 编码为 zlib 格式（RFC 1950）
+		return zlib.NewWriter(io.Discard)
+	}},
+}
+
+// newZstdEncoder 创建 zstd 压缩器，窗口不超过 8MB，与浏览器的解码限制一致
+func newZstdEncoder() (*zstd.Encoder, error) {
+	return zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+}
+
+// Compress 返回按 Accept-Encoding 协商编码压缩响应的中间件
+// 只压缩达到最小长度且内容类型匹配的响应；已编码、no-transform、HEAD 请求与无响应体的状态码保持原样。
+// 压缩后的响应删除 Content-Length，强 ETag 转为弱 ETag，Flush 时先输出已压缩的数据以支持流式响应
+func Compress(opts CompressOptions) (func(http.Handler) http.Handler, error) {
+	if opts.Encodings == nil {
+		opts.Encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+	}
+	for _, enc := range opts.Encodings {
+		if _, ok := encoderPools[enc]; !ok {
+			return nil, fmt.Errorf("unsupported compression encoding: %q", enc)
+		}
+	}
+	if slices.Contains(opts.Encodings, EncodingZstd) {
+		// 启动时创建首个 zstd 压缩器，选项错误在此返回而不是在请求中静默失败
+		enc, err := newZstdEncoder()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		encoderPools[EncodingZstd].Put(enc)
+	}
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1024
+	}
+	if opts.ContentTypes == nil {
+		opts.ContentTypes = DefaultCompressTypes
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"), opts.Encodings)
+			cw := &compressWriter{ResponseWriter: w, opts: &opts, encoding: encoding, head: r.Method == http.MethodHead}
+			defer func() {
+				// handler panic 时不结束响应，交给外层 Recovery 写出错误响应，只把压缩器放回池中
+				if rec := recover(); rec != nil {
+					cw.release()
+					panic(rec)
+				}
+			}()
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}, nil
+}
+
+// Gzip 压缩中间件
+// Deprecated: Use Compress instead, which negotiates the encoding and skips small or incompressible responses
+func Gzip(next http.Handler) http.Handler {
+	compress, _ := Compress(CompressOptions{Encodings: []string{EncodingGzip}})
+	return compress(next)
+}
+
+// negotiateEncoding 按 q 值选择客户端接受的编码，q 值相同时按服务端偏好，没有可用编码时返回空字符串
+func negotiateEncoding(header []string, supported []string) string {
+	if len(header) == 0 {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	wildcard := -1.0
+	for _, h := range header {
+		for _, part := range strings.Split(h, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+					v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+					if err != nil || v < 0 || v > 1 {
+						v = 0
+					}
+					q = v
+				}
+			}
+			// x-gzip 为 gzip 的别名（RFC 9110）
+			if name == "x-gzip" {
+				name = EncodingGzip
+			}
+			if name == "*" {
+				wildcard = q
+				continue
+			}
+			accepted[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		q, ok := accepted[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressWriter 缓冲响应的开头部分以决定是否压缩，决定后直接写入压缩器或底层 ResponseWriter
+type compressWriter struct {
+	http.ResponseWriter
+	opts     *CompressOptions
+	encoding string
+	head     bool
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+	// hijacked 连接已被接管，不再写入任何数据
+	hijacked bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	// 1xx 信息响应直接发送，不影响最终响应
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status != 0 {
+		return
+	}
+	cw.status = code
+	if !bodyAllowed(code) || cw.head || !cw.eligibleHeaders() {
+		cw.decide(false)
+		return
+	}
+	// 已知长度不足最小长度时无需缓冲
+	if cl, err := strconv.Atoi(cw.Header().Get("Content-Length")); err == nil && cl < cw.opts.MinSize {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.opts.MinSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush 输出已缓冲与已压缩的数据；尚未决定时立即决定，流式响应不必等到最小长度
+func (cw *compressWriter) Flush() {
+	if cw.hijacked {
+		return
+	}
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		if err := cw.start(len(cw.buf) > 0); err != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack 接管连接，已写出的响应头与数据不再由中间件处理
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil {
+		cw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// start 根据已缓冲的数据决定是否压缩并写出缓冲
+func (cw *compressWriter) start(compress bool) error {
+	if compress && cw.Header().Get("Content-Type") == "" {
+		// 与 net/http 一致，未设置时根据内容推断，推断结果同样用于内容类型过滤
+		cw.Header().Set("Content-Type", http.DetectContentType(cw.buf))
+		compress = cw.eligibleHeaders()
+	}
+	cw.decide(compress)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// decide 设置响应头并写出状态码，之后的数据直接写入压缩器或底层 ResponseWriter
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+	h := cw.Header()
+	if cw.considered() && !slices.ContainsFunc(h.Values("Vary"), func(v string) bool {
+		return strings.Contains(strings.ToLower(v), "accept-encoding")
+	}) {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if compress && cw.encoding != "" {
+		if enc, ok := encoderPools[cw.encoding].Get().(encoder); ok {
+			enc.Reset(cw.ResponseWriter)
+			cw.enc = enc
+			h.Set("Content-Encoding", cw.encoding)
+			h.Del("Content-Length")
+			// 压缩后的字节与原始表示不同，强 ETag 不再成立
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// considered 响应内容是否可能随 Accept-Encoding 变化，需要 Vary 告知缓存
+func (cw *compressWriter) considered() bool {
+	return bodyAllowed(cw.status) && cw.eligibleHeaders()
+}
+
+// eligibleHeaders 根据响应头判断是否可以压缩，Content-Type 未设置时视为可以，待推断后再判断
+func (cw *compressWriter) eligibleHeaders() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || cw.status == http.StatusPartialContent {
+		return false
+	}
+	for _, v := range h.Values("Cache-Control") {
+		if strings.Contains(strings.ToLower(v), "no-transform") {
+			return false
+		}
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(cw.opts.ContentTypes, func(t string) bool {
+		if strings.HasSuffix(t, "/") {
+			return strings.HasPrefix(mediaType, t)
+		}
+		return mediaType == t
+	})
+}
+
+// close 结束响应：不足最小长度的缓冲原样写出并设置 Content-Length，压缩器写出尾部后放回池中
+func (cw *compressWriter) close() {
+	if cw.hijacked {
+		return
+	}
+	if cw.status == 0 {
+		// handler 没有写入任何内容，与 net/http 一致按 200 处理
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		if len(cw.buf) > 0 && !cw.head {
+			cw.Header().Set("Content-Length", strconv.Itoa(len(cw.buf)))
+		}
+		cw.decide(false)
+		_, _ = cw.ResponseWriter.Write(cw.buf)
+		cw.buf = nil
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+	}
+	cw.release()
+}
+
+// release 将压缩器放回池中，不再写出任何数据
+func (cw *compressWriter) release() {
+	if cw.enc == nil {
+		return
+	}
+	cw.enc.Reset(io.Discard)
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+}
+
+// bodyAllowed 状态码是否允许响应体
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
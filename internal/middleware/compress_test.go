@@ -0,0 +1,305 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+	tests := []struct {
+		name     string
+		header   []string
+		expected string
+	}{
+		{"Absent", nil, ""},
+		{"Single", []string{"gzip"}, "gzip"},
+		{"ServerPreference", []string{"gzip, deflate, br, zstd"}, "br"},
+		{"QValues", []string{"br;q=0.5, gzip;q=0.8, zstd;q=0.1"}, "gzip"},
+		{"QZeroExcludes", []string{"br;q=0, gzip"}, "gzip"},
+		{"Wildcard", []string{"*"}, "br"},
+		{"WildcardExcludesListed", []string{"br;q=0, *;q=0.5"}, "zstd"},
+		{"WildcardLowerThanListed", []string{"*;q=0.1, deflate"}, "deflate"},
+		{"IdentityOnly", []string{"identity"}, ""},
+		{"Unsupported", []string{"compress, sdch"}, ""},
+		{"CaseAndSpaces", []string{" GZIP ; Q=0.9 "}, "gzip"},
+		{"XGzipAlias", []string{"x-gzip"}, "gzip"},
+		{"InvalidQ", []string{"br;q=abc, gzip"}, "gzip"},
+		{"MultipleHeaders", []string{"gzip;q=0.5", "zstd"}, "zstd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, negotiateEncoding(tt.header, supported))
+		})
+	}
+	assert.Equal(t, "gzip", negotiateEncoding([]string{"br, gzip"}, []string{EncodingGzip}))
+}
+
+func newCompress(t *testing.T, opts CompressOptions, h http.HandlerFunc) http.Handler {
+	t.Helper()
+	compress, err := Compress(opts)
+	require.NoError(t, err)
+	return compress(h)
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch encoding {
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		var dec *zstd.Decoder
+		dec, err = zstd.NewReader(bytes.NewReader(body))
+		r = dec
+	case EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingDeflate:
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestCompress_Encodings(t *testing.T) {
+	body := strings.Repeat(`{"result":42}`, 200)
+	handler := newCompress(t, CompressOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Length", "2600")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, body)
+	})
+
+	for _, enc := range []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate} {
+		t.Run(enc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", enc)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, enc, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			assert.Empty(t, w.Header().Get("Content-Length"))
+			assert.Equal(t, `W/"v1"`, w.Header().Get("ETag"))
+			assert.Less(t, w.Body.Len(), len(body))
+			assert.Equal(t, body, decode(t, enc, w.Body.Bytes()))
+		})
+	}
+
+	// 复用池中的压缩器
+	for range 3 {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "zstd")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, body, decode(t, EncodingZstd, w.Body.Bytes()))
+	}
+}
+
+func TestCompress_Skip(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+	png := "\x89PNG\r\n\x1a\n" + large
+	tests := []struct {
+		name     string
+		method   string
+		handler  http.HandlerFunc
+		encoding string
+		body     string
+		vary     bool
+		length   string
+	}{
+		{
+			name: "BelowMinSize",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = io.WriteString(w, "small")
+			},
+			body:   "small",
+			vary:   true,
+			length: "5",
+		},
+		{
+			name: "ContentType",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = io.WriteString(w, large)
+			},
+			body: large,
+		},
+		{
+			name: "SniffedContentType",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, png)
+			},
+			body: png,
+		},
+		{
+			name: "AlreadyEncoded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = io.WriteString(w, large)
+			},
+			encoding: "gzip",
+			body:     large,
+		},
+		{
+			name: "NoTransform",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Cache-Control", "public, no-transform")
+				_, _ = io.WriteString(w, large)
+			},
+			body: large,
+		},
+		{
+			name: "NoContent",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+		{
+			name: "NotModified",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusNotModified)
+			},
+		},
+		{
+			name: "PartialContent",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = io.WriteString(w, large)
+			},
+			body: large,
+		},
+		{
+			name:   "Head",
+			method: http.MethodHead,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Length", "2048")
+			},
+			vary:   true,
+			length: "2048",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(cmp.Or(tt.method, http.MethodGet), "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			newCompress(t, CompressOptions{}, tt.handler).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.encoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.body, w.Body.String())
+			assert.Equal(t, tt.vary, w.Header().Get("Vary") != "")
+			assert.Equal(t, tt.length, w.Header().Get("Content-Length"))
+		})
+	}
+}
+
+func TestCompress_Flush(t *testing.T) {
+	var flushed []string
+	handler := newCompress(t, CompressOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		cw, ok := w.(*compressWriter)
+		require.True(t, ok)
+		rec, ok := cw.ResponseWriter.(*httptest.ResponseRecorder)
+		require.True(t, ok)
+		for _, line := range []string{`{"n":1}`, `{"n":2}`} {
+			_, _ = io.WriteString(w, line+"\n")
+			// 流式响应不必等到最小长度，Flush 后客户端即可解出已写入的内容
+			require.NoError(t, http.NewResponseController(w).Flush())
+			assert.True(t, rec.Flushed)
+			r, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+			require.NoError(t, err)
+			out, _ := io.ReadAll(r)
+			flushed = append(flushed, string(out))
+		}
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, []string{"{\"n\":1}\n", "{\"n\":1}\n{\"n\":2}\n"}, flushed)
+	assert.Equal(t, "{\"n\":1}\n{\"n\":2}\n", decode(t, EncodingGzip, w.Body.Bytes()))
+}
+
+func TestCompress_Hijack(t *testing.T) {
+	// 经过 Metrics 等包装后仍可通过 ResponseController 接管连接
+	handler := Metrics(newCompress(t, CompressOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = rw.Flush()
+	}))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(bufio.NewReader(resp.Body))
+	require.NoError(t, err)
+	assert.Equal(t, "hijacked", string(body))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+}
+
+func TestCompress_InvalidEncoding(t *testing.T) {
+	_, err := Compress(CompressOptions{Encodings: []string{"lzma"}})
+	assert.Error(t, err)
+}
+
+func TestCompress_PanicReleasesEncoder(t *testing.T) {
+	body := strings.Repeat(`{"result":42}`, 200)
+	handler := newCompress(t, CompressOptions{Encodings: []string{EncodingZstd}}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+	})
+
+	// 已开始压缩后 panic 继续向上抛出，交给 Recovery 处理
+	req := httptest.NewRequest("GET", "/panic", nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	assert.PanicsWithValue(t, "boom", func() { handler.ServeHTTP(httptest.NewRecorder(), req) })
+
+	// 放回池中的压缩器已重置，后续响应不受影响
+	for range 3 {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "zstd")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, body, decode(t, EncodingZstd, w.Body.Bytes()))
+	}
+}
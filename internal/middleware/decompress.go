@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+// DecompressOptions 请求体解压中间件配置，零值字段使用默认值
+type DecompressOptions struct {
+	// Encodings 接受的请求体编码，默认 gzip、deflate、zstd
+	Encodings []string
+	// MaxSize 解压后请求体的最大字节数，默认 1MB
+	MaxSize int64
+}
+
+// errDecompressedTooLarge 解压后的请求体超出限制
+var errDecompressedTooLarge = stderrors.New("decompressed body too large")
+
+// Decompress 返回解压请求体的中间件
+// 按 Content-Encoding 逐层解码，解码后的请求体替换原请求体并删除 Content-Encoding；
+// 解压后超出 MaxSize（防御压缩炸弹）返回 413，不支持的编码返回 415 并在 Accept-Encoding 中列出支持的编码，
+// 无法解码的请求体返回 400
+func Decompress(opts DecompressOptions) (func(http.Handler) http.Handler, error) {
+	if opts.Encodings == nil {
+		opts.Encodings = []string{EncodingGzip, EncodingDeflate, EncodingZstd}
+	}
+	for _, enc := range opts.Encodings {
+		if _, ok := decoders[enc]; !ok {
+			return nil, fmt.Errorf("unsupported request decompression encoding: %q", enc)
+		}
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 1 << 20
+	}
+	accepted := strings.Join(opts.Encodings, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encodings := contentEncodings(r.Header.Values("Content-Encoding"))
+			if len(encodings) == 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, enc := range encodings {
+				if !slices.Contains(opts.Encodings, enc) {
+					w.Header().Set("Accept-Encoding", accepted)
+					response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeUnsupportedMediaType,
+						"Unsupported Content-Encoding", enc))
+					return
+				}
+			}
+
+			body, err := decodeBody(http.MaxBytesReader(w, r.Body, opts.MaxSize), encodings, opts.MaxSize)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if stderrors.Is(err, errDecompressedTooLarge) || stderrors.As(err, &maxBytesErr) {
+					response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeRequestTooLarge,
+						"Request body too large", fmt.Sprintf("limit is %d bytes", opts.MaxSize)))
+					return
+				}
+				response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeValidation,
+					"Invalid compressed request body", err.Error()))
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Del("Content-Encoding")
+			r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// decoders 各编码的解码器，返回的 ReadCloser 关闭时释放解码器资源
+var decoders = map[string]func(r io.Reader) (io.ReadCloser, error){
+	EncodingGzip: func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	EncodingDeflate: func(r io.Reader) (io.ReadCloser, error) {
+		// HTTP 的 deflate 编码为 zlib 格式（RFC 1950）
+		return zlib.NewReader(r)
+	},
+	EncodingZstd: func(r io.Reader) (io.ReadCloser, error) {
+		// 限制解码窗口，避免帧头声明的超大窗口占用内存
+		dec, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxWindow(8<<20),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	},
+}
+
+// decodeBody 按与编码相反的顺序逐层解码，解码结果超出 maxSize 时返回 errDecompressedTooLarge
+func decodeBody(body io.Reader, encodings []string, maxSize int64) ([]byte, error) {
+	r := body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		dec, err := decoders[encodings[i]](r)
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		r = dec
+	}
+
+	decoded, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(decoded)) > maxSize {
+		return nil, errDecompressedTooLarge
+	}
+	return decoded, nil
+}
+
+// contentEncodings 解析 Content-Encoding，按应用顺序返回，忽略 identity
+func contentEncodings(values []string) []string {
+	var encodings []string
+	for _, v := range values {
+		for _, enc := range strings.Split(v, ",") {
+			enc = strings.ToLower(strings.TrimSpace(enc))
+			if enc == "x-gzip" {
+				enc = EncodingGzip
+			}
+			if enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	return encodings
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(&buf)
+	case EncodingDeflate:
+		w = zlib.NewWriter(&buf)
+	case EncodingZstd:
+		enc, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		w = enc
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	decompress, err := Decompress(DecompressOptions{MaxSize: 64 << 10})
+	require.NoError(t, err)
+
+	var got string
+	var gotHeader http.Header
+	var gotLength int64
+	handler := decompress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		got, gotHeader, gotLength = string(body), r.Header, r.ContentLength
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(body []byte, encoding ...string) *httptest.ResponseRecorder {
+		got = ""
+		req := httptest.NewRequest("POST", "/api/v1/batch", bytes.NewReader(body))
+		for _, enc := range encoding {
+			req.Header.Add("Content-Encoding", enc)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	payload := []byte(`{"operations":[{"op":"add","a":1,"b":2}]}`)
+
+	for _, enc := range []string{EncodingGzip, EncodingDeflate, EncodingZstd} {
+		t.Run(enc, func(t *testing.T) {
+			w := send(encode(t, enc, payload), enc)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, string(payload), got)
+			assert.Empty(t, gotHeader.Get("Content-Encoding"))
+			assert.Equal(t, int64(len(payload)), gotLength)
+		})
+	}
+
+	t.Run("Identity", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send(payload).Code)
+		assert.Equal(t, string(payload), got)
+		assert.Equal(t, http.StatusOK, send(payload, "identity").Code)
+		assert.Equal(t, string(payload), got)
+	})
+
+	t.Run("Layered", func(t *testing.T) {
+		// Content-Encoding 按应用顺序列出，先 gzip 后 zstd
+		w := send(encode(t, EncodingZstd, encode(t, EncodingGzip, payload)), "gzip, zstd")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, string(payload), got)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		w := send(payload, "br")
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Equal(t, "gzip, deflate, zstd", w.Header().Get("Accept-Encoding"))
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, float64(http.StatusUnsupportedMediaType), resp["code"])
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, send(payload, "gzip").Code)
+		truncated := encode(t, EncodingGzip, payload)
+		assert.Equal(t, http.StatusBadRequest, send(truncated[:len(truncated)-4], "gzip").Code)
+	})
+
+	// 压缩炸弹：压缩后很小，解压后远超限制
+	bomb := []byte(strings.Repeat("0", 10<<20))
+	for _, enc := range []string{EncodingGzip, EncodingDeflate, EncodingZstd} {
+		t.Run("Bomb/"+enc, func(t *testing.T) {
+			compressed := encode(t, enc, bomb)
+			require.Less(t, len(compressed), 64<<10)
+			w := send(compressed, enc)
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+			assert.Contains(t, w.Body.String(), "Request body too large")
+			assert.Empty(t, got, "handler is not called")
+		})
+	}
+
+	t.Run("CompressedTooLarge", func(t *testing.T) {
+		// 压缩后的请求体本身超出限制
+		random := make([]byte, 128<<10)
+		_, _ = rand.Read(random)
+		w := send(encode(t, EncodingGzip, random), "gzip")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	_, err = Decompress(DecompressOptions{Encodings: []string{"br"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,328 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+const (
+	// HeaderIdempotencyKey 客户端提供的幂等键
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed 标记响应是重放的结果
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodyBytes  = 1 << 20
+	idempotencyPollInterval = 20 * time.Millisecond
+)
+
+// idempotencyVolatileHeaders 每次请求各自计算的响应头，既不保存也不重放
+var idempotencyVolatileHeaders = []string{
+	HeaderXRequestID,
+	HeaderRateLimitLimit,
+	HeaderRateLimitRemaining,
+	HeaderRateLimitReset,
+	HeaderRateLimitPolicy,
+	"Retry-After",
+}
+
+// isVolatileHeader 判断响应头是否为每次请求各自计算的响应头，key 为规范化后的名称
+func isVolatileHeader(key string) bool {
+	return slices.ContainsFunc(idempotencyVolatileHeaders, func(h string) bool {
+		return http.CanonicalHeaderKey(h) == key
+	})
+}
+
+// IdempotentResponse 保存的首次响应
+type IdempotentResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyRecord 幂等键对应的记录
+// Response 为 nil 表示首个请求仍在处理中
+type IdempotencyRecord struct {
+	Fingerprint string
+	Response    *IdempotentResponse
+}
+
+// IdempotencyStore 幂等记录存储
+type IdempotencyStore interface {
+	// Acquire 尝试占用 key。占用成功返回 nil，调用方负责处理请求并调用 Complete 或 Release；
+	// key 已存在时返回已有记录
+	Acquire(ctx context.Context, key, fingerprint string) (*IdempotencyRecord, error)
+	// Complete 保存首次响应，供后续重试重放
+	Complete(ctx context.Context, key string, resp *IdempotentResponse) error
+	// Release 放弃占用（例如处理失败且允许重试），之后相同 key 的请求会重新执行
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyOptions 幂等中间件选项
+type IdempotencyOptions struct {
+	// WaitTimeout 并发的重复请求等待首个请求完成的最长时间，为 0 时立即返回 409
+	WaitTimeout time.Duration
+}
+
+// Idempotency 幂等中间件
+// 携带 Idempotency-Key 的请求首次执行后保存响应（状态码、响应头、响应体），
+// 相同 key 的重试直接重放该响应；同一 key 对应的请求内容不同则返回 422，
+// 首个请求仍在处理时，重复请求等待其完成或返回 409。
+// 只保存内层处理器设置的响应头，CORS、限流、配额等外层中间件的响应头按本次请求生成。
+// 5xx 和 429 响应不会被保存，客户端可以使用同一 key 重试。
+func Idempotency(store IdempotencyStore, opts IdempotencyOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				response.Error(w, r, http.StatusBadRequest, "Idempotency-Key is too long")
+				return
+			}
+			// 已认证的调用方各自拥有独立的 key 空间，避免重放他人的响应
+			if principal := auth.FromContext(r.Context()); principal != nil {
+				key = principal.ID + ":" + key
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if stderrors.As(err, &maxBytesErr) {
+					response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeRequestTooLarge,
+						"Request body too large", fmt.Sprintf("limit is %d bytes", maxIdempotentBodyBytes)))
+					return
+				}
+				response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeValidation,
+					"Failed to read request body", err.Error()))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+
+			record, err := acquireIdempotencyKey(r.Context(), store, key, fingerprint, opts.WaitTimeout)
+			if err != nil {
+				logger.FromContext(r.Context()).Error("Failed to acquire idempotency key", zap.String("key", key), zap.Error(err))
+				response.Error(w, r, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+
+			if record != nil {
+				switch {
+				case record.Fingerprint != fingerprint:
+					response.Error(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was used with a different request")
+				case record.Response == nil:
+					response.Error(w, r, http.StatusConflict, "A request with the same Idempotency-Key is in progress")
+				default:
+					replayResponse(w, record.Response)
+				}
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK, outer: w.Header().Clone()}
+			completed := false
+			defer func() {
+				// handler panic 或响应不可缓存时释放 key，允许重试
+				if !completed {
+					if err := store.Release(context.WithoutCancel(r.Context()), key); err != nil {
+						logger.FromContext(r.Context()).Error("Failed to release idempotency key", zap.String("key", key), zap.Error(err))
+					}
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests {
+				return
+			}
+			resp := &IdempotentResponse{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+			if resp.Header == nil {
+				resp.Header = rec.handlerHeader()
+			}
+			if err := store.Complete(context.WithoutCancel(r.Context()), key, resp); err != nil {
+				logger.FromContext(r.Context()).Error("Failed to store idempotent response", zap.String("key", key), zap.Error(err))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// acquireIdempotencyKey 占用 key；首个请求仍在处理时，在 waitTimeout 内轮询等待其完成
+func acquireIdempotencyKey(
+	ctx context.Context,
+	store IdempotencyStore,
+	key, fingerprint string,
+	waitTimeout time.Duration,
+) (*IdempotencyRecord, error) {
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		record, err := store.Acquire(ctx, key, fingerprint)
+		if err != nil || record == nil || record.Response != nil || record.Fingerprint != fingerprint {
+			return record, err
+		}
+		if !time.Now().Before(deadline) {
+			return record, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return record, nil
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// requestFingerprint 计算请求指纹，用于识别同一 key 下的不同请求
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayResponse 重放保存的响应，每次请求各自计算的响应头保留本次请求的值
+func replayResponse(w http.ResponseWriter, resp *IdempotentResponse) {
+	for k, v := range resp.Header {
+		if isVolatileHeader(k) {
+			continue
+		}
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.Header().Set(HeaderIdempotentReplayed, "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// idempotencyRecorder 在写出响应的同时保存一份副本
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	// outer 调用内层处理器前外层中间件已设置的响应头
+	outer       http.Header
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = code
+	rec.header = rec.handlerHeader()
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// handlerHeader 返回内层处理器新增或修改的响应头，不含每次请求各自计算的响应头
+func (rec *idempotencyRecorder) handlerHeader() http.Header {
+	header := make(http.Header)
+	for k, v := range rec.ResponseWriter.Header() {
+		if isVolatileHeader(k) || slices.Equal(rec.outer[k], v) {
+			continue
+		}
+		header[k] = append([]string(nil), v...)
+	}
+	return header
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+type idempotencyEntry struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+// MemoryIdempotencyStore 进程内幂等记录存储
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	ttl       time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryIdempotencyStore 创建进程内幂等记录存储，记录在 ttl 后过期
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryIdempotencyStore) Acquire(_ context.Context, key, fingerprint string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expiresAt) {
+		record := e.record
+		return &record, nil
+	}
+
+	s.entries[key] = &idempotencyEntry{
+		record:    IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(s.ttl),
+	}
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, key string, resp *IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.record.Response = resp
+		e.expiresAt = s.now().Add(s.ttl)
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep 定期清理过期记录，调用方需持有锁
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func idempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	return req
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	var calls atomic.Int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Call", strings.Repeat("x", int(n)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	handler := Idempotency(NewMemoryIdempotencyStore(time.Hour), IdempotencyOptions{})(next)
+
+	w1 := httptest.NewRecorder()
+	handler.ServeHTTP(w1, idempotentRequest("key-1", `{"a":1}`))
+	assert.Equal(t, http.StatusCreated, w1.Code)
+	assert.Empty(t, w1.Header().Get(HeaderIdempotentReplayed))
+
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, idempotentRequest("key-1", `{"a":1}`))
+	assert.Equal(t, http.StatusCreated, w2.Code)
+	assert.Equal(t, `{"a":1}`, w2.Body.String())
+	assert.Equal(t, "x", w2.Header().Get("X-Call"))
+	assert.Equal(t, "true", w2.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// 没有 Idempotency-Key 的请求不受影响
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", `{"a":1}`))
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", `{"a":1}`))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestIdempotency_ReplaysOnlyHandlerHeaders(t *testing.T) {
+	var calls atomic.Int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set(HeaderRateLimitRemaining, strconv.Itoa(10-int(n)))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/v1/history/1")
+		w.WriteHeader(http.StatusCreated)
+	})
+	// 外层中间件按请求来源设置 CORS 响应头
+	cors := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+			w.Header().Set("Vary", "Origin")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := cors(Idempotency(NewMemoryIdempotencyStore(time.Hour), IdempotencyOptions{})(next))
+
+	do := func(origin string) *httptest.ResponseRecorder {
+		req := idempotentRequest("key-1", `{"a":1}`)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("https://a.example")
+	assert.Equal(t, "9", first.Header().Get(HeaderRateLimitRemaining))
+
+	replayed := do("https://b.example")
+	assert.Equal(t, "true", replayed.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	assert.Equal(t, "/api/v1/history/1", replayed.Header().Get("Location"))
+	assert.Equal(t, "https://b.example", replayed.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, []string{"Origin"}, replayed.Header().Values("Vary"))
+	assert.Empty(t, replayed.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_MismatchedRequest(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Idempotency(NewMemoryIdempotencyStore(time.Hour), IdempotencyOptions{})(next)
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{"a":1}`))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest("key-1", `{"a":2}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+// errReader 读取时返回错误的请求体
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestIdempotency_BodyErrors(t *testing.T) {
+	var calls atomic.Int32
+	handler := Idempotency(NewMemoryIdempotencyStore(time.Hour), IdempotencyOptions{})(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest("key-1", strings.Repeat("x", maxIdempotentBodyBytes+1)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// 读取失败（例如客户端中断）不是请求体过大
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", errReader{})
+	req.Header.Set(HeaderIdempotencyKey, "key-2")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Zero(t, calls.Load())
+}
+
+func TestIdempotency_ConcurrentDuplicates(t *testing.T) {
+	tests := []struct {
+		name         string
+		waitTimeout  time.Duration
+		expectedCode int
+	}{
+		{name: "Conflict without waiting", waitTimeout: 0, expectedCode: http.StatusConflict},
+		{name: "Wait and replay", waitTimeout: 5 * time.Second, expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			var calls atomic.Int32
+			next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				calls.Add(1)
+				close(started)
+				<-release
+				w.Write([]byte("done"))
+			})
+			handler := Idempotency(NewMemoryIdempotencyStore(time.Hour), IdempotencyOptions{WaitTimeout: tt.waitTimeout})(next)
+
+			first := make(chan struct{})
+			go func() {
+				defer close(first)
+				handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", "body"))
+			}()
+			<-started
+
+			if tt.waitTimeout > 0 {
+				// 首个请求稍后完成，重复请求应等待并重放其响应
+				time.AfterFunc(50*time.Millisecond, func() { close(release) })
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, idempotentRequest("key-1", "body"))
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, "done", w.Body.String())
+			} else {
+				close(release)
+			}
+
+			<-first
+			assert.Equal(t, int32(1), calls.Load())
+		})
+	}
+}
+
+func TestIdempotency_RetryableResponsesNotStored(t *testing.T) {
+	var calls atomic.Int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		if calls.Load() == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Recovery(Idempotency(NewMemoryIdempotencyStore(time.Hour), IdempotencyOptions{})(next))
+
+	codes := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, idempotentRequest("key-1", "body"))
+		codes = append(codes, w.Code)
+	}
+
+	// panic 与 503 都不会被保存，直到第一次成功响应后才开始重放
+	assert.Equal(t, []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK, http.StatusOK}, codes)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestMemoryIdempotencyStore_TTL(t *testing.T) {
+	store := NewMemoryIdempotencyStore(time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	record, err := store.Acquire(t.Context(), "key-1", "fp")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+	assert.NoError(t, store.Complete(t.Context(), "key-1", &IdempotentResponse{Status: http.StatusOK}))
+
+	record, err = store.Acquire(t.Context(), "key-1", "fp")
+	assert.NoError(t, err)
+	assert.NotNil(t, record)
+
+	// 过期后 key 可以重新使用
+	now = now.Add(time.Minute)
+	record, err = store.Acquire(t.Context(), "key-1", "fp")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/ipfilter"
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+// IPFilter 返回按客户端 IP 黑白名单过滤请求的中间件，被拒绝的请求返回 403
+// filter 为 nil 时表示未启用，请求直接透传
+func IPFilter(filter *ipfilter.Filter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if filter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := filter.Decide(r)
+			if !d.Allowed {
+				logger.FromContext(r.Context()).Warn("Blocked by ip filter",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("pattern", d.Pattern),
+					zap.String("reason", d.Reason),
+				)
+				response.FromError(w, r, errors.New(errors.ErrTypeForbidden, "Access denied"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
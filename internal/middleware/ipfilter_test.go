@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/ipfilter"
+)
+
+func TestIPFilter(t *testing.T) {
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// 未启用时请求直接透传
+	req := httptest.NewRequest("GET", "/debug/vars", nil)
+	req.RemoteAddr = "203.0.113.7:4711"
+	w := httptest.NewRecorder()
+	IPFilter(nil)(nextHandler).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	filter, err := ipfilter.New(ipfilter.Rules{
+		Groups: []ipfilter.Group{{Pattern: "/debug/", Allow: []string{"10.0.0.0/8"}}},
+	}, ipfilter.Options{})
+	require.NoError(t, err)
+	defer filter.Stop()
+	handler := IPFilter(filter)(nextHandler)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(http.StatusForbidden), body["code"])
+
+	req.RemoteAddr = "10.0.0.2:4711"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 不在任何路由组中的路由不受影响
+	req = httptest.NewRequest("GET", "/api/v1/add", nil)
+	req.RemoteAddr = "203.0.113.7:4711"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/exiaohu/go-demo/internal/loadshed"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+// LoadShed 返回自适应并发限制中间件，并发数超出当前上限时返回 503 JSON 错误响应与 Retry-After
+// 豁免的路由（健康检查、监控等）始终放行且不计入并发数；
+// 5xx 响应与 panic 视为失败，促使上限收缩；limiter 为 nil 时表示未启用，请求直接透传
+func LoadShed(limiter *loadshed.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
+		retryAfter := strconv.FormatInt(int64(math.Ceil(limiter.RetryAfter().Seconds())), 10)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limiter.Exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			release, ok := limiter.Acquire()
+			if !ok {
+				w.Header().Set("Retry-After", retryAfter)
+				response.Error(w, r, http.StatusServiceUnavailable, "Server is overloaded")
+				return
+			}
+
+			wrapped := wrapResponseWriter(w)
+			dropped := true
+			defer func() { release(dropped) }()
+			next.ServeHTTP(wrapped, r)
+			dropped = wrapped.status >= http.StatusInternalServerError
+		})
+	}
+}
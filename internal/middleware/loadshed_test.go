@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/loadshed"
+)
+
+func TestLoadShed(t *testing.T) {
+	limiter, err := loadshed.New(loadshed.Options{InitialLimit: 1, MinLimit: 1, Exempt: []string{"/healthz"}})
+	require.NoError(t, err)
+
+	var shed *httptest.ResponseRecorder
+	var inner []int
+	handler := LoadShed(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 占用唯一名额期间的并发请求被拒绝，豁免路由仍可访问
+		if r.URL.Path == "/api/v1/add" {
+			shed = httptest.NewRecorder()
+			LoadShed(limiter)(http.NotFoundHandler()).ServeHTTP(shed, httptest.NewRequest("GET", "/api/v1/add", nil))
+		}
+		inner = append(inner, limiter.InFlight())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/add", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, shed)
+	assert.Equal(t, http.StatusServiceUnavailable, shed.Code)
+	assert.Equal(t, "1", shed.Header().Get("Retry-After"))
+	assert.Equal(t, "application/json", shed.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":503,"message":"Server is overloaded"}`, shed.Body.String())
+	assert.Equal(t, 0, limiter.InFlight())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []int{1, 0}, inner, "exempt requests are not counted")
+
+	// panic 时也归还名额
+	panicking := LoadShed(limiter)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	assert.Panics(t, func() {
+		panicking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+	assert.Equal(t, 0, limiter.InFlight())
+
+	// 未启用时直接透传
+	w = httptest.NewRecorder()
+	LoadShed(nil)(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,50 +1,207 @@
 package middleware
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/exiaohu/go-demo/pkg/metrics"
 )
 
-var (
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
+// RouteUnmatched 未经路由（例如在中间件中被拒绝）的请求使用的 route 标签
+const RouteUnmatched = "unmatched"
+
+// MetricsOptions HTTP 指标配置，零值字段使用默认值
+type MetricsOptions struct {
+	// DurationBuckets 请求耗时直方图的桶（秒），默认 prometheus.DefBuckets
+	DurationBuckets []float64
+	// SizeBuckets 请求与响应大小直方图的桶（字节），默认 100B 到 100MB 按 10 倍递增
+	SizeBuckets []float64
+}
+
+// HTTPMetrics HTTP 请求的 Prometheus 指标，按 ServeMux 匹配到的路由模式打标签，
+// 避免按原始路径打标签时扫描器探测随机路径导致序列数量失控
+type HTTPMetrics struct {
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	inflight     prometheus.Gauge
+	panics       prometheus.Counter
+}
+
+// NewHTTPMetrics 创建并注册 HTTP 指标
+// 同一 Registerer 上已注册的指标按 metrics.Register 的规则复用，请求耗时直方图的桶边界与 opts 不一致时 panic
+func NewHTTPMetrics(reg prometheus.Registerer, opts MetricsOptions) *HTTPMetrics {
+	if opts.DurationBuckets == nil {
+		opts.DurationBuckets = prometheus.DefBuckets
+	}
+	if opts.SizeBuckets == nil {
+		opts.SizeBuckets = prometheus.ExponentialBuckets(100, 10, 7)
+	}
+	labels := []string{"method", "route"}
+	return &HTTPMetrics{
+		requests: metrics.MustRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "http_requests_total",
 			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "path", "status"},
-	)
-
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
+		}, []string{"method", "route", "status"})),
+		duration: metrics.MustNewHistogramVec(reg, prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
 			Help:    "HTTP request duration distribution",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "path"},
-	)
-)
-
-func init() {
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
+			Buckets: opts.DurationBuckets,
+		}, labels),
+		requestSize: metrics.MustNewHistogramVec(reg, prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "HTTP request body size distribution",
+			Buckets: opts.SizeBuckets,
+		}, labels),
+		responseSize: metrics.MustNewHistogramVec(reg, prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "HTTP response body size distribution as written to the client",
+			Buckets: opts.SizeBuckets,
+		}, labels),
+		inflight: metrics.MustRegister(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served",
+		})),
+		panics: metrics.MustRegister(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_panics_total",
+			Help: "Total number of panics recovered from HTTP handlers",
+		})),
+	}
 }
 
-// Metrics 记录 Prometheus 指标
-func Metrics(next http.Handler) http.Handler {
+// Middleware 返回记录 HTTP 指标的中间件
+// 路由模式由最内层的 RoutePattern 写入，未经路由的请求记为 RouteUnmatched；
+// 内层 Recovery 恢复的 panic 计入 http_panics_total。
+// 指标在 defer 中记录，因 http.ErrAbortHandler 中断的请求同样计入
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		m.inflight.Inc()
+
+		state := &requestState{}
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
 		wrapped := wrapResponseWriter(w)
 
-		next.ServeHTTP(wrapped, r)
+		defer func() {
+			m.inflight.Dec()
+			pattern, panicked := state.get()
+			if pattern == "" {
+				pattern = RouteUnmatched
+			}
+			requestSize := r.ContentLength
+			if requestSize < 0 {
+				requestSize = body.n
+			}
+			status := wrapped.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			method := metricMethod(r.Method)
+			m.requests.WithLabelValues(method, pattern, strconv.Itoa(status)).Inc()
+			m.duration.WithLabelValues(method, pattern).Observe(time.Since(start).Seconds())
+			m.requestSize.WithLabelValues(method, pattern).Observe(float64(requestSize))
+			m.responseSize.WithLabelValues(method, pattern).Observe(float64(wrapped.bytes))
+			if panicked {
+				m.panics.Inc()
+			}
+		}()
+
+		next.ServeHTTP(wrapped, r.WithContext(context.WithValue(r.Context(), requestStateKey{}, state)))
+	})
+}
+
+// metricMethod 将非标准的请求方法归为 OTHER，同样是为了限制标签取值
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// defaultHTTPMetrics 注册在 prometheus.DefaultRegisterer 上的默认 HTTP 指标
+var defaultHTTPMetrics = sync.OnceValue(func() *HTTPMetrics {
+	return NewHTTPMetrics(prometheus.DefaultRegisterer, MetricsOptions{})
+})
 
-		duration := time.Since(start).Seconds()
-		status := strconv.Itoa(wrapped.status)
+// Metrics 使用默认桶记录 Prometheus 指标，指标注册在 prometheus.DefaultRegisterer 上
+func Metrics(next http.Handler) http.Handler {
+	return defaultHTTPMetrics().Middleware(next)
+}
 
-		// 记录指标
-		httpRequestsTotal.WithLabelValues(r.Method, r.URL.Path, status).Inc()
-		httpRequestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(duration)
+// RoutePattern 记录 ServeMux 匹配到的路由模式，包装在 ServeMux 外层使用
+// ServeMux 在分发前将匹配到的模式写入 r.Pattern。嵌套路由时最内层先记录，外层不会覆盖更具体的模式，
+// 因此挂载在 StripPrefix 之后的 /api/v1 子路由与根路由上的同一 handler 使用相同的标签
+func RoutePattern(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 在 defer 中记录，handler panic 时同样可以按路由统计
+		defer func() {
+			if state, ok := r.Context().Value(requestStateKey{}).(*requestState); ok && r.Pattern != "" {
+				state.setRoute(r.Pattern)
+			}
+		}()
+		mux.ServeHTTP(w, r)
 	})
 }
+
+type requestStateKey struct{}
+
+// requestState 在请求 context 中传递匹配到的路由模式与是否发生 panic，Metrics 在请求结束后读取
+type requestState struct {
+	mu       sync.Mutex
+	pattern  string
+	panicked bool
+}
+
+// setRoute 记录路由模式，已记录更具体的模式时忽略
+func (s *requestState) setRoute(pattern string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pattern == "" {
+		s.pattern = pattern
+	}
+}
+
+func (s *requestState) setPanicked() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.panicked = true
+}
+
+func (s *requestState) get() (pattern string, panicked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pattern, s.panicked
+}
+
+// recordPanic 标记当前请求发生了 panic，由外层的 Metrics 计入 http_panics_total
+func recordPanic(ctx context.Context) {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+		state.setPanicked()
+	}
+}
+
+// countingReader 统计实际读取的请求体字节数，用于未声明 Content-Length 的请求
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMetricsHandler(t *testing.T, opts MetricsOptions) (*HTTPMetrics, http.Handler) {
+	t.Helper()
+
+	v1 := http.NewServeMux()
+	v1.HandleFunc("/add", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("sum"))
+	})
+	v1.HandleFunc("DELETE /history/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+		}
+	})
+	router.HandleFunc("/add", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("sum"))
+	})
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	router.Handle("/api/v1/", http.StripPrefix("/api/v1", RoutePattern(v1)))
+
+	m := NewHTTPMetrics(prometheus.NewRegistry(), opts)
+	return m, Chain(RoutePattern(router), m.Middleware, Recovery)
+}
+
+func TestHTTPMetrics_RouteLabels(t *testing.T) {
+	m, handler := newMetricsHandler(t, MetricsOptions{})
+
+	paths := []struct{ method, path string }{
+		{"GET", "/add"},
+		{"GET", "/api/v1/add"},
+		{"GET", "/api/v1/add"},
+		{"DELETE", "/api/v1/history/1"},
+		{"DELETE", "/api/v1/history/2"},
+		{"GET", "/wp-login.php"},
+		{"GET", "/.env"},
+		{"GET", "/panic"},
+		{"PROPFIND", "/add"},
+	}
+	for _, p := range paths {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(p.method, p.path, nil))
+	}
+
+	// 根路由与 /api/v1 下的同一 handler 共用一个序列
+	assert.InDelta(t, 3, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/add", "200")), 0)
+	assert.InDelta(t, 2, testutil.ToFloat64(m.requests.WithLabelValues("DELETE", "DELETE /history/{id}", "204")), 0)
+	// 扫描器探测的随机路径归到兜底路由，不会产生新的序列
+	assert.InDelta(t, 2, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/", "404")), 0)
+	// panic 的请求同样按路由统计
+	assert.InDelta(t, 1, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/panic", "500")), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(m.requests.WithLabelValues("OTHER", "/add", "200")), 0)
+	assert.Equal(t, 5, testutil.CollectAndCount(m.requests))
+	assert.InDelta(t, 0, testutil.ToFloat64(m.inflight), 0)
+}
+
+func TestHTTPMetrics_Unmatched(t *testing.T) {
+	m := NewHTTPMetrics(prometheus.NewRegistry(), MetricsOptions{})
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+	handler := Chain(RoutePattern(http.NewServeMux()), m.Middleware, reject)
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	assert.InDelta(t, 3, testutil.ToFloat64(m.requests.WithLabelValues("GET", RouteUnmatched, "403")), 0)
+	assert.Equal(t, 1, testutil.CollectAndCount(m.requests))
+}
+
+func TestHTTPMetrics_Sizes(t *testing.T) {
+	m, handler := newMetricsHandler(t, MetricsOptions{
+		DurationBuckets: []float64{0.1, 1},
+		SizeBuckets:     []float64{10, 1000},
+	})
+
+	// 未声明 Content-Length 的请求按实际读取的字节数统计
+	reader := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 512)
+		for {
+			if _, err := r.Body.Read(buf); err != nil {
+				break
+			}
+		}
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	req := httptest.NewRequest("POST", "/add", strings.NewReader(strings.Repeat("a", 50)))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/chunked", strings.NewReader(strings.Repeat("b", 500)))
+	req.ContentLength = -1
+	m.Middleware(reader).ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := `
+# HELP http_request_size_bytes HTTP request body size distribution
+# TYPE http_request_size_bytes histogram
+http_request_size_bytes_bucket{method="POST",route="/add",le="10"} 0
+http_request_size_bytes_bucket{method="POST",route="/add",le="1000"} 1
+http_request_size_bytes_bucket{method="POST",route="/add",le="+Inf"} 1
+http_request_size_bytes_sum{method="POST",route="/add"} 50
+http_request_size_bytes_count{method="POST",route="/add"} 1
+http_request_size_bytes_bucket{method="POST",route="unmatched",le="10"} 0
+http_request_size_bytes_bucket{method="POST",route="unmatched",le="1000"} 1
+http_request_size_bytes_bucket{method="POST",route="unmatched",le="+Inf"} 1
+http_request_size_bytes_sum{method="POST",route="unmatched"} 500
+http_request_size_bytes_count{method="POST",route="unmatched"} 1
+# HELP http_response_size_bytes HTTP response body size distribution as written to the client
+# TYPE http_response_size_bytes histogram
+http_response_size_bytes_bucket{method="POST",route="/add",le="10"} 1
+http_response_size_bytes_bucket{method="POST",route="/add",le="1000"} 1
+http_response_size_bytes_bucket{method="POST",route="/add",le="+Inf"} 1
+http_response_size_bytes_sum{method="POST",route="/add"} 3
+http_response_size_bytes_count{method="POST",route="/add"} 1
+http_response_size_bytes_bucket{method="POST",route="unmatched",le="10"} 0
+http_response_size_bytes_bucket{method="POST",route="unmatched",le="1000"} 1
+http_response_size_bytes_bucket{method="POST",route="unmatched",le="+Inf"} 1
+http_response_size_bytes_sum{method="POST",route="unmatched"} 100
+http_response_size_bytes_count{method="POST",route="unmatched"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(m.requestSize, strings.NewReader(expected), "http_request_size_bytes"))
+	require.NoError(t, testutil.CollectAndCompare(m.responseSize, strings.NewReader(expected), "http_response_size_bytes"))
+}
+
+func TestHTTPMetrics_InFlight(t *testing.T) {
+	m := NewHTTPMetrics(prometheus.NewRegistry(), MetricsOptions{})
+	var during float64
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = testutil.ToFloat64(m.inflight)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.InDelta(t, 1, during, 0)
+	assert.InDelta(t, 0, testutil.ToFloat64(m.inflight), 0)
+}
@@ -1,12 +1,20 @@
 package middleware
 
 import (
+	stderrors "errors"
+	"fmt"
 	"net/http"
+	"runtime/debug"
 	"time"
 
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"github.com/exiaohu/go-demo/pkg/errors"
 	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
 	"github.com/exiaohu/go-demo/pkg/util/ip"
 )
 
@@ -15,6 +23,8 @@ type responseWriter struct {
 	http.ResponseWriter
 	status      int
 	wroteHeader bool
+	// bytes 已写出的响应体字节数
+	bytes int64
 }
 
 func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
@@ -25,6 +35,11 @@ func (rw *responseWriter) Status() int {
 	return rw.status
 }
 
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter 的 Flush、Hijack 等能力
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *responseWriter) WriteHeader(code int) {
 	if rw.wroteHeader {
 		return
@@ -38,7 +53,9 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	if !rw.wroteHeader {
 		rw.WriteHeader(http.StatusOK)
 	}
-	return rw.ResponseWriter.Write(b)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
 }
 
 // RequestLogger 记录每个请求的日志
@@ -50,7 +67,7 @@ func RequestLogger(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		logger.Info("HTTP Request",
+		logger.FromContext(r.Context()).Info("HTTP Request",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Int("status", wrapped.status),
@@ -61,19 +78,48 @@ func RequestLogger(next http.Handler) http.Handler {
 	})
 }
 
-// Recovery 恢复 panic 并记录错误
+// Recovery 恢复 panic，记录堆栈并返回带 Request ID 的 JSON 错误响应
+// 堆栈同时记录到当前 OpenTelemetry span，并由外层的 Metrics 计入 http_panics_total 指标。
+// http.ErrAbortHandler 按 net/http 的约定继续向上抛出以中断连接；
+// 响应头已经写出时无法再返回错误响应，同样中断连接，避免客户端把不完整的响应当作成功
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wrapped := wrapResponseWriter(w)
 		defer func() {
-			if err := recover(); err != nil {
-				logger.Error("Panic recovered",
-					zap.Any("error", err),
-					zap.String("path", r.URL.Path),
-				)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			rec := recover()
+			if rec == nil {
+				return
 			}
+			err, ok := rec.(error)
+			if !ok {
+				err = fmt.Errorf("%v", rec)
+			}
+			if stderrors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			stack := debug.Stack()
+			err = fmt.Errorf("panic: %w", err)
+
+			span := trace.SpanFromContext(r.Context())
+			span.RecordError(err, trace.WithAttributes(semconv.ExceptionStacktraceKey.String(string(stack))))
+			span.SetStatus(codes.Error, err.Error())
+			recordPanic(r.Context())
+
+			logger.FromContext(r.Context()).Error("Panic recovered",
+				zap.Any("error", rec),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Bool("headers_written", wrapped.wroteHeader),
+				zap.ByteString("stack", stack),
+			)
+
+			if wrapped.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			response.FromError(wrapped, r, errors.New(errors.ErrTypeInternal, http.StatusText(http.StatusInternalServerError)))
 		}()
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(wrapped, r)
 	})
 }
 
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/quota"
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+// 配额相关响应头
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// Quota 配额中间件，在请求 context 中放入 quota.Charger，由处理器在请求通过校验后按计算次数扣减
+// 响应头报告剩余最少的窗口；配额用尽时 quota.Charge 返回 quota.ErrExceeded 并设置 Retry-After，
+// 由处理器返回 429。请求最终失败（4xx/5xx）时退还已扣减的配额。
+// 配额存储不可用时放行请求，避免存储故障导致全部接口不可用
+func Quota(quotas *quota.Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if quotas == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := wrapResponseWriter(w)
+			c := &quotaCharge{quotas: quotas, subject: quota.Subject(r), header: wrapped.Header()}
+			next.ServeHTTP(wrapped, r.WithContext(quota.NewContext(r.Context(), c.charge)))
+
+			if wrapped.status >= http.StatusBadRequest {
+				// 客户端断开不应影响退还
+				c.refund(context.WithoutCancel(r.Context()))
+			}
+		})
+	}
+}
+
+// quotaCharge 记录一个请求中已扣减的配额，以便请求失败时退还
+type quotaCharge struct {
+	quotas  *quota.Service
+	subject string
+	header  http.Header
+	charges []charged
+}
+
+type charged struct {
+	n        int64
+	statuses []quota.Status
+}
+
+func (c *quotaCharge) charge(ctx context.Context, n int64) error {
+	statuses, err := c.quotas.Consume(ctx, c.subject, n)
+	exceeded := errors.Is(err, quota.ErrExceeded)
+	if err != nil && !exceeded {
+		logger.FromContext(ctx).Error("Failed to consume quota", zap.String("subject", c.subject), zap.Error(err))
+		return nil
+	}
+
+	now := time.Now()
+	setQuotaHeaders(c.header, statuses, now)
+	if exceeded {
+		logger.FromContext(ctx).Warn("Quota exceeded", zap.String("subject", c.subject), zap.Int64("cost", n))
+		c.header.Set("Retry-After", strconv.FormatInt(retryAfter(statuses, n, now), 10))
+		return quota.ErrExceeded
+	}
+	c.charges = append(c.charges, charged{n: n, statuses: statuses})
+	return nil
+}
+
+func (c *quotaCharge) refund(ctx context.Context) {
+	for _, ch := range c.charges {
+		if err := c.quotas.Refund(ctx, c.subject, ch.n, ch.statuses); err != nil {
+			logger.FromContext(ctx).Error("Failed to refund quota", zap.String("subject", c.subject), zap.Error(err))
+		}
+	}
+}
+
+// setQuotaHeaders 写入 X-RateLimit-* 与 RateLimit-Policy 响应头
+func setQuotaHeaders(h http.Header, statuses []quota.Status, now time.Time) {
+	tightest, ok := quota.Tightest(statuses)
+	if !ok {
+		return
+	}
+	h.Set(HeaderRateLimitLimit, strconv.FormatInt(tightest.Limit, 10))
+	h.Set(HeaderRateLimitRemaining, strconv.FormatInt(tightest.Remaining, 10))
+	h.Set(HeaderRateLimitReset, strconv.FormatInt(secondsUntil(tightest.ResetAt, now), 10))
+
+	policies := make([]string, 0, len(statuses))
+	for _, st := range statuses {
+		policies = append(policies, fmt.Sprintf("%d;w=%d;comment=%q",
+			st.Limit, int64(st.Window()/time.Second), st.Period))
+	}
+	h.Set(HeaderRateLimitPolicy, strings.Join(policies, ", "))
+}
+
+// retryAfter 返回剩余配额不足 n 次的窗口中最晚的重置时间
+func retryAfter(statuses []quota.Status, n int64, now time.Time) int64 {
+	var seconds int64
+	for _, st := range statuses {
+		if st.Remaining < n {
+			seconds = max(seconds, secondsUntil(st.ResetAt, now))
+		}
+	}
+	return seconds
+}
+
+func secondsUntil(t, now time.Time) int64 {
+	return int64(math.Ceil(max(t.Sub(now), 0).Seconds()))
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/quota"
+	"github.com/exiaohu/go-demo/internal/repository"
+)
+
+func TestQuota(t *testing.T) {
+	svc := quota.NewService(repository.NewMemoryQuotaRepository(), repository.NewMemoryUnitOfWork(),
+		quota.Limits{Daily: 2, Monthly: 100}, nil)
+
+	calls := 0
+	handler := Quota(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := quota.Charge(r.Context(), 1); err != nil {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/add", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("10.0.0.1:1")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "2", first.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "1", first.Header().Get(HeaderRateLimitRemaining))
+	reset, err := strconv.Atoi(first.Header().Get(HeaderRateLimitReset))
+	require.NoError(t, err)
+	assert.True(t, reset > 0 && reset <= 86400)
+	assert.Regexp(t, `^2;w=86400;comment="day", 100;w=\d+;comment="month"$`, first.Header().Get(HeaderRateLimitPolicy))
+
+	assert.Equal(t, http.StatusOK, do("10.0.0.1:1").Code)
+
+	exceeded := do("10.0.0.1:1")
+	assert.Equal(t, http.StatusTooManyRequests, exceeded.Code)
+	assert.Equal(t, "0", exceeded.Header().Get(HeaderRateLimitRemaining))
+	assert.NotEmpty(t, exceeded.Header().Get("Retry-After"))
+	assert.Equal(t, 2, calls)
+
+	// 按客户端分别计数
+	assert.Equal(t, http.StatusOK, do("10.0.0.2:1").Code)
+
+	// 未启用配额时直接放行
+	passthrough := Quota(nil)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	w := httptest.NewRecorder()
+	passthrough.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/add", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(HeaderRateLimitLimit))
+}
+
+func TestQuota_ChargeAndRefund(t *testing.T) {
+	svc := quota.NewService(repository.NewMemoryQuotaRepository(), repository.NewMemoryUnitOfWork(),
+		quota.Limits{Daily: 10}, nil)
+
+	// 处理器按实际计算次数扣减，返回的状态码决定是否退还
+	do := func(cost int64, status int) *httptest.ResponseRecorder {
+		handler := Quota(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := quota.Charge(r.Context(), cost); err != nil {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(status)
+		}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/batch", nil))
+		return w
+	}
+	used := func() int64 {
+		statuses, err := svc.Usage(context.Background(), "ip:192.0.2.1")
+		require.NoError(t, err)
+		return statuses[0].Used
+	}
+
+	assert.Equal(t, http.StatusOK, do(4, http.StatusOK).Code)
+	assert.Equal(t, int64(4), used())
+
+	// 校验失败的请求退还配额
+	assert.Equal(t, http.StatusBadRequest, do(4, http.StatusBadRequest).Code)
+	assert.Equal(t, int64(4), used())
+
+	// 超出剩余配额的批量请求被整体拒绝，剩余配额非零时同样要求等到窗口重置
+	rejected := do(7, http.StatusOK)
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	assert.Equal(t, "6", rejected.Header().Get(HeaderRateLimitRemaining))
+	retry, err := strconv.Atoi(rejected.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.True(t, retry > 0 && retry <= 86400)
+	assert.Equal(t, int64(4), used())
+
+	assert.Equal(t, http.StatusOK, do(6, http.StatusOK).Code)
+	assert.Equal(t, int64(10), used())
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/response"
+)
+
+// Authorize RBAC 授权中间件，需放在 Authenticate 之后
+// 被拒绝的请求写入审计日志；匿名请求返回 401，权限不足或路由未被策略覆盖返回 403
+func Authorize(policy *auth.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.FromContext(r.Context())
+			decision := policy.Decide(r, principal)
+			if decision.Allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			auditDenied(r, principal, decision)
+			if principal == nil {
+				challenges, _ := r.Context().Value(challengesKey{}).([]string)
+				unauthorized(w, r, challenges, errors.New(errors.ErrTypeUnauthorized, "Authentication required"))
+				return
+			}
+			details := "route is not covered by the access policy"
+			if len(decision.Required) > 0 {
+				details = "requires one of: " + strings.Join(decision.Required, ", ")
+			}
+			response.FromError(w, r, errors.NewWithDetails(errors.ErrTypeForbidden, "Access denied", details))
+		})
+	}
+}
+
+// auditDenied 记录授权拒绝的审计日志
+func auditDenied(r *http.Request, principal *auth.Principal, decision auth.Decision) {
+	fields := []zap.Field{
+		zap.String("audit", "access_denied"),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("pattern", decision.Pattern),
+		zap.Strings("required", decision.Required),
+	}
+	if principal != nil {
+		fields = append(fields,
+			zap.String("auth_method", principal.Method),
+			zap.Strings("roles", principal.Roles),
+		)
+	}
+	logger.FromContext(r.Context()).Warn("Access denied", fields...)
+}
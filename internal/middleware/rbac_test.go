@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+)
+
+func TestAuthorize(t *testing.T) {
+	policy, err := auth.NewPolicy(auth.PolicyOptions{
+		Roles:       map[string][]string{"operator": {"metrics"}},
+		Permissions: map[string][]string{"metrics": {"/metrics"}},
+		Public:      []string{"GET /healthz"},
+	})
+	require.NoError(t, err)
+
+	verifier := stubVerifier{
+		"operator-key": {ID: "apikey:1", Roles: []string{"operator"}},
+		"viewer-key":   {ID: "apikey:2", Roles: []string{"viewer"}},
+	}
+	handler := Authenticate(auth.NewAPIKeyAuthenticator(verifier))(
+		Authorize(policy)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})),
+	)
+
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		wantStatus int
+	}{
+		{"PublicAnonymous", "/healthz", "", http.StatusOK},
+		{"UncoveredAnonymous", "/debug/slo", "", http.StatusUnauthorized},
+		{"UncoveredAuthenticated", "/debug/slo", "operator-key", http.StatusForbidden},
+		{"ProtectedAnonymous", "/metrics", "", http.StatusUnauthorized},
+		{"ProtectedForbidden", "/metrics", "viewer-key", http.StatusForbidden},
+		{"ProtectedAllowed", "/metrics", "operator-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(auth.HeaderAPIKey, tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), auth.SchemeAPIKey)
+			}
+		})
+	}
+}
@@ -9,17 +9,12 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/requestid"
 	"github.com/exiaohu/go-demo/pkg/util/ip"
 )
 
-type contextKey string
-
-const (
-	// RequestIDKey 上下文中 Request ID 的键
-	RequestIDKey contextKey = "request_id"
-	// HeaderXRequestID HTTP Header 中的 Request ID 键
-	HeaderXRequestID = "X-Request-ID"
-)
+// HeaderXRequestID HTTP Header 中的 Request ID 键
+const HeaderXRequestID = "X-Request-ID"
 
 // RequestID 为每个请求生成唯一的 ID
 func RequestID(next http.Handler) http.Handler {
@@ -34,7 +29,7 @@ func RequestID(next http.Handler) http.Handler {
 		w.Header().Set(HeaderXRequestID, reqID)
 
 		// 将 Request ID 注入到 Context
-		ctx := context.WithValue(r.Context(), RequestIDKey, reqID)
+		ctx := requestid.NewContext(r.Context(), reqID)
 
 		// 传递带有 Request ID 的 Context
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -43,20 +38,22 @@ func RequestID(next http.Handler) http.Handler {
 
 // GetRequestID 从 Context 中获取 Request ID
 func GetRequestID(ctx context.Context) string {
-	if val, ok := ctx.Value(RequestIDKey).(string); ok {
-		return val
-	}
-	return ""
+	return requestid.FromContext(ctx)
 }
 
-// WithLogger 注入带有 Request ID 的 logger 到 Context（可选增强）
+// WithLogger 将请求级 logger 注入 Context，日志带有 Request ID、客户端 IP 以及 trace_id 与 span_id，
+// 之后通过 logger.FromContext 取出；需要位于 ClientIP、RequestID 与 Tracer 之后，认证通过后 Authenticate 再追加调用方标识
 func WithLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r)
+		ctx := logger.With(r.Context(),
+			zap.String("request_id", GetRequestID(r.Context())),
+			zap.String("ip", ip.GetClientIP(r)),
+		)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// LoggerMiddleware 重写 RequestLogger 以包含 Request ID
+// LoggerMiddleware 重写 RequestLogger，使用 WithLogger 注入的请求级 logger 记录请求日志
 func LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -64,15 +61,11 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		reqID := GetRequestID(r.Context())
-
-		logger.Info("HTTP Request",
+		logger.FromContext(r.Context()).Info("HTTP Request",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Int("status", wrapped.status),
 			zap.Duration("duration", time.Since(start)),
-			zap.String("ip", ip.GetClientIP(r)),
-			zap.String("request_id", reqID),
 			zap.String("user_agent", r.UserAgent()),
 		)
 	})
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey API 密钥
+// 明文密钥只在创建时返回一次，数据库中只保存其 SHA-256 哈希
+type APIKey struct {
+	ID         uint       `gorm:"primarykey"                   json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Name       string     `gorm:"size:64;not null"             json:"name"`
+	Prefix     string     `gorm:"size:32;uniqueIndex;not null" json:"prefix"`
+	Hash       string     `gorm:"size:64;not null"             json:"-"`
+	Scopes     string     `gorm:"size:255;not null"            json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ScopeList 返回密钥的 scope 列表
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// Revoked 判断密钥是否已被吊销
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
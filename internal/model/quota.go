@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// QuotaUsage 调用方在某个配额窗口内的用量
+// 每个 (Subject, Period, WindowStart) 对应一行，计数持久化以便重启后继续生效
+type QuotaUsage struct {
+	ID          uint      `gorm:"primarykey"                                    json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Subject     string    `gorm:"size:191;not null;uniqueIndex:idx_quota_window" json:"subject"`
+	Period      string    `gorm:"size:16;not null;uniqueIndex:idx_quota_window"  json:"period"`
+	WindowStart time.Time `gorm:"not null;uniqueIndex:idx_quota_window"          json:"window_start"`
+	Count       int64     `gorm:"not null;default:0"                             json:"count"`
+}
@@ -0,0 +1,23 @@
+package quota
+
+import "context"
+
+// Charger 扣减当前请求调用方的 n 次配额，配额用尽时返回 ErrExceeded
+type Charger func(ctx context.Context, n int64) error
+
+type chargerKey struct{}
+
+// NewContext 返回携带 Charger 的 context，由配额中间件放入请求 context
+func NewContext(ctx context.Context, c Charger) context.Context {
+	return context.WithValue(ctx, chargerKey{}, c)
+}
+
+// Charge 扣减当前请求调用方的 n 次配额，配额用尽时返回 ErrExceeded
+// 处理器在请求通过校验后按实际的计算次数调用，例如批量计算按操作数扣减；
+// context 中没有 Charger（未启用配额）时不做任何事
+func Charge(ctx context.Context, n int64) error {
+	if c, ok := ctx.Value(chargerKey{}).(Charger); ok {
+		return c(ctx, n)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Package quota 实现按调用方统计的日/月配额
+// 用量持久化在数据库中，服务重启后继续生效
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/internal/repository"
+	"github.com/exiaohu/go-demo/pkg/logger"
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+// Period 配额窗口
+type Period string
+
+// 支持的配额窗口，均按 UTC 自然日/自然月划分
+const (
+	PeriodDay   Period = "day"
+	PeriodMonth Period = "month"
+)
+
+// ErrExceeded 调用方已用尽配额
+var ErrExceeded = errors.New("quota exceeded")
+
+// Window 返回 t 所在窗口的起止时间
+func (p Period) Window(t time.Time) (start, end time.Time) {
+	t = t.UTC()
+	switch p {
+	case PeriodMonth:
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// Limits 一个调用方的配额，0 表示不限制
+type Limits struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+// Status 某个窗口的配额使用情况
+type Status struct {
+	Period      Period    `json:"period"`
+	Limit       int64     `json:"limit"`
+	Used        int64     `json:"used"`
+	Remaining   int64     `json:"remaining"`
+	WindowStart time.Time `json:"window_start"`
+	ResetAt     time.Time `json:"reset_at"`
+}
+
+// Window 返回窗口长度
+func (s Status) Window() time.Duration {
+	return s.ResetAt.Sub(s.WindowStart)
+}
+
+// Service 配额检查与用量查询
+type Service struct {
+	repo      repository.QuotaRepository
+	uow       repository.UnitOfWork
+	defaults  Limits
+	overrides map[string]Limits
+	now       func() time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewService 创建配额服务，overrides 按调用方标识覆盖默认配额
+func NewService(repo repository.QuotaRepository, uow repository.UnitOfWork, defaults Limits, overrides map[string]Limits) *Service {
+	return &Service{repo: repo, uow: uow, defaults: defaults, overrides: overrides, now: time.Now}
+}
+
+// Subject 返回请求对应的配额主体：已认证调用方使用其 ID，匿名请求使用客户端 IP
+func Subject(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.ID
+	}
+	return "ip:" + ip.GetClientIP(r)
+}
+
+// Limits 返回调用方的配额
+func (s *Service) Limits(subject string) Limits {
+	if limits, ok := s.overrides[subject]; ok {
+		return limits
+	}
+	return s.defaults
+}
+
+// Consume 为调用方消耗 n 次配额
+// 所有窗口在同一事务中扣减，任一窗口超出时全部回滚并返回 ErrExceeded；
+// 返回的状态反映扣减后（被拒绝时为当前）的用量，不限制的窗口不出现在结果中
+func (s *Service) Consume(ctx context.Context, subject string, n int64) ([]Status, error) {
+	periods := s.periods(subject)
+	if len(periods) == 0 {
+		return nil, nil
+	}
+
+	now := s.now()
+	statuses := make([]Status, len(periods))
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		exceeded := false
+		for i, p := range periods {
+			start, end := p.period.Window(now)
+			count, ok, err := s.repo.Increment(ctx, subject, string(p.period), start, n, p.limit)
+			if err != nil {
+				return err
+			}
+			statuses[i] = newStatus(p.period, p.limit, count, start, end)
+			exceeded = exceeded || !ok
+		}
+		if exceeded {
+			return ErrExceeded
+		}
+		return nil
+	})
+	if errors.Is(err, ErrExceeded) {
+		// 事务已回滚，重新读取各窗口的实际用量
+		statuses, err := s.Usage(ctx, subject)
+		if err != nil {
+			return nil, err
+		}
+		return statuses, ErrExceeded
+	}
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Refund 退还 Consume 扣减的 n 次配额，statuses 为 Consume 返回的状态，用于定位扣减时所在的窗口
+func (s *Service) Refund(ctx context.Context, subject string, n int64, statuses []Status) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		for _, st := range statuses {
+			if err := s.repo.Decrement(ctx, subject, string(st.Period), st.WindowStart, n); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Prune 删除已经结束的窗口的用量记录，返回删除的行数
+// 匿名调用方按 IP 各占一行，不清理时用量表会无限增长
+func (s *Service) Prune(ctx context.Context) (int64, error) {
+	now := s.now()
+	var total int64
+	for _, p := range []Period{PeriodDay, PeriodMonth} {
+		start, _ := p.Window(now)
+		n, err := s.repo.DeleteBefore(ctx, string(p), start)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// StartPruning 立即并且之后每隔 interval 调用一次 Prune，直到调用 Stop；只能调用一次
+// interval 不大于 0 时不清理
+func (s *Service) StartPruning(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.Prune(context.Background()); err != nil {
+				logger.Warn("Failed to prune quota usage", zap.Error(err))
+			} else if n > 0 {
+				logger.Debug("Pruned expired quota usage", zap.Int64("rows", n))
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止 StartPruning 启动的清理，未启动时什么也不做
+func (s *Service) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// Usage 返回调用方当前的配额使用情况
+func (s *Service) Usage(ctx context.Context, subject string) ([]Status, error) {
+	periods := s.periods(subject)
+	now := s.now()
+	statuses := make([]Status, 0, len(periods))
+	for _, p := range periods {
+		start, end := p.period.Window(now)
+		count, err := s.repo.Get(ctx, subject, string(p.period), start)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, newStatus(p.period, p.limit, count, start, end))
+	}
+	return statuses, nil
+}
+
+type periodLimit struct {
+	period Period
+	limit  int64
+}
+
+// periods 返回调用方受限的窗口，按日、月顺序排列
+func (s *Service) periods(subject string) []periodLimit {
+	limits := s.Limits(subject)
+	var periods []periodLimit
+	if limits.Daily > 0 {
+		periods = append(periods, periodLimit{PeriodDay, limits.Daily})
+	}
+	if limits.Monthly > 0 {
+		periods = append(periods, periodLimit{PeriodMonth, limits.Monthly})
+	}
+	return periods
+}
+
+func newStatus(period Period, limit, used int64, start, end time.Time) Status {
+	return Status{
+		Period:      period,
+		Limit:       limit,
+		Used:        used,
+		Remaining:   max(limit-used, 0),
+		WindowStart: start,
+		ResetAt:     end,
+	}
+}
+
+// Tightest 返回剩余次数最少的窗口，剩余相同时取最早重置的窗口
+func Tightest(statuses []Status) (Status, bool) {
+	if len(statuses) == 0 {
+		return Status{}, false
+	}
+	tightest := statuses[0]
+	for _, st := range statuses[1:] {
+		if st.Remaining < tightest.Remaining ||
+			st.Remaining == tightest.Remaining && st.ResetAt.Before(tightest.ResetAt) {
+			tightest = st
+		}
+	}
+	return tightest, true
+}
@@ -0,0 +1,180 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/internal/repository"
+)
+
+func newTestService(defaults Limits, overrides map[string]Limits, now time.Time) *Service {
+	s := NewService(repository.NewMemoryQuotaRepository(), repository.NewMemoryUnitOfWork(), defaults, overrides)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestPeriod_Window(t *testing.T) {
+	at := time.Date(2024, 2, 29, 23, 59, 59, 0, time.FixedZone("UTC+8", 8*3600))
+
+	start, end := PeriodDay.Window(at)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), end)
+
+	start, end = PeriodMonth.Window(at)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), end)
+}
+
+func TestService_Consume(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	s := newTestService(Limits{Daily: 2, Monthly: 3}, nil, now)
+
+	statuses, err := s.Consume(ctx, "apikey:1", 1)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, Status{
+		Period: PeriodDay, Limit: 2, Used: 1, Remaining: 1,
+		WindowStart: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		ResetAt:     time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+	}, statuses[0])
+	assert.Equal(t, PeriodMonth, statuses[1].Period)
+	assert.Equal(t, int64(2), statuses[1].Remaining)
+
+	_, err = s.Consume(ctx, "apikey:1", 1)
+	require.NoError(t, err)
+
+	// 日配额用尽，月配额的扣减随之回滚
+	statuses, err = s.Consume(ctx, "apikey:1", 1)
+	assert.ErrorIs(t, err, ErrExceeded)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, int64(0), statuses[0].Remaining)
+	assert.Equal(t, int64(2), statuses[1].Used)
+
+	// 次日日配额重置，但月配额只剩 1 次
+	s.now = func() time.Time { return now.AddDate(0, 0, 1) }
+	_, err = s.Consume(ctx, "apikey:1", 1)
+	require.NoError(t, err)
+	statuses, err = s.Consume(ctx, "apikey:1", 1)
+	assert.ErrorIs(t, err, ErrExceeded)
+	assert.Equal(t, int64(1), statuses[0].Used)
+	assert.Equal(t, int64(3), statuses[1].Used)
+
+	// 其他调用方不受影响
+	_, err = s.Consume(ctx, "apikey:2", 1)
+	assert.NoError(t, err)
+}
+
+func TestService_Overrides(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(Limits{Daily: 1}, map[string]Limits{
+		"apikey:vip":       {Daily: 100},
+		"jwt:unlimited.io": {},
+	}, time.Now())
+
+	assert.Equal(t, Limits{Daily: 1}, s.Limits("apikey:1"))
+	assert.Equal(t, Limits{Daily: 100}, s.Limits("apikey:vip"))
+
+	// 不限制的调用方不产生任何状态
+	for range 5 {
+		statuses, err := s.Consume(ctx, "jwt:unlimited.io", 1)
+		require.NoError(t, err)
+		assert.Empty(t, statuses)
+	}
+}
+
+func TestService_Usage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(Limits{Daily: 5}, nil, time.Now())
+
+	statuses, err := s.Usage(ctx, "apikey:1")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, int64(0), statuses[0].Used)
+
+	_, err = s.Consume(ctx, "apikey:1", 3)
+	require.NoError(t, err)
+
+	statuses, err = s.Usage(ctx, "apikey:1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), statuses[0].Used)
+	assert.Equal(t, int64(2), statuses[0].Remaining)
+	assert.Equal(t, 24*time.Hour, statuses[0].Window())
+}
+
+func TestTightest(t *testing.T) {
+	day := Status{Period: PeriodDay, Remaining: 5, ResetAt: time.Unix(100, 0)}
+	month := Status{Period: PeriodMonth, Remaining: 5, ResetAt: time.Unix(1000, 0)}
+
+	got, ok := Tightest([]Status{month, day})
+	require.True(t, ok)
+	assert.Equal(t, PeriodDay, got.Period)
+
+	month.Remaining = 1
+	got, _ = Tightest([]Status{day, month})
+	assert.Equal(t, PeriodMonth, got.Period)
+
+	_, ok = Tightest(nil)
+	assert.False(t, ok)
+}
+
+func TestSubject(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "ip:10.0.0.1", Subject(r))
+
+	r = r.WithContext(auth.NewContext(r.Context(), &auth.Principal{ID: "apikey:7"}))
+	assert.Equal(t, "apikey:7", Subject(r))
+}
+
+func TestService_Refund(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	s := newTestService(Limits{Daily: 10, Monthly: 10}, nil, now)
+
+	statuses, err := s.Consume(ctx, "apikey:1", 3)
+	require.NoError(t, err)
+
+	// 跨过窗口边界后仍然退还到扣减时的窗口
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	require.NoError(t, s.Refund(ctx, "apikey:1", 3, statuses))
+	s.now = func() time.Time { return now }
+	statuses, err = s.Usage(ctx, "apikey:1")
+	require.NoError(t, err)
+	assert.Zero(t, statuses[0].Used)
+	assert.Zero(t, statuses[1].Used)
+}
+
+func TestService_Prune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	s := newTestService(Limits{Daily: 10, Monthly: 10}, nil, now)
+
+	for _, subject := range []string{"ip:10.0.0.1", "ip:10.0.0.2"} {
+		_, err := s.Consume(ctx, subject, 1)
+		require.NoError(t, err)
+	}
+
+	// 当前窗口的记录保留
+	n, err := s.Prune(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// 次日只清理日窗口，次月再清理月窗口
+	s.now = func() time.Time { return now.AddDate(0, 0, 1) }
+	n, err = s.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	s.now = func() time.Time { return now.AddDate(0, 1, 0) }
+	n, err = s.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
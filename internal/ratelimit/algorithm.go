@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// tokenBucketState 令牌桶状态，last 为零值表示桶是满的
+type tokenBucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+type tokenBucket struct {
+	rate  float64
+	burst int
+}
+
+func (b tokenBucket) take(s *tokenBucketState, now time.Time) Result {
+	burst := float64(b.burst)
+	if s.last.IsZero() {
+		s.tokens = burst
+	} else if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens = min(burst, s.tokens+elapsed.Seconds()*b.rate)
+	}
+	s.last = now
+
+	if s.tokens >= 1 {
+		s.tokens--
+		return Result{Allowed: true, Limit: b.burst, Remaining: int(s.tokens)}
+	}
+	return Result{Limit: b.burst, RetryAfter: seconds((1 - s.tokens) / b.rate)}
+}
+
+func (b tokenBucket) idle() time.Duration {
+	return seconds(float64(b.burst) / b.rate)
+}
+
+// gcraState GCRA 状态，tat 为下一个请求的理论到达时间
+type gcraState struct {
+	tat time.Time
+}
+
+// gcra 每 interval 补充一次额度，请求最多可以比理论到达时间提前 burst 个 interval
+type gcra struct {
+	interval time.Duration
+	burst    int
+}
+
+func (g gcra) take(s *gcraState, now time.Time) Result {
+	tolerance := g.interval * time.Duration(g.burst)
+	tat := s.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(g.interval)
+
+	if allowAt := next.Add(-tolerance); now.Before(allowAt) {
+		return Result{Limit: g.burst, RetryAfter: allowAt.Sub(now)}
+	}
+	s.tat = next
+	return Result{Allowed: true, Limit: g.burst, Remaining: int((tolerance - next.Sub(now)) / g.interval)}
+}
+
+func (g gcra) idle() time.Duration {
+	return g.interval * time.Duration(g.burst)
+}
+
+// slidingLogState 窗口内放行请求的时间，按时间升序排列
+type slidingLogState struct {
+	log []time.Time
+}
+
+type slidingLog struct {
+	limit  int
+	window time.Duration
+}
+
+func (l slidingLog) take(s *slidingLogState, now time.Time) Result {
+	// 丢弃滑出窗口的记录，原地移动以保证内存不超过 limit 条
+	start := now.Add(-l.window)
+	i := 0
+	for i < len(s.log) && !s.log[i].After(start) {
+		i++
+	}
+	if i > 0 {
+		s.log = append(s.log[:0], s.log[i:]...)
+	}
+
+	if len(s.log) >= l.limit {
+		return Result{Limit: l.limit, RetryAfter: s.log[0].Add(l.window).Sub(now)}
+	}
+	s.log = append(s.log, now)
+	return Result{Allowed: true, Limit: l.limit, Remaining: l.limit - len(s.log)}
+}
+
+func (l slidingLog) idle() time.Duration {
+	return l.window
+}
+
+// slidingWindowState 当前固定窗口的起点与当前、上一窗口的计数
+type slidingWindowState struct {
+	start time.Time
+	prev  int
+	curr  int
+}
+
+// slidingWindow 以上一窗口计数按重叠比例加权，估算滑动窗口内的请求数
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+func (w slidingWindow) take(s *slidingWindowState, now time.Time) Result {
+	start := now.Truncate(w.window)
+	if !start.Equal(s.start) {
+		if start.Sub(s.start) == w.window {
+			s.prev = s.curr
+		} else {
+			s.prev = 0
+		}
+		s.curr = 0
+		s.start = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(w.window)
+	estimated := float64(s.prev)*weight + float64(s.curr)
+
+	if estimated+1 > float64(w.limit) {
+		// 上一窗口的权重随时间线性下降，求出估算值降到可以放行的时刻
+		retry := start.Add(w.window).Sub(now)
+		if s.prev > 0 && s.curr+1 <= w.limit {
+			at := w.window - w.window*time.Duration(w.limit-s.curr-1)/time.Duration(s.prev)
+			retry = max(at-elapsed, 0)
+		}
+		return Result{Limit: w.limit, RetryAfter: retry}
+	}
+	s.curr++
+	return Result{Allowed: true, Limit: w.limit, Remaining: max(int(float64(w.limit)-estimated-1), 0)}
+}
+
+func (w slidingWindow) idle() time.Duration {
+	return 2 * w.window
+}
+
+// seconds 将秒数转换为 time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
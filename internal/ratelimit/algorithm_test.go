@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend 限流状态存储的测试实现，同一组用例在进程内与 Redis 存储上运行
+type backend struct {
+	name string
+	// newLimiter 创建限流器，返回的 advance 推进限流器使用的时钟
+	newLimiter func(t *testing.T, alg Algorithm, limit Limit) (Limiter, func(time.Duration))
+}
+
+var backends = []backend{
+	{"Memory", newMemoryTestLimiter},
+	{"Redis", newRedisTestLimiter},
+}
+
+// testStart 测试时钟的起点，对齐到整秒以便滑动窗口的边界可预测
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newMemoryTestLimiter 创建使用可控时钟的进程内限流器
+func newMemoryTestLimiter(t *testing.T, alg Algorithm, limit Limit) (Limiter, func(time.Duration)) {
+	t.Helper()
+	clock := &testClock{now: testStart}
+	l, err := New(alg, limit, Options{CleanupInterval: time.Hour, Now: clock.Now})
+	require.NoError(t, err)
+	t.Cleanup(l.Stop)
+	return l, clock.Advance
+}
+
+// allowN 连续发起 n 个请求，返回放行的数量
+func allowN(t *testing.T, l Limiter, key string, n int) int {
+	t.Helper()
+	allowed := 0
+	for range n {
+		if allow(t, l, key).Allowed {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func allow(t *testing.T, l Limiter, key string) Result {
+	t.Helper()
+	res, err := l.Allow(context.Background(), key)
+	require.NoError(t, err)
+	return res
+}
+
+// 令牌桶与 GCRA 的行为应当一致：突发 burst 个请求，之后按 rate 放行
+func TestBucketAlgorithms(t *testing.T) {
+	for _, b := range backends {
+		for _, alg := range []Algorithm{AlgorithmTokenBucket, AlgorithmGCRA} {
+			t.Run(b.name+"/"+string(alg), func(t *testing.T) {
+				l, advance := b.newLimiter(t, alg, Limit{Rate: 2, Burst: 3})
+
+				first := allow(t, l, "a")
+				assert.True(t, first.Allowed)
+				assert.Equal(t, 3, first.Limit)
+				assert.Equal(t, 2, first.Remaining)
+				assert.Equal(t, 2, allowN(t, l, "a", 5))
+
+				denied := allow(t, l, "a")
+				assert.False(t, denied.Allowed)
+				assert.Equal(t, 0, denied.Remaining)
+				assert.Equal(t, 500*time.Millisecond, denied.RetryAfter)
+
+				// 不同 key 互不影响
+				assert.True(t, allow(t, l, "b").Allowed)
+
+				advance(500 * time.Millisecond)
+				assert.Equal(t, 1, allowN(t, l, "a", 3))
+
+				// 空闲足够久后回满，但不超过 burst
+				advance(time.Hour)
+				assert.Equal(t, 3, allowN(t, l, "a", 10))
+			})
+		}
+	}
+}
+
+func TestSlidingLog(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			l, advance := b.newLimiter(t, AlgorithmSlidingLog, Limit{Rate: 1, Window: 3 * time.Second})
+
+			assert.Equal(t, 2, allow(t, l, "a").Remaining)
+			advance(time.Second)
+			assert.True(t, allow(t, l, "a").Allowed)
+			advance(time.Second)
+			assert.True(t, allow(t, l, "a").Allowed)
+
+			denied := allow(t, l, "a")
+			assert.False(t, denied.Allowed)
+			assert.Equal(t, time.Second, denied.RetryAfter)
+
+			// 第一个请求滑出窗口后恰好放行一个
+			advance(time.Second)
+			assert.Equal(t, 1, allowN(t, l, "a", 3))
+
+			advance(3 * time.Second)
+			assert.Equal(t, 3, allowN(t, l, "a", 5))
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			l, advance := b.newLimiter(t, AlgorithmSlidingWindow, Limit{Rate: 10, Window: time.Second})
+
+			assert.Equal(t, 10, allowN(t, l, "a", 15))
+
+			// 下一窗口过去 20% 时，上一窗口的 10 个请求按 80% 计入
+			advance(1200 * time.Millisecond)
+			assert.Equal(t, 2, allowN(t, l, "a", 5))
+
+			denied := allow(t, l, "a")
+			assert.False(t, denied.Allowed)
+			assert.Equal(t, 100*time.Millisecond, denied.RetryAfter)
+
+			advance(100 * time.Millisecond)
+			assert.Equal(t, 1, allowN(t, l, "a", 3))
+
+			// 相隔超过一个窗口后上一窗口不再计入
+			advance(2 * time.Second)
+			assert.Equal(t, 10, allowN(t, l, "a", 15))
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		alg   Algorithm
+		limit Limit
+	}{
+		"ZeroRate":          {AlgorithmTokenBucket, Limit{Rate: 0, Burst: 1}},
+		"ZeroBurst":         {AlgorithmTokenBucket, Limit{Rate: 1}},
+		"GCRAZeroBurst":     {AlgorithmGCRA, Limit{Rate: 1}},
+		"WindowTooSmall":    {AlgorithmSlidingLog, Limit{Rate: 0.5, Window: time.Second}},
+		"UnknownAlgorithm":  {Algorithm("leaky"), Limit{Rate: 1, Burst: 1}},
+		"CounterTooSmall":   {AlgorithmSlidingWindow, Limit{Rate: 0.1}},
+		"NegativeRateGCRA":  {AlgorithmGCRA, Limit{Rate: -1, Burst: 1}},
+		"NegativeRateSlide": {AlgorithmSlidingLog, Limit{Rate: -1}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tt.alg, tt.limit, Options{})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/pkg/util/ip"
+)
+
+// KeyFunc 返回请求所属的限流 key
+type KeyFunc func(r *http.Request) string
+
+// 支持的限流 key
+const (
+	// KeyIP 按客户端 IP
+	KeyIP = "ip"
+	// KeyCIDR 按客户端 IP 所在网段
+	KeyCIDR = "cidr"
+	// KeyAPIKey 按已认证的调用方（API 密钥或 JWT 主体），匿名请求按客户端 IP
+	KeyAPIKey = "apikey"
+	// KeyHeader 按指定请求头的值，缺失时按客户端 IP
+	KeyHeader = "header"
+	// KeyRoute 按路由，命中同一路由的全部请求共享额度
+	KeyRoute = "route"
+)
+
+// 网段 key 的默认前缀长度
+const (
+	defaultIPv4Prefix = 24
+	defaultIPv6Prefix = 64
+)
+
+// KeyOptions 限流 key 配置
+type KeyOptions struct {
+	// Kind key 类型，为空时按客户端 IP
+	Kind string
+	// Header KeyHeader 使用的请求头
+	Header string
+	// IPv4Prefix、IPv6Prefix KeyCIDR 使用的前缀长度，默认 /24 与 /64
+	IPv4Prefix int
+	IPv6Prefix int
+	// Route KeyRoute 使用的路由模式，为空时使用请求路径
+	Route string
+}
+
+// NewKeyFunc 根据配置创建 KeyFunc
+func NewKeyFunc(opts KeyOptions) (KeyFunc, error) {
+	switch opts.Kind {
+	case KeyIP, "":
+		return ipKey, nil
+	case KeyCIDR:
+		v4, v6 := opts.IPv4Prefix, opts.IPv6Prefix
+		if v4 == 0 {
+			v4 = defaultIPv4Prefix
+		}
+		if v6 == 0 {
+			v6 = defaultIPv6Prefix
+		}
+		if v4 < 0 || v4 > 32 || v6 < 0 || v6 > 128 {
+			return nil, fmt.Errorf("rate limit: invalid cidr prefix /%d (IPv4) or /%d (IPv6)", v4, v6)
+		}
+		return cidrKey(v4, v6), nil
+	case KeyAPIKey:
+		return principalKey, nil
+	case KeyHeader:
+		if opts.Header == "" {
+			return nil, fmt.Errorf("rate limit: %q key requires a header name", KeyHeader)
+		}
+		return headerKey(http.CanonicalHeaderKey(opts.Header)), nil
+	case KeyRoute:
+		return routeKey(opts.Route), nil
+	default:
+		return nil, fmt.Errorf("rate limit: unsupported key %q", opts.Kind)
+	}
+}
+
+func ipKey(r *http.Request) string {
+	return "ip:" + ip.GetClientIP(r)
+}
+
+// cidrKey 将客户端 IP 归并到所在网段，IPv4 映射的 IPv6 地址按 IPv4 处理
+func cidrKey(v4, v6 int) KeyFunc {
+	return func(r *http.Request) string {
+		clientIP := ip.GetClientIP(r)
+		addr, err := netip.ParseAddr(clientIP)
+		if err != nil {
+			return "ip:" + clientIP
+		}
+		addr = addr.Unmap()
+		bits := v6
+		if addr.Is4() {
+			bits = v4
+		}
+		prefix, err := addr.WithZone("").Prefix(bits)
+		if err != nil {
+			return "ip:" + clientIP
+		}
+		return "cidr:" + prefix.String()
+	}
+}
+
+func principalKey(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return "principal:" + p.ID
+	}
+	return ipKey(r)
+}
+
+func headerKey(header string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(header); v != "" {
+			return "header:" + v
+		}
+		return ipKey(r)
+	}
+}
+
+func routeKey(pattern string) KeyFunc {
+	return func(r *http.Request) string {
+		if pattern == "" {
+			return "route:" + r.URL.Path
+		}
+		return "route:" + pattern
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+)
+
+func TestNewKeyFunc(t *testing.T) {
+	request := func(remote string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/add", nil)
+		r.RemoteAddr = remote
+		return r
+	}
+	withHeader := func(r *http.Request, name, value string) *http.Request {
+		r.Header.Set(name, value)
+		return r
+	}
+	authenticated := func(r *http.Request) *http.Request {
+		return r.WithContext(auth.NewContext(r.Context(), &auth.Principal{ID: "apikey:1"}))
+	}
+
+	tests := []struct {
+		name string
+		opts KeyOptions
+		req  *http.Request
+		want string
+	}{
+		{"DefaultIP", KeyOptions{}, request("10.0.0.1:1234"), "ip:10.0.0.1"},
+		{"IP", KeyOptions{Kind: KeyIP}, request("[2001:db8::1]:1234"), "ip:2001:db8::1"},
+		{"CIDRv4", KeyOptions{Kind: KeyCIDR}, request("10.1.2.3:1234"), "cidr:10.1.2.0/24"},
+		{"CIDRv4Custom", KeyOptions{Kind: KeyCIDR, IPv4Prefix: 16}, request("10.1.2.3:1234"), "cidr:10.1.0.0/16"},
+		{"CIDRv6", KeyOptions{Kind: KeyCIDR}, request("[2001:db8:1:2:3::4]:1234"), "cidr:2001:db8:1:2::/64"},
+		{"CIDRv4Mapped", KeyOptions{Kind: KeyCIDR}, request("[::ffff:10.1.2.3]:1234"), "cidr:10.1.2.0/24"},
+		{"CIDRInvalidIP", KeyOptions{Kind: KeyCIDR}, request("unix"), "ip:unix"},
+		{"APIKey", KeyOptions{Kind: KeyAPIKey}, authenticated(request("10.0.0.1:1234")), "principal:apikey:1"},
+		{"APIKeyAnonymous", KeyOptions{Kind: KeyAPIKey}, request("10.0.0.1:1234"), "ip:10.0.0.1"},
+		{"Header", KeyOptions{Kind: KeyHeader, Header: "x-tenant"}, withHeader(request("10.0.0.1:1234"), "X-Tenant", "acme"), "header:acme"},
+		{"HeaderMissing", KeyOptions{Kind: KeyHeader, Header: "X-Tenant"}, request("10.0.0.1:1234"), "ip:10.0.0.1"},
+		{"Route", KeyOptions{Kind: KeyRoute, Route: "GET /api/v1/add"}, request("10.0.0.1:1234"), "route:GET /api/v1/add"},
+		{"RouteDefaultRule", KeyOptions{Kind: KeyRoute}, request("10.0.0.1:1234"), "route:/api/v1/add"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewKeyFunc(tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, key(tt.req))
+		})
+	}
+}
+
+func TestNewKeyFunc_Invalid(t *testing.T) {
+	tests := map[string]KeyOptions{
+		"Unknown":       {Kind: "user-agent"},
+		"HeaderMissing": {Kind: KeyHeader},
+		"IPv4Prefix":    {Kind: KeyCIDR, IPv4Prefix: 33},
+		"IPv6Prefix":    {Kind: KeyCIDR, IPv6Prefix: -1},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewKeyFunc(opts)
+			assert.Error(t, err)
+		})
+	}
+}
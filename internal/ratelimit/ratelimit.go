@@ -0,0 +1,154 @@
+// Package ratelimit 实现可插拔的限流算法、限流 key 与按路由的限流规则
+// 限流状态可以保存在进程内，也可以保存在多个副本共享的 Redis 中
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Algorithm 限流算法
+type Algorithm string
+
+// 支持的限流算法
+const (
+	// AlgorithmTokenBucket 令牌桶：以固定速率补充令牌，允许不超过桶容量的突发
+	AlgorithmTokenBucket Algorithm = "token_bucket"
+	// AlgorithmSlidingLog 滑动窗口日志：记录窗口内每个请求的时间，精确但内存与上限成正比
+	AlgorithmSlidingLog Algorithm = "sliding_log"
+	// AlgorithmSlidingWindow 滑动窗口计数：按上一窗口计数加权估算，内存恒定
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	// AlgorithmGCRA 通用信元速率算法：只保存理论到达时间，效果等价于令牌桶
+	AlgorithmGCRA Algorithm = "gcra"
+)
+
+// defaultWindow 滑动窗口算法未配置窗口时使用的窗口长度
+const defaultWindow = time.Second
+
+// Limit 限流参数
+// 令牌桶与 GCRA 以每秒 Rate 的速率补充额度，最多允许 Burst 个突发请求；
+// 滑动窗口算法在任意 Window 长度的时间内最多允许 Rate×Window 个请求，Burst 不生效
+type Limit struct {
+	Rate   float64
+	Burst  int
+	Window time.Duration
+}
+
+// Result 一次限流判定的结果
+type Result struct {
+	// Allowed 是否放行
+	Allowed bool
+	// Limit 该 key 可用的请求上限
+	Limit int
+	// Remaining 放行后剩余的请求数
+	Remaining int
+	// RetryAfter 被拒绝时距下一次可能放行的时间
+	RetryAfter time.Duration
+}
+
+// Limiter 按 key 限流
+type Limiter interface {
+	// Allow 为 key 消耗一次额度，仅在状态存储不可用且配置为拒绝请求时返回错误
+	Allow(ctx context.Context, key string) (Result, error)
+	// Stop 释放后台资源
+	Stop()
+}
+
+// New 创建使用指定算法的进程内限流器，algorithm 为空时使用令牌桶
+func New(algorithm Algorithm, limit Limit, opts Options) (Limiter, error) {
+	sp, err := newSpec(algorithm, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	switch sp.algorithm {
+	case AlgorithmGCRA:
+		return newMemoryLimiter[gcraState](gcra{interval: sp.interval, burst: sp.limit}, opts), nil
+	case AlgorithmSlidingLog:
+		return newMemoryLimiter[slidingLogState](slidingLog{limit: sp.limit, window: sp.window}, opts), nil
+	case AlgorithmSlidingWindow:
+		return newMemoryLimiter[slidingWindowState](slidingWindow{limit: sp.limit, window: sp.window}, opts), nil
+	default:
+		return newMemoryLimiter[tokenBucketState](tokenBucket{rate: sp.rate, burst: sp.limit}, opts), nil
+	}
+}
+
+// spec 校验并归一化后的算法参数，进程内与 Redis 实现共用
+type spec struct {
+	algorithm Algorithm
+	// limit 令牌桶与 GCRA 的突发容量，或滑动窗口内的请求上限
+	limit int
+	// rate 令牌桶每秒补充的令牌数
+	rate float64
+	// interval GCRA 补充一次额度的间隔
+	interval time.Duration
+	// window 滑动窗口长度
+	window time.Duration
+}
+
+func newSpec(algorithm Algorithm, limit Limit) (spec, error) {
+	if limit.Rate <= 0 || math.IsInf(limit.Rate, 0) || math.IsNaN(limit.Rate) {
+		return spec{}, fmt.Errorf("rate limit: rate must be a positive number, got %v", limit.Rate)
+	}
+
+	switch algorithm {
+	case AlgorithmTokenBucket, "", AlgorithmGCRA:
+		if algorithm == "" {
+			algorithm = AlgorithmTokenBucket
+		}
+		if limit.Burst < 1 {
+			return spec{}, fmt.Errorf("rate limit: %s requires burst >= 1, got %d", algorithm, limit.Burst)
+		}
+		return spec{
+			algorithm: algorithm,
+			limit:     limit.Burst,
+			rate:      limit.Rate,
+			interval:  time.Duration(float64(time.Second) / limit.Rate),
+		}, nil
+	case AlgorithmSlidingLog, AlgorithmSlidingWindow:
+		window := limit.Window
+		if window <= 0 {
+			window = defaultWindow
+		}
+		n := int(limit.Rate * window.Seconds())
+		if n < 1 {
+			return spec{}, fmt.Errorf("rate limit: %s allows no requests per %s at rate %v", algorithm, window, limit.Rate)
+		}
+		return spec{algorithm: algorithm, limit: n, window: window}, nil
+	default:
+		return spec{}, fmt.Errorf("rate limit: unsupported algorithm %q", algorithm)
+	}
+}
+
+// algorithm 在单个 key 的状态上执行限流判定，状态的零值表示全新的 key
+type algorithm[S any] interface {
+	take(state *S, now time.Time) Result
+	// idle 状态空闲多久后等价于零值状态
+	idle() time.Duration
+}
+
+// memoryLimiter 将算法状态保存在进程内的状态表中
+type memoryLimiter[S any] struct {
+	alg   algorithm[S]
+	table *table[S]
+}
+
+func newMemoryLimiter[S any](alg algorithm[S], opts Options) *memoryLimiter[S] {
+	return &memoryLimiter[S]{alg: alg, table: newTable[S](opts, alg.idle())}
+}
+
+// Allow 为 key 消耗一次额度
+func (m *memoryLimiter[S]) Allow(_ context.Context, key string) (Result, error) {
+	var res Result
+	m.table.do(key, func(state *S, now time.Time) {
+		res = m.alg.take(state, now)
+	})
+	return res, nil
+}
+
+// Stop 停止后台清理
+func (m *memoryLimiter[S]) Stop() {
+	m.table.Stop()
+}
@@ -0,0 +1,220 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+// 限流脚本在 Redis 中原子执行，统一使用 Redis 服务端时间（微秒）以避免副本间的时钟偏差，
+// 返回 {是否放行, 上限, 剩余, 重试等待微秒数}。
+// 时间戳超过 Lua 默认数字格式的精度，写入时使用 %.0f 格式化。
+var (
+	// tokenBucketScript ARGV: 每秒速率, 桶容量
+	tokenBucketScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+if tokens == nil then
+  tokens = burst
+else
+  local elapsed = math.max(0, now - tonumber(state[2]))
+  tokens = math.min(burst, tokens + elapsed / 1000000 * rate)
+end
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate * 1000000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', string.format('%.17g', tokens), 'ts', string.format('%.0f', now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, burst, math.floor(tokens), retry}
+`)
+
+	// gcraScript ARGV: 补充间隔微秒数, 突发容量
+	gcraScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local tolerance = interval * burst
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil or tat < now then
+  tat = now
+end
+local nxt = tat + interval
+
+local allow_at = nxt - tolerance
+if now < allow_at then
+  return {0, burst, 0, allow_at - now}
+end
+
+redis.call('SET', KEYS[1], string.format('%.0f', nxt), 'PX', math.ceil((nxt - now) / 1000) + 1000)
+return {1, burst, math.floor((tolerance - (nxt - now)) / interval), 0}
+`)
+
+	// slidingLogScript ARGV: 窗口内请求上限, 窗口微秒数
+	slidingLogScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', string.format('%.0f', now - window))
+local count = redis.call('ZCARD', KEYS[1])
+if count >= limit then
+  local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+  return {0, limit, 0, tonumber(oldest[2]) + window - now}
+end
+
+-- 同一微秒内的请求以当前数量区分成员
+local ts = string.format('%.0f', now)
+redis.call('ZADD', KEYS[1], ts, ts .. '-' .. count)
+redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000) + 1000)
+return {1, limit, limit - count - 1, 0}
+`)
+
+	// slidingWindowScript ARGV: 窗口内请求上限, 窗口微秒数
+	slidingWindowScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local start = now - now % window
+
+local state = redis.call('HMGET', KEYS[1], 'start', 'prev', 'curr')
+local ws, prev, curr = tonumber(state[1]), tonumber(state[2]) or 0, tonumber(state[3]) or 0
+if ws ~= start then
+  if ws ~= nil and start - ws == window then
+    prev = curr
+  else
+    prev = 0
+  end
+  curr = 0
+end
+
+local elapsed = now - start
+local estimated = prev * (1 - elapsed / window) + curr
+local allowed, remaining, retry = 0, 0, 0
+if estimated + 1 > limit then
+  retry = start + window - now
+  if prev > 0 and curr + 1 <= limit then
+    retry = math.max(window - math.floor(window * (limit - curr - 1) / prev) - elapsed, 0)
+  end
+else
+  curr = curr + 1
+  allowed = 1
+  remaining = math.max(math.floor(limit - estimated - 1), 0)
+end
+
+redis.call('HSET', KEYS[1], 'start', string.format('%.0f', start), 'prev', prev, 'curr', curr)
+redis.call('PEXPIRE', KEYS[1], math.ceil(2 * window / 1000) + 1000)
+return {allowed, limit, remaining, retry}
+`)
+)
+
+// failureLogInterval 存储不可用时记录日志的最小间隔，避免每个请求都写一条日志
+const failureLogInterval = 10 * time.Second
+
+// RedisOptions RedisStore 的可选配置
+type RedisOptions struct {
+	// Prefix 限流状态 key 的前缀
+	Prefix string
+	// FailOpen Redis 不可用时放行请求；为 false 时 Allow 返回错误，由调用方拒绝请求
+	FailOpen bool
+}
+
+// RedisStore 将限流状态保存在 Redis 中，多个副本共享同一份额度
+// 每次判定执行一个 Lua 脚本，读取、计算与写回在 Redis 中原子完成
+type RedisStore struct {
+	client      redis.UniversalClient
+	opts        RedisOptions
+	lastFailure atomic.Int64
+}
+
+// NewRedisStore 创建 Redis 存储，关闭存储时会关闭 client
+func NewRedisStore(client redis.UniversalClient, opts RedisOptions) *RedisStore {
+	return &RedisStore{client: client, opts: opts}
+}
+
+// Limiter 创建状态保存在 Redis 中的限流器
+func (s *RedisStore) Limiter(name string, algorithm Algorithm, limit Limit) (Limiter, error) {
+	sp, err := newSpec(algorithm, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &redisLimiter{store: s, prefix: s.opts.Prefix + name + ":", limit: sp.limit}
+	switch sp.algorithm {
+	case AlgorithmGCRA:
+		l.script, l.args = gcraScript, []any{sp.interval.Microseconds(), sp.limit}
+	case AlgorithmSlidingLog:
+		l.script, l.args = slidingLogScript, []any{sp.limit, sp.window.Microseconds()}
+	case AlgorithmSlidingWindow:
+		l.script, l.args = slidingWindowScript, []any{sp.limit, sp.window.Microseconds()}
+	default:
+		l.script, l.args = tokenBucketScript, []any{sp.rate, sp.limit}
+	}
+	return l, nil
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// fail 按配置处理 Redis 错误：放行请求或返回错误
+func (s *RedisStore) fail(err error, limit int) (Result, error) {
+	now := time.Now().UnixNano()
+	if last := s.lastFailure.Load(); now-last >= int64(failureLogInterval) && s.lastFailure.CompareAndSwap(last, now) {
+		logger.Error("Rate limit store unavailable", zap.Error(err), zap.Bool("fail_open", s.opts.FailOpen))
+	}
+	if s.opts.FailOpen {
+		return Result{Allowed: true, Limit: limit}, nil
+	}
+	return Result{}, fmt.Errorf("rate limit store: %w", err)
+}
+
+// redisLimiter 使用 Redis 脚本执行限流算法
+type redisLimiter struct {
+	store  *RedisStore
+	script *redis.Script
+	args   []any
+	prefix string
+	limit  int
+}
+
+// Allow 为 key 消耗一次额度
+func (l *redisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	res, err := l.script.Run(ctx, l.store.client, []string{l.prefix + key}, l.args...).Int64Slice()
+	if err == nil && len(res) != 4 {
+		err = fmt.Errorf("unexpected script result %v", res)
+	}
+	if err != nil {
+		return l.store.fail(err, l.limit)
+	}
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      int(res[1]),
+		Remaining:  int(res[2]),
+		RetryAfter: time.Duration(res[3]) * time.Microsecond,
+	}, nil
+}
+
+// Stop 连接由 RedisStore 统一关闭，限流器无需释放资源
+func (l *redisLimiter) Stop() {}
@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis 启动进程内的 RESP 服务器，时钟固定在 testStart
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	m := miniredis.RunT(t)
+	m.SetTime(testStart)
+	return m
+}
+
+// newTestRedisStore 创建连接到 m 的存储，模拟一个副本
+func newTestRedisStore(t *testing.T, m *miniredis.Miniredis, failOpen bool) *RedisStore {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: m.Addr(), MaxRetries: -1})
+	s := NewRedisStore(client, RedisOptions{Prefix: "test:", FailOpen: failOpen})
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// newRedisTestLimiter 创建状态保存在进程内 RESP 服务器中的限流器
+func newRedisTestLimiter(t *testing.T, alg Algorithm, limit Limit) (Limiter, func(time.Duration)) {
+	t.Helper()
+	m := newTestRedis(t)
+	l, err := newTestRedisStore(t, m, false).Limiter("rule", alg, limit)
+	require.NoError(t, err)
+
+	now := testStart
+	return l, func(d time.Duration) {
+		now = now.Add(d)
+		m.SetTime(now)
+		m.FastForward(d)
+	}
+}
+
+func TestRedisStore_SharedAcrossReplicas(t *testing.T) {
+	m := newTestRedis(t)
+	limit := Limit{Rate: 1, Burst: 2}
+
+	a, err := newTestRedisStore(t, m, false).Limiter("default", AlgorithmTokenBucket, limit)
+	require.NoError(t, err)
+	b, err := newTestRedisStore(t, m, false).Limiter("default", AlgorithmTokenBucket, limit)
+	require.NoError(t, err)
+	other, err := newTestRedisStore(t, m, false).Limiter("GET /api/v1/divide", AlgorithmTokenBucket, limit)
+	require.NoError(t, err)
+
+	// 两个副本共享同一规则的额度
+	assert.True(t, allow(t, a, "ip:10.0.0.1").Allowed)
+	assert.True(t, allow(t, b, "ip:10.0.0.1").Allowed)
+	assert.False(t, allow(t, a, "ip:10.0.0.1").Allowed)
+	assert.False(t, allow(t, b, "ip:10.0.0.1").Allowed)
+
+	// 不同规则的状态互不影响
+	assert.True(t, allow(t, other, "ip:10.0.0.1").Allowed)
+	assert.True(t, m.Exists("test:default:ip:10.0.0.1"))
+	assert.True(t, m.Exists("test:GET /api/v1/divide:ip:10.0.0.1"))
+}
+
+func TestRedisStore_Atomic(t *testing.T) {
+	m := newTestRedis(t)
+
+	for _, alg := range []Algorithm{AlgorithmTokenBucket, AlgorithmGCRA, AlgorithmSlidingLog, AlgorithmSlidingWindow} {
+		t.Run(string(alg), func(t *testing.T) {
+			var allowed atomic.Int64
+			var wg sync.WaitGroup
+			// 多个副本并发请求，放行总数不超过上限
+			for range 4 {
+				l, err := newTestRedisStore(t, m, false).Limiter(string(alg), alg, Limit{Rate: 10, Burst: 10, Window: time.Second})
+				require.NoError(t, err)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 25 {
+						res, err := l.Allow(context.Background(), "k")
+						if assert.NoError(t, err) && res.Allowed {
+							allowed.Add(1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int64(10), allowed.Load())
+		})
+	}
+}
+
+func TestRedisStore_Expiry(t *testing.T) {
+	m := newTestRedis(t)
+	l, err := newTestRedisStore(t, m, false).Limiter("rule", AlgorithmTokenBucket, Limit{Rate: 1, Burst: 5})
+	require.NoError(t, err)
+
+	allow(t, l, "k")
+	// 状态在桶回满后过期，不会在 Redis 中无限累积
+	ttl := m.TTL("test:rule:k")
+	assert.Greater(t, ttl, 5*time.Second)
+	assert.LessOrEqual(t, ttl, 6*time.Second)
+}
+
+func TestRedisStore_Unavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		failOpen bool
+	}{
+		{"FailOpen", true},
+		{"FailClosed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestRedis(t)
+			l, err := newTestRedisStore(t, m, tt.failOpen).Limiter("rule", AlgorithmGCRA, Limit{Rate: 1, Burst: 1})
+			require.NoError(t, err)
+			m.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			res, err := l.Allow(ctx, "k")
+			if tt.failOpen {
+				require.NoError(t, err)
+				assert.True(t, res.Allowed)
+			} else {
+				assert.Error(t, err)
+				assert.False(t, res.Allowed)
+			}
+		})
+	}
+}
+
+func TestRedisStore_InvalidLimit(t *testing.T) {
+	m := newTestRedis(t)
+	_, err := newTestRedisStore(t, m, false).Limiter("rule", AlgorithmTokenBucket, Limit{Rate: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Rule 一条限流规则
+type Rule struct {
+	// Pattern ServeMux 风格的路由模式，例如 "GET /api/v1/divide"，默认规则为空
+	Pattern string
+	// Limiter 限流器，为 nil 表示命中该规则的请求不限流
+	Limiter Limiter
+	// Key 请求所属的限流 key
+	Key KeyFunc
+}
+
+// Rules 默认限流规则与按路由覆盖的规则
+// 路由使用 Go 1.22 ServeMux 的模式语法，请求只按最具体的路由模式匹配，
+// 未被任何模式覆盖的请求使用默认规则
+type Rules struct {
+	def    *Rule
+	mux    *http.ServeMux
+	routes map[string]*Rule
+}
+
+// NewRules 创建限流规则，def 为默认规则；返回错误时会停止传入的全部限流器
+func NewRules(def Rule, routes []Rule) (rules *Rules, err error) {
+	rs := &Rules{
+		def:    &def,
+		mux:    http.NewServeMux(),
+		routes: make(map[string]*Rule, len(routes)),
+	}
+
+	defer func() {
+		// ServeMux 在模式非法或冲突时 panic，转换为配置错误
+		if r := recover(); r != nil {
+			err = fmt.Errorf("rate limit: invalid route pattern: %v", r)
+		}
+		if err != nil {
+			stopAll(append([]Rule{def}, routes...))
+			rules = nil
+		}
+	}()
+
+	for i := range routes {
+		rule := &routes[i]
+		if rule.Pattern == "" {
+			return nil, errors.New("rate limit: route rule requires a pattern")
+		}
+		rs.mux.Handle(rule.Pattern, http.NotFoundHandler())
+		rs.routes[rule.Pattern] = rule
+	}
+	return rs, nil
+}
+
+// Match 返回请求命中的规则
+func (rs *Rules) Match(r *http.Request) *Rule {
+	if len(rs.routes) > 0 {
+		if _, pattern := rs.mux.Handler(r); pattern != "" {
+			if rule, ok := rs.routes[pattern]; ok {
+				return rule
+			}
+		}
+	}
+	return rs.def
+}
+
+// Stop 释放全部限流器的后台资源
+func (rs *Rules) Stop() {
+	rules := []Rule{*rs.def}
+	for _, rule := range rs.routes {
+		rules = append(rules, *rule)
+	}
+	stopAll(rules)
+}
+
+func stopAll(rules []Rule) {
+	for _, rule := range rules {
+		if rule.Limiter != nil {
+			rule.Limiter.Stop()
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLimiter 记录是否已停止
+type stubLimiter struct {
+	stopped bool
+}
+
+func (s *stubLimiter) Allow(context.Context, string) (Result, error) {
+	return Result{Allowed: true}, nil
+}
+
+func (s *stubLimiter) Stop() { s.stopped = true }
+
+func TestRules_Match(t *testing.T) {
+	def := &stubLimiter{}
+	divide := &stubLimiter{}
+	batch := &stubLimiter{}
+	rules, err := NewRules(Rule{Limiter: def}, []Rule{
+		{Pattern: "/healthz"},
+		{Pattern: "/api/v1/divide", Limiter: divide},
+		{Pattern: "POST /api/v1/batch", Limiter: batch},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		pattern string
+		limiter Limiter
+	}{
+		{"Default", http.MethodGet, "/api/v1/add", "", def},
+		{"Unlimited", http.MethodGet, "/healthz", "/healthz", nil},
+		{"Route", http.MethodGet, "/api/v1/divide", "/api/v1/divide", divide},
+		{"RouteMethod", http.MethodPost, "/api/v1/batch", "POST /api/v1/batch", batch},
+		// 方法不匹配时使用默认规则
+		{"RouteOtherMethod", http.MethodGet, "/api/v1/batch", "", def},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := rules.Match(httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.pattern, rule.Pattern)
+			if tt.limiter == nil {
+				assert.Nil(t, rule.Limiter)
+			} else {
+				assert.Same(t, tt.limiter, rule.Limiter)
+			}
+		})
+	}
+
+	rules.Stop()
+	assert.True(t, def.stopped)
+	assert.True(t, divide.stopped)
+	assert.True(t, batch.stopped)
+}
+
+func TestNewRules_Invalid(t *testing.T) {
+	tests := map[string][]Rule{
+		"MissingPattern":   {{Pattern: ""}},
+		"InvalidPattern":   {{Pattern: "/a/{id"}},
+		"DuplicatePattern": {{Pattern: "/a"}, {Pattern: "/a"}},
+	}
+
+	for name, routes := range tests {
+		t.Run(name, func(t *testing.T) {
+			def := &stubLimiter{}
+			route := &stubLimiter{}
+			routes[0].Limiter = route
+
+			_, err := NewRules(Rule{Limiter: def}, routes)
+			assert.Error(t, err)
+			// 创建失败时释放全部限流器
+			assert.True(t, def.stopped)
+			assert.True(t, route.stopped)
+		})
+	}
+}
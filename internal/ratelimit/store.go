@@ -0,0 +1,29 @@
+package ratelimit
+
+// Store 限流状态存储，为每条限流规则创建状态保存在该存储中的限流器
+type Store interface {
+	// Limiter 创建限流器，name 区分不同规则的状态，同名规则在共享存储的多个副本间共享额度
+	Limiter(name string, algorithm Algorithm, limit Limit) (Limiter, error)
+	// Close 释放存储的连接
+	Close() error
+}
+
+// MemoryStore 进程内存储，每个副本独立计数
+type MemoryStore struct {
+	opts Options
+}
+
+// NewMemoryStore 创建进程内存储
+func NewMemoryStore(opts Options) *MemoryStore {
+	return &MemoryStore{opts: opts}
+}
+
+// Limiter 创建进程内限流器，每个限流器拥有独立的状态表
+func (s *MemoryStore) Limiter(_ string, algorithm Algorithm, limit Limit) (Limiter, error) {
+	return New(algorithm, limit, s.opts)
+}
+
+// Close 进程内存储无需释放连接
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+const (
+	defaultShards          = 32
+	defaultIdleTTL         = 10 * time.Minute
+	defaultMaxKeys         = 100_000
+	defaultJanitorInterval = time.Minute
+)
+
+// Options 进程内限流状态表的可选配置，零值字段使用默认值
+type Options struct {
+	// IdleTTL key 空闲多久后释放其状态，默认 10 分钟；
+	// 不会短于算法状态恢复为初始值所需的时间，保证淘汰后重建的状态与保留的状态一致
+	IdleTTL time.Duration
+	// MaxKeys 最多同时跟踪的 key 数，超出时淘汰最久未访问的，默认 100000
+	MaxKeys int
+	// Shards 分片数，降低锁竞争，默认 32
+	Shards int
+	// CleanupInterval 后台清理空闲 key 的间隔，默认 1 分钟
+	CleanupInterval time.Duration
+	// Now 当前时间，默认 time.Now，测试中可替换为可控时钟
+	Now func() time.Time
+}
+
+// table 按 key 保存限流状态
+// key 按哈希分布到多个分片，每个分片独立加锁并维护 LRU 链表；
+// 后台 janitor 定期淘汰空闲 key，Stop 后退出
+type table[S any] struct {
+	shards  []*shard[S]
+	seed    maphash.Seed
+	idleTTL time.Duration
+	now     func() time.Time
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// shard 一个分片，链表头部为最近访问的 key
+type shard[S any] struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type entry[S any] struct {
+	key      string
+	state    S
+	lastSeen time.Time
+}
+
+// newTable 创建状态表并启动后台清理，minIdle 为算法状态恢复为初始值所需的时间
+func newTable[S any](opts Options, minIdle time.Duration) *table[S] {
+	if opts.Shards <= 0 {
+		opts.Shards = defaultShards
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = defaultMaxKeys
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = defaultIdleTTL
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = defaultJanitorInterval
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	t := &table[S]{
+		shards: make([]*shard[S], opts.Shards),
+		seed:   maphash.MakeSeed(),
+		// 空闲时间超过 minIdle 后，淘汰并重建的状态与原状态等价，不会给客户端额外的突发额度
+		idleTTL: max(opts.IdleTTL, minIdle),
+		now:     opts.Now,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	// 容量按分片均分，向上取整
+	capacity := (opts.MaxKeys + opts.Shards - 1) / opts.Shards
+	for n := range t.shards {
+		t.shards[n] = &shard[S]{capacity: capacity, ll: list.New(), items: make(map[string]*list.Element)}
+	}
+
+	go t.janitor(opts.CleanupInterval)
+
+	return t
+}
+
+// do 在分片锁内以 key 的状态调用 fn，key 不存在时以零值状态创建
+func (t *table[S]) do(key string, fn func(state *S, now time.Time)) {
+	s := t.shards[maphash.String(t.seed, key)%uint64(len(t.shards))]
+	now := t.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		e := elem.Value.(*entry[S]) //nolint:forcetypeassert // list 中只存放 *entry[S]
+		e.lastSeen = now
+		s.ll.MoveToFront(elem)
+		fn(&e.state, now)
+		return
+	}
+
+	e := &entry[S]{key: key, lastSeen: now}
+	s.items[key] = s.ll.PushFront(e)
+	for s.ll.Len() > s.capacity {
+		s.removeOldest()
+	}
+	fn(&e.state, now)
+}
+
+// len 返回当前跟踪的 key 数
+func (t *table[S]) len() int {
+	n := 0
+	for _, s := range t.shards {
+		s.mu.Lock()
+		n += s.ll.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stop 停止后台清理并等待其退出，可重复调用
+func (t *table[S]) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+	<-t.done
+}
+
+// janitor 定期淘汰空闲 key，直到 Stop 被调用
+func (t *table[S]) janitor(interval time.Duration) {
+	defer close(t.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			if n := t.evictIdle(); n > 0 {
+				logger.Debug("Evicted idle rate limit keys", zap.Int("count", n))
+			}
+		}
+	}
+}
+
+// evictIdle 淘汰空闲超过 idleTTL 的 key，返回淘汰数量
+func (t *table[S]) evictIdle() int {
+	deadline := t.now().Add(-t.idleTTL)
+	evicted := 0
+	for _, s := range t.shards {
+		s.mu.Lock()
+		// 链表按访问时间排序，从尾部开始淘汰直到遇到活跃 key
+		for elem := s.ll.Back(); elem != nil; elem = s.ll.Back() {
+			if elem.Value.(*entry[S]).lastSeen.After(deadline) { //nolint:forcetypeassert // 同上
+				break
+			}
+			s.removeOldest()
+			evicted++
+		}
+		s.mu.Unlock()
+	}
+	return evicted
+}
+
+// removeOldest 移除最久未访问的 key，调用方需持有锁
+func (s *shard[S]) removeOldest() {
+	elem := s.ll.Back()
+	if elem == nil {
+		return
+	}
+	s.ll.Remove(elem)
+	delete(s.items, elem.Value.(*entry[S]).key) //nolint:forcetypeassert // 同上
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+func init() {
+	_ = logger.Initialize(true)
+}
+
+// testClock 可控时钟
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestTable 创建使用可控时钟的状态表，janitor 间隔足够长，由测试手动触发淘汰
+func newTestTable(t *testing.T, opts Options, minIdle time.Duration) (*table[int], *testClock) {
+	t.Helper()
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	opts.CleanupInterval = time.Hour
+	opts.Now = clock.Now
+	tb := newTable[int](opts, minIdle)
+	t.Cleanup(tb.Stop)
+	return tb, clock
+}
+
+// incr 递增 key 的计数并返回递增后的值
+func incr(tb *table[int], key string) int {
+	var n int
+	tb.do(key, func(state *int, _ time.Time) {
+		*state++
+		n = *state
+	})
+	return n
+}
+
+func TestTable_ReusesState(t *testing.T) {
+	tb, _ := newTestTable(t, Options{}, 0)
+
+	assert.Equal(t, 1, incr(tb, "a"))
+	assert.Equal(t, 2, incr(tb, "a"))
+	assert.Equal(t, 1, incr(tb, "b"))
+	assert.Equal(t, 2, tb.len())
+}
+
+func TestTable_EvictIdle(t *testing.T) {
+	tb, clock := newTestTable(t, Options{IdleTTL: time.Minute}, 0)
+
+	incr(tb, "a")
+	incr(tb, "b")
+
+	clock.Advance(45 * time.Second)
+	// 再次访问刷新最后访问时间
+	incr(tb, "b")
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, 1, tb.evictIdle())
+	assert.Equal(t, 1, tb.len())
+	assert.Equal(t, 1, incr(tb, "a"), "evicted key starts from a fresh state")
+
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, 2, tb.evictIdle())
+	assert.Equal(t, 0, tb.len())
+}
+
+func TestTable_IdleTTLCoversReset(t *testing.T) {
+	tb, _ := newTestTable(t, Options{IdleTTL: time.Second}, 500*time.Second)
+	assert.Equal(t, 500*time.Second, tb.idleTTL)
+}
+
+func TestTable_MaxKeysEvictsLRU(t *testing.T) {
+	// 单分片时容量即全局上限，淘汰顺序可预测
+	tb, _ := newTestTable(t, Options{MaxKeys: 3, Shards: 1}, 0)
+
+	incr(tb, "a")
+	incr(tb, "b")
+	incr(tb, "c")
+	// 访问 a 后 b 成为最久未访问的 key
+	incr(tb, "a")
+	incr(tb, "d")
+
+	assert.Equal(t, 3, tb.len())
+	s := tb.shards[0]
+	assert.NotContains(t, s.items, "b")
+	assert.Contains(t, s.items, "c")
+	assert.Contains(t, s.items, "d")
+	assert.Equal(t, 3, incr(tb, "a"))
+}
+
+func TestTable_MaxKeysSharded(t *testing.T) {
+	tb, _ := newTestTable(t, Options{MaxKeys: 64, Shards: 8}, 0)
+
+	for i := range 10_000 {
+		incr(tb, fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	assert.LessOrEqual(t, tb.len(), 64)
+}
+
+func TestTable_JanitorEvictsIdle(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		now = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	tb := newTable[int](Options{IdleTTL: time.Minute, CleanupInterval: time.Millisecond, Now: clock}, 0)
+	t.Cleanup(tb.Stop)
+
+	incr(tb, "a")
+	assert.Equal(t, 1, tb.len())
+
+	// 空闲超过 IdleTTL 后由后台 janitor 淘汰，无需手动触发
+	mu.Lock()
+	now = now.Add(2 * time.Minute)
+	mu.Unlock()
+	assert.Eventually(t, func() bool { return tb.len() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestTable_Stop(t *testing.T) {
+	tb := newTable[int](Options{CleanupInterval: time.Millisecond}, 0)
+	incr(tb, "a")
+
+	stopped := make(chan struct{})
+	go func() {
+		tb.Stop()
+		// 重复调用不会阻塞或 panic
+		tb.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		require.Fail(t, "janitor did not stop")
+	}
+}
+
+func TestTable_Concurrent(t *testing.T) {
+	tb, _ := newTestTable(t, Options{MaxKeys: 100, Shards: 4}, 0)
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				incr(tb, fmt.Sprintf("%d-%d", g, i%200))
+				if i%100 == 0 {
+					tb.evictIdle()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, tb.len(), 100)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/pkg/errors"
+)
+
+// APIKeyRepository 定义 API 密钥数据访问接口
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	// GetByPrefix 按前缀查找密钥（包括已吊销的），不存在时返回 NotFound 错误
+	GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	// List 按创建顺序返回全部密钥
+	List(ctx context.Context) ([]model.APIKey, error)
+	// Revoke 吊销密钥，不存在或已吊销时返回 NotFound 错误
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	// TouchLastUsed 更新最近使用时间
+	TouchLastUsed(ctx context.Context, id uint, at time.Time) error
+}
+
+var errAPIKeyNotFound = errors.New(errors.ErrTypeNotFound, "API key not found")
+
+type GormAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository 创建 APIKeyRepository 实例
+func NewAPIKeyRepository(db *gorm.DB) *GormAPIKeyRepository {
+	return &GormAPIKeyRepository{db: db}
+}
+
+func (r *GormAPIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	return conn(ctx, r.db).Create(key).Error
+}
+
+func (r *GormAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := conn(ctx, r.db).Where("prefix = ?", prefix).First(&key).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *GormAPIKeyRepository) List(ctx context.Context) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	err := conn(ctx, r.db).Order("id asc").Find(&keys).Error
+	return keys, err
+}
+
+func (r *GormAPIKeyRepository) Revoke(ctx context.Context, id uint, at time.Time) error {
+	result := conn(ctx, r.db).Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *GormAPIKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	return conn(ctx, r.db).Model(&model.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/exiaohu/go-demo/internal/model"
+)
+
+// MemoryAPIKeyRepository 基于内存的 APIKeyRepository 实现
+type MemoryAPIKeyRepository struct {
+	mu     sync.RWMutex
+	keys   map[uint]model.APIKey
+	lastID uint
+}
+
+// NewMemoryAPIKeyRepository 创建内存版 APIKeyRepository 实例
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{keys: make(map[uint]model.APIKey)}
+}
+
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.keys {
+		if k.Prefix == key.Prefix {
+			return fmt.Errorf("duplicate api key prefix: %s", key.Prefix)
+		}
+	}
+
+	r.lastID++
+	key.ID = r.lastID
+	now := time.Now()
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = now
+	}
+	if key.UpdatedAt.IsZero() {
+		key.UpdatedAt = now
+	}
+	r.keys[key.ID] = *key
+
+	id := key.ID
+	recordUndo(ctx, func() {
+		r.mu.Lock()
+		delete(r.keys, id)
+		r.mu.Unlock()
+	})
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, k := range r.keys {
+		if k.Prefix == prefix {
+			return &k, nil
+		}
+	}
+	return nil, errAPIKeyNotFound
+}
+
+func (r *MemoryAPIKeyRepository) List(ctx context.Context) ([]model.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	keys := make([]model.APIKey, 0, len(r.keys))
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, id uint, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.keys[id]
+	if !ok || k.Revoked() {
+		return errAPIKeyNotFound
+	}
+	k.RevokedAt = &at
+	r.keys[id] = k
+
+	recordUndo(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if restored, ok := r.keys[id]; ok {
+			restored.RevokedAt = nil
+			r.keys[id] = restored
+		}
+	})
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if k, ok := r.keys[id]; ok {
+		k.LastUsedAt = &at
+		r.keys[id] = k
+	}
+	return nil
+}
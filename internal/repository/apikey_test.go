@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/pkg/errors"
+)
+
+func runAPIKeyRepositoryConformance(t *testing.T, newRepo func(t *testing.T) APIKeyRepository) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("CreateAndGetByPrefix", func(t *testing.T) {
+		repo := newRepo(t)
+
+		key := &model.APIKey{Name: "ci", Prefix: "abc123", Hash: "hash", Scopes: "calculate,read-history"}
+		require.NoError(t, repo.Create(ctx, key))
+		assert.NotZero(t, key.ID)
+
+		got, err := repo.GetByPrefix(ctx, "abc123")
+		require.NoError(t, err)
+		assert.Equal(t, "ci", got.Name)
+		assert.Equal(t, "hash", got.Hash)
+		assert.Equal(t, []string{"calculate", "read-history"}, got.ScopeList())
+		assert.False(t, got.Revoked())
+
+		_, err = repo.GetByPrefix(ctx, "missing")
+		assert.True(t, errors.IsNotFoundError(err))
+	})
+
+	t.Run("DuplicatePrefix", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(ctx, &model.APIKey{Name: "a", Prefix: "dup", Hash: "h1", Scopes: "admin"}))
+		assert.Error(t, repo.Create(ctx, &model.APIKey{Name: "b", Prefix: "dup", Hash: "h2", Scopes: "admin"}))
+	})
+
+	t.Run("ListInCreationOrder", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, name := range []string{"first", "second", "third"} {
+			require.NoError(t, repo.Create(ctx, &model.APIKey{Name: name, Prefix: name, Hash: "h", Scopes: "calculate"}))
+		}
+
+		keys, err := repo.List(ctx)
+		require.NoError(t, err)
+		names := make([]string, 0, len(keys))
+		for _, k := range keys {
+			names = append(names, k.Name)
+		}
+		assert.Equal(t, []string{"first", "second", "third"}, names)
+	})
+
+	t.Run("RevokeAndTouch", func(t *testing.T) {
+		repo := newRepo(t)
+
+		key := &model.APIKey{Name: "ci", Prefix: "p1", Hash: "h", Scopes: "calculate"}
+		require.NoError(t, repo.Create(ctx, key))
+
+		require.NoError(t, repo.TouchLastUsed(ctx, key.ID, now))
+		require.NoError(t, repo.Revoke(ctx, key.ID, now.Add(time.Hour)))
+
+		got, err := repo.GetByPrefix(ctx, "p1")
+		require.NoError(t, err)
+		assert.True(t, got.Revoked())
+		require.NotNil(t, got.LastUsedAt)
+		assert.True(t, now.Equal(*got.LastUsedAt))
+
+		// 重复吊销、吊销不存在的密钥都返回 NotFound
+		assert.True(t, errors.IsNotFoundError(repo.Revoke(ctx, key.ID, now)))
+		assert.True(t, errors.IsNotFoundError(repo.Revoke(ctx, 9999, now)))
+	})
+}
+
+func TestGormAPIKeyRepository_Conformance(t *testing.T) {
+	runAPIKeyRepositoryConformance(t, func(t *testing.T) APIKeyRepository {
+		return NewAPIKeyRepository(setupTestDB(t))
+	})
+}
+
+func TestMemoryAPIKeyRepository_Conformance(t *testing.T) {
+	runAPIKeyRepositoryConformance(t, func(_ *testing.T) APIKeyRepository {
+		return NewMemoryAPIKeyRepository()
+	})
+}
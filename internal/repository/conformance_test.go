@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/pkg/errors"
+)
+
+// historyRepositoryFactory 为每个子测试创建一个全新的、空的仓储实例
+type historyRepositoryFactory func(t *testing.T) HistoryRepository
+
+// runHistoryRepositoryConformance 所有 HistoryRepository 实现都必须通过的一致性测试
+func runHistoryRepositoryConformance(t *testing.T, newRepo historyRepositoryFactory) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("CreateAssignsIDAndTimestamps", func(t *testing.T) {
+		repo := newRepo(t)
+
+		first := &model.CalculationHistory{Operation: "add", A: 1, B: 2, Result: 3, ClientIP: "127.0.0.1"}
+		second := &model.CalculationHistory{Operation: "add", A: 2, B: 3, Result: 5, ClientIP: "127.0.0.1"}
+		require.NoError(t, repo.Create(ctx, first))
+		require.NoError(t, repo.Create(ctx, second))
+
+		assert.NotZero(t, first.ID)
+		assert.Greater(t, second.ID, first.ID)
+		assert.False(t, first.CreatedAt.IsZero())
+		assert.False(t, first.UpdatedAt.IsZero())
+	})
+
+	t.Run("CreateRejectsDuplicateID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(ctx, &model.CalculationHistory{ID: 7, Operation: "add"}))
+		assert.Error(t, repo.Create(ctx, &model.CalculationHistory{ID: 7, Operation: "subtract"}))
+	})
+
+	t.Run("ListOrdersByCreatedAtDesc", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// 插入顺序与创建时间顺序不同，验证按 created_at 而不是插入顺序排序
+		for i, op := range []string{"b", "c", "a"} {
+			offset := map[string]int{"a": 0, "b": 1, "c": 2}[op]
+			h := &model.CalculationHistory{Operation: op, A: i, CreatedAt: base.Add(time.Duration(offset) * time.Minute)}
+			require.NoError(t, repo.Create(ctx, h))
+		}
+
+		list, err := repo.List(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, list, 3)
+		assert.Equal(t, []string{"c", "b", "a"}, operations(list))
+	})
+
+	t.Run("ListBreaksTiesByIDDesc", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, op := range []string{"a", "b", "c"} {
+			require.NoError(t, repo.Create(ctx, &model.CalculationHistory{Operation: op, CreatedAt: base}))
+		}
+
+		list, err := repo.List(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "b", "a"}, operations(list))
+	})
+
+	t.Run("ListLimit", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := 0; i < 5; i++ {
+			h := &model.CalculationHistory{Operation: fmt.Sprintf("op%d", i), CreatedAt: base.Add(time.Duration(i) * time.Second)}
+			require.NoError(t, repo.Create(ctx, h))
+		}
+
+		tests := []struct {
+			limit    int
+			expected []string
+		}{
+			{limit: 2, expected: []string{"op4", "op3"}},
+			{limit: 10, expected: []string{"op4", "op3", "op2", "op1", "op0"}},
+			{limit: 0, expected: []string{}},
+			{limit: -1, expected: []string{"op4", "op3", "op2", "op1", "op0"}},
+		}
+		for _, tt := range tests {
+			list, err := repo.List(ctx, tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, operations(list), "limit=%d", tt.limit)
+		}
+	})
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		list, err := repo.List(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, list)
+	})
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		repo := newRepo(t)
+
+		kept := &model.CalculationHistory{Operation: "kept", CreatedAt: base}
+		deleted := &model.CalculationHistory{Operation: "deleted", CreatedAt: base.Add(time.Second)}
+		require.NoError(t, repo.Create(ctx, kept))
+		require.NoError(t, repo.Create(ctx, deleted))
+
+		require.NoError(t, repo.Delete(ctx, deleted.ID))
+
+		// 软删除的记录不再出现在列表中
+		list, err := repo.List(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kept"}, operations(list))
+
+		// 重复删除、删除不存在的记录都返回 NotFound
+		assert.True(t, errors.IsNotFoundError(repo.Delete(ctx, deleted.ID)))
+		assert.True(t, errors.IsNotFoundError(repo.Delete(ctx, 9999)))
+
+		// 软删除的记录仍占用主键，新记录不会复用其 ID
+		next := &model.CalculationHistory{Operation: "next"}
+		require.NoError(t, repo.Create(ctx, next))
+		assert.Greater(t, next.ID, deleted.ID)
+	})
+
+	t.Run("ConcurrentCreate", func(t *testing.T) {
+		repo := newRepo(t)
+
+		const n = 50
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- repo.Create(ctx, &model.CalculationHistory{Operation: "add", A: i})
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		list, err := repo.List(ctx, -1)
+		require.NoError(t, err)
+		assert.Len(t, list, n)
+
+		ids := make(map[uint]struct{}, n)
+		for _, h := range list {
+			ids[h.ID] = struct{}{}
+		}
+		assert.Len(t, ids, n, "IDs must be unique")
+	})
+}
+
+func operations(list []model.CalculationHistory) []string {
+	ops := make([]string, 0, len(list))
+	for _, h := range list {
+		ops = append(ops, h.Operation)
+	}
+	return ops
+}
+
+func TestGormHistoryRepository_Conformance(t *testing.T) {
+	runHistoryRepositoryConformance(t, func(t *testing.T) HistoryRepository {
+		return NewHistoryRepository(setupTestDB(t))
+	})
+}
+
+func TestMemoryHistoryRepository_Conformance(t *testing.T) {
+	runHistoryRepositoryConformance(t, func(_ *testing.T) HistoryRepository {
+		return NewMemoryHistoryRepository()
+	})
+}
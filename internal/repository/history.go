@@ -6,14 +6,21 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/pkg/errors"
 )
 
 // HistoryRepository 定义历史记录数据访问接口
+// 所有实现都必须通过 conformance_test.go 中的一致性测试
+// Delete 用于在一致性测试中校验软删除语义，同时供历史记录删除接口使用
 type HistoryRepository interface {
 	Create(ctx context.Context, history *model.CalculationHistory) error
 	List(ctx context.Context, limit int) ([]model.CalculationHistory, error)
+	Delete(ctx context.Context, id uint) error
 }
 
+// errHistoryNotFound 历史记录不存在（或已被软删除）
+var errHistoryNotFound = errors.New(errors.ErrTypeNotFound, "History not found")
+
 type GormHistoryRepository struct {
 	db *gorm.DB
 }
@@ -24,11 +31,24 @@ func NewHistoryRepository(db *gorm.DB) *GormHistoryRepository {
 }
 
 func (r *GormHistoryRepository) Create(ctx context.Context, history *model.CalculationHistory) error {
-	return r.db.WithContext(ctx).Create(history).Error
+	return conn(ctx, r.db).Create(history).Error
 }
 
+// List 按创建时间倒序返回历史记录，创建时间相同时按 ID 倒序保证结果稳定
 func (r *GormHistoryRepository) List(ctx context.Context, limit int) ([]model.CalculationHistory, error) {
 	var history []model.CalculationHistory
-	err := r.db.WithContext(ctx).Order("created_at desc").Limit(limit).Find(&history).Error
+	err := conn(ctx, r.db).Order("created_at desc, id desc").Limit(limit).Find(&history).Error
 	return history, err
 }
+
+// Delete 软删除指定记录，记录不存在时返回 NotFound 错误
+func (r *GormHistoryRepository) Delete(ctx context.Context, id uint) error {
+	result := conn(ctx, r.db).Delete(&model.CalculationHistory{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errHistoryNotFound
+	}
+	return nil
+}
@@ -16,8 +16,13 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	assert.NoError(t, err)
 
+	// 内存数据库的每个连接都是独立的库，限制为单连接以支持并发测试
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
 	// 自动迁移
-	err = db.AutoMigrate(&model.CalculationHistory{})
+	err = db.AutoMigrate(&model.CalculationHistory{}, &model.APIKey{}, &model.QuotaUsage{})
 	assert.NoError(t, err)
 
 	return db
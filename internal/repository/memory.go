@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/exiaohu/go-demo/internal/model"
+)
+
+// MemoryHistoryRepository 基于内存的 HistoryRepository 实现
+// 适用于测试和无需持久化的临时运行模式，排序与软删除语义与 GormHistoryRepository 保持一致。
+// 配合 MemoryUnitOfWork 使用时，事务回滚会撤销其中的写入
+type MemoryHistoryRepository struct {
+	mu      sync.RWMutex
+	records map[uint]model.CalculationHistory
+	lastID  uint
+}
+
+// NewMemoryHistoryRepository 创建内存版 HistoryRepository 实例
+func NewMemoryHistoryRepository() *MemoryHistoryRepository {
+	return &MemoryHistoryRepository{
+		records: make(map[uint]model.CalculationHistory),
+	}
+}
+
+func (r *MemoryHistoryRepository) Create(ctx context.Context, history *model.CalculationHistory) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 与数据库自增主键保持一致：未指定 ID 时自动分配，指定的 ID 不允许重复
+	if history.ID == 0 {
+		history.ID = r.lastID + 1
+	} else if _, exists := r.records[history.ID]; exists {
+		return fmt.Errorf("duplicate primary key: %d", history.ID)
+	}
+	if history.ID > r.lastID {
+		r.lastID = history.ID
+	}
+
+	// 与 GORM 保持一致：零值时间字段自动填充
+	now := time.Now()
+	if history.CreatedAt.IsZero() {
+		history.CreatedAt = now
+	}
+	if history.UpdatedAt.IsZero() {
+		history.UpdatedAt = now
+	}
+
+	r.records[history.ID] = *history
+
+	id := history.ID
+	recordUndo(ctx, func() {
+		r.mu.Lock()
+		delete(r.records, id)
+		r.mu.Unlock()
+	})
+	return nil
+}
+
+func (r *MemoryHistoryRepository) List(ctx context.Context, limit int) ([]model.CalculationHistory, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	history := make([]model.CalculationHistory, 0, len(r.records))
+	for _, h := range r.records {
+		if h.DeletedAt.Valid {
+			continue
+		}
+		history = append(history, h)
+	}
+	r.mu.RUnlock()
+
+	// 按创建时间倒序，创建时间相同时按 ID 倒序保证结果稳定
+	sort.Slice(history, func(i, j int) bool {
+		if !history[i].CreatedAt.Equal(history[j].CreatedAt) {
+			return history[i].CreatedAt.After(history[j].CreatedAt)
+		}
+		return history[i].ID > history[j].ID
+	})
+
+	// 与 SQL LIMIT 语义一致：负数表示不限制
+	if limit >= 0 && limit < len(history) {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+// Delete 软删除指定记录，记录不存在时返回 NotFound 错误
+func (r *MemoryHistoryRepository) Delete(ctx context.Context, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, exists := r.records[id]
+	if !exists || h.DeletedAt.Valid {
+		return errHistoryNotFound
+	}
+	h.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.records[id] = h
+
+	recordUndo(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if restored, ok := r.records[id]; ok {
+			restored.DeletedAt = gorm.DeletedAt{}
+			r.records[id] = restored
+		}
+	})
+	return nil
+}
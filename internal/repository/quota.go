@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/exiaohu/go-demo/internal/model"
+)
+
+// QuotaRepository 定义配额用量数据访问接口
+type QuotaRepository interface {
+	// Increment 在累计值不超过 limit 时将计数加 n，返回操作后的计数以及是否增加成功
+	// 超出 limit 时计数保持不变，返回当前计数和 false
+	Increment(ctx context.Context, subject, period string, windowStart time.Time, n, limit int64) (int64, bool, error)
+	// Get 返回窗口内的计数，没有记录时返回 0
+	Get(ctx context.Context, subject, period string, windowStart time.Time) (int64, error)
+	// Decrement 将计数减 n，最低为 0，用于退还已扣减但请求最终失败的配额
+	Decrement(ctx context.Context, subject, period string, windowStart time.Time, n int64) error
+	// DeleteBefore 删除窗口开始时间早于 before 的记录，返回删除的行数
+	DeleteBefore(ctx context.Context, period string, before time.Time) (int64, error)
+}
+
+type GormQuotaRepository struct {
+	db *gorm.DB
+}
+
+// NewQuotaRepository 创建 QuotaRepository 实例
+func NewQuotaRepository(db *gorm.DB) *GormQuotaRepository {
+	return &GormQuotaRepository{db: db}
+}
+
+func (r *GormQuotaRepository) Increment(
+	ctx context.Context, subject, period string, windowStart time.Time, n, limit int64,
+) (int64, bool, error) {
+	db := conn(ctx, r.db)
+	windowStart = windowStart.UTC()
+
+	// 确保窗口行存在，并发插入由唯一索引去重
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.QuotaUsage{
+		Subject:     subject,
+		Period:      period,
+		WindowStart: windowStart,
+	}).Error
+	if err != nil {
+		return 0, false, err
+	}
+
+	// 条件更新保证并发下计数不会超过 limit
+	result := db.Model(&model.QuotaUsage{}).
+		Where("subject = ? AND period = ? AND window_start = ? AND count + ? <= ?", subject, period, windowStart, n, limit).
+		UpdateColumns(map[string]any{
+			"count":      gorm.Expr("count + ?", n),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, false, result.Error
+	}
+
+	count, err := r.Get(ctx, subject, period, windowStart)
+	return count, result.RowsAffected > 0, err
+}
+
+func (r *GormQuotaRepository) Decrement(ctx context.Context, subject, period string, windowStart time.Time, n int64) error {
+	return conn(ctx, r.db).Model(&model.QuotaUsage{}).
+		Where("subject = ? AND period = ? AND window_start = ?", subject, period, windowStart.UTC()).
+		UpdateColumns(map[string]any{
+			"count":      gorm.Expr("CASE WHEN count > ? THEN count - ? ELSE 0 END", n, n),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+func (r *GormQuotaRepository) DeleteBefore(ctx context.Context, period string, before time.Time) (int64, error) {
+	result := conn(ctx, r.db).
+		Where("period = ? AND window_start < ?", period, before.UTC()).
+		Delete(&model.QuotaUsage{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *GormQuotaRepository) Get(ctx context.Context, subject, period string, windowStart time.Time) (int64, error) {
+	var usage model.QuotaUsage
+	err := conn(ctx, r.db).
+		Where("subject = ? AND period = ? AND window_start = ?", subject, period, windowStart.UTC()).
+		First(&usage).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return usage.Count, err
+}
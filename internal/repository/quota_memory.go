@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type quotaKey struct {
+	subject     string
+	period      string
+	windowStart int64
+}
+
+// MemoryQuotaRepository 基于内存的 QuotaRepository 实现，进程退出后用量丢失
+type MemoryQuotaRepository struct {
+	mu     sync.Mutex
+	counts map[quotaKey]int64
+}
+
+// NewMemoryQuotaRepository 创建内存 QuotaRepository 实例
+func NewMemoryQuotaRepository() *MemoryQuotaRepository {
+	return &MemoryQuotaRepository{counts: make(map[quotaKey]int64)}
+}
+
+func (r *MemoryQuotaRepository) Increment(
+	ctx context.Context, subject, period string, windowStart time.Time, n, limit int64,
+) (int64, bool, error) {
+	key := quotaKey{subject: subject, period: period, windowStart: windowStart.Unix()}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.counts[key]
+	if count+n > limit {
+		return count, false, nil
+	}
+	r.counts[key] = count + n
+	recordUndo(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.counts[key] -= n
+	})
+	return count + n, true, nil
+}
+
+func (r *MemoryQuotaRepository) Decrement(
+	ctx context.Context, subject, period string, windowStart time.Time, n int64,
+) error {
+	key := quotaKey{subject: subject, period: period, windowStart: windowStart.Unix()}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count, ok := r.counts[key]
+	if !ok {
+		return nil
+	}
+	n = min(n, count)
+	r.counts[key] = count - n
+	recordUndo(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.counts[key] += n
+	})
+	return nil
+}
+
+func (r *MemoryQuotaRepository) DeleteBefore(_ context.Context, period string, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key := range r.counts {
+		if key.period == period && key.windowStart < before.Unix() {
+			delete(r.counts, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryQuotaRepository) Get(_ context.Context, subject, period string, windowStart time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[quotaKey{subject: subject, period: period, windowStart: windowStart.Unix()}], nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type quotaRepositoryFactory func(t *testing.T) (QuotaRepository, UnitOfWork)
+
+func runQuotaRepositoryConformance(t *testing.T, newRepo quotaRepositoryFactory) {
+	ctx := context.Background()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextDay := day.AddDate(0, 0, 1)
+
+	t.Run("IncrementUpToLimit", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		count, err := repo.Get(ctx, "apikey:1", "day", day)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		for i := int64(1); i <= 3; i++ {
+			count, ok, err := repo.Increment(ctx, "apikey:1", "day", day, 1, 3)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, i, count)
+		}
+
+		// 超出上限时计数不变
+		count, ok, err := repo.Increment(ctx, "apikey:1", "day", day, 1, 3)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, int64(3), count)
+
+		count, err = repo.Get(ctx, "apikey:1", "day", day)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("IsolatedWindowsAndSubjects", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		_, _, err := repo.Increment(ctx, "apikey:1", "day", day, 2, 10)
+		require.NoError(t, err)
+		_, _, err = repo.Increment(ctx, "apikey:2", "day", day, 3, 10)
+		require.NoError(t, err)
+		_, _, err = repo.Increment(ctx, "apikey:1", "day", nextDay, 4, 10)
+		require.NoError(t, err)
+		_, _, err = repo.Increment(ctx, "apikey:1", "month", day, 5, 10)
+		require.NoError(t, err)
+
+		for _, tt := range []struct {
+			subject, period string
+			window          time.Time
+			want            int64
+		}{
+			{"apikey:1", "day", day, 2},
+			{"apikey:2", "day", day, 3},
+			{"apikey:1", "day", nextDay, 4},
+			{"apikey:1", "month", day, 5},
+		} {
+			count, err := repo.Get(ctx, tt.subject, tt.period, tt.window)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, count, "%s %s %s", tt.subject, tt.period, tt.window)
+		}
+	})
+
+	t.Run("ConcurrentIncrementsNeverExceedLimit", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			granted int
+		)
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, ok, err := repo.Increment(ctx, "apikey:1", "day", day, 1, 10)
+				assert.NoError(t, err)
+				if ok {
+					mu.Lock()
+					granted++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 10, granted)
+		count, err := repo.Get(ctx, "apikey:1", "day", day)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), count)
+	})
+
+	t.Run("Decrement", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		_, _, err := repo.Increment(ctx, "apikey:1", "day", day, 3, 10)
+		require.NoError(t, err)
+		require.NoError(t, repo.Decrement(ctx, "apikey:1", "day", day, 2))
+		count, err := repo.Get(ctx, "apikey:1", "day", day)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		// 计数不会低于 0，没有记录时什么也不做
+		require.NoError(t, repo.Decrement(ctx, "apikey:1", "day", day, 5))
+		count, err = repo.Get(ctx, "apikey:1", "day", day)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		require.NoError(t, repo.Decrement(ctx, "apikey:2", "day", day, 1))
+	})
+
+	t.Run("DeleteBefore", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		_, _, err := repo.Increment(ctx, "apikey:1", "day", day, 1, 10)
+		require.NoError(t, err)
+		_, _, err = repo.Increment(ctx, "ip:10.0.0.1", "day", day, 1, 10)
+		require.NoError(t, err)
+		_, _, err = repo.Increment(ctx, "apikey:1", "day", nextDay, 2, 10)
+		require.NoError(t, err)
+		_, _, err = repo.Increment(ctx, "apikey:1", "month", day, 3, 10)
+		require.NoError(t, err)
+
+		deleted, err := repo.DeleteBefore(ctx, "day", nextDay)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		for _, tt := range []struct {
+			subject, period string
+			window          time.Time
+			want            int64
+		}{
+			{"apikey:1", "day", day, 0},
+			{"ip:10.0.0.1", "day", day, 0},
+			{"apikey:1", "day", nextDay, 2},
+			{"apikey:1", "month", day, 3},
+		} {
+			count, err := repo.Get(ctx, tt.subject, tt.period, tt.window)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, count, "%s %s %s", tt.subject, tt.period, tt.window)
+		}
+	})
+
+	t.Run("RollbackWithUnitOfWork", func(t *testing.T) {
+		repo, uow := newRepo(t)
+		errExceeded := errors.New("exceeded")
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			_, _, err := repo.Increment(ctx, "apikey:1", "day", day, 1, 10)
+			require.NoError(t, err)
+			return errExceeded
+		})
+		assert.ErrorIs(t, err, errExceeded)
+
+		count, err := repo.Get(ctx, "apikey:1", "day", day)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
+
+func TestGormQuotaRepository(t *testing.T) {
+	runQuotaRepositoryConformance(t, func(t *testing.T) (QuotaRepository, UnitOfWork) {
+		db := setupTestDB(t)
+		return NewQuotaRepository(db), NewUnitOfWork(db)
+	})
+}
+
+func TestMemoryQuotaRepository(t *testing.T) {
+	runQuotaRepositoryConformance(t, func(_ *testing.T) (QuotaRepository, UnitOfWork) {
+		return NewMemoryQuotaRepository(), NewMemoryUnitOfWork()
+	})
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork 工作单元，使多个仓储操作在同一事务中原子地执行
+type UnitOfWork interface {
+	// Do 在事务中执行 fn，事务通过 ctx 传递给 fn 内调用的仓储方法。
+	// fn 返回错误或发生 panic 时回滚（panic 会在回滚后继续向上抛出），否则提交。
+	// 在 fn 内部再次调用 Do 会创建保存点，内层失败只回滚内层的操作。
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type gormTxKey struct{}
+
+// GormUnitOfWork 基于 GORM 事务的 UnitOfWork 实现
+type GormUnitOfWork struct {
+	db *gorm.DB
+}
+
+// NewUnitOfWork 创建 GORM UnitOfWork 实例
+func NewUnitOfWork(db *gorm.DB) *GormUnitOfWork {
+	return &GormUnitOfWork{db: db}
+}
+
+// Do 开启事务；ctx 中已有事务时 GORM 会自动改用保存点
+func (u *GormUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return conn(ctx, u.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, gormTxKey{}, tx))
+	})
+}
+
+// conn 返回 ctx 中的事务连接，不在事务中时返回默认连接
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(gormTxKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+type memoryTxKey struct{}
+
+// memoryTx 内存事务，记录每个写操作的撤销函数
+type memoryTx struct {
+	mu   sync.Mutex
+	undo []func()
+}
+
+func (tx *memoryTx) record(undo func()) {
+	tx.mu.Lock()
+	tx.undo = append(tx.undo, undo)
+	tx.mu.Unlock()
+}
+
+// rollback 逆序执行撤销函数
+func (tx *memoryTx) rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+// merge 内层事务提交后，将其撤销记录并入外层事务，以便外层回滚时一并撤销
+func (tx *memoryTx) merge(child *memoryTx) {
+	child.mu.Lock()
+	undo := child.undo
+	child.undo = nil
+	child.mu.Unlock()
+
+	tx.mu.Lock()
+	tx.undo = append(tx.undo, undo...)
+	tx.mu.Unlock()
+}
+
+// recordUndo 在事务中时登记撤销函数，不在事务中时直接忽略
+func recordUndo(ctx context.Context, undo func()) {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		tx.record(undo)
+	}
+}
+
+// MemoryUnitOfWork 配合内存仓储使用的 UnitOfWork 实现
+// 通过撤销日志保证原子性，但不提供隔离性：未提交的写入对其他请求立即可见
+type MemoryUnitOfWork struct{}
+
+// NewMemoryUnitOfWork 创建内存 UnitOfWork 实例
+func NewMemoryUnitOfWork() *MemoryUnitOfWork {
+	return &MemoryUnitOfWork{}
+}
+
+func (u *MemoryUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, _ := ctx.Value(memoryTxKey{}).(*memoryTx)
+	tx := &memoryTx{}
+
+	committed := false
+	defer func() {
+		// fn 返回错误或 panic 时撤销本层的全部写入
+		if !committed {
+			tx.rollback()
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, tx)); err != nil {
+		return err
+	}
+	committed = true
+
+	if parent != nil {
+		parent.merge(tx)
+	}
+	return nil
+}
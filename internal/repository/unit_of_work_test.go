@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/model"
+)
+
+type unitOfWorkFactory func(t *testing.T) (UnitOfWork, HistoryRepository)
+
+func runUnitOfWorkConformance(t *testing.T, newUoW unitOfWorkFactory) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	create := func(t *testing.T, ctx context.Context, repo HistoryRepository, op string) *model.CalculationHistory {
+		t.Helper()
+		h := &model.CalculationHistory{Operation: op}
+		require.NoError(t, repo.Create(ctx, h))
+		return h
+	}
+	list := func(t *testing.T, ctx context.Context, repo HistoryRepository) []string {
+		t.Helper()
+		history, err := repo.List(ctx, -1)
+		require.NoError(t, err)
+		return operations(history)
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		uow, repo := newUoW(t)
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			create(t, ctx, repo, "a")
+			create(t, ctx, repo, "b")
+			// 事务内可以读到自己的写入
+			assert.Len(t, list(t, ctx, repo), 2)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a", "b"}, list(t, ctx, repo))
+	})
+
+	t.Run("RollbackOnError", func(t *testing.T) {
+		uow, repo := newUoW(t)
+		existing := create(t, ctx, repo, "existing")
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			create(t, ctx, repo, "a")
+			require.NoError(t, repo.Delete(ctx, existing.ID))
+			return errBoom
+		})
+		assert.ErrorIs(t, err, errBoom)
+		// 创建被撤销，软删除被恢复
+		assert.Equal(t, []string{"existing"}, list(t, ctx, repo))
+	})
+
+	t.Run("RollbackOnPanic", func(t *testing.T) {
+		uow, repo := newUoW(t)
+
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = uow.Do(ctx, func(ctx context.Context) error {
+				create(t, ctx, repo, "a")
+				panic("boom")
+			})
+		})
+		assert.Empty(t, list(t, ctx, repo))
+	})
+
+	t.Run("NestedSavepointRollback", func(t *testing.T) {
+		uow, repo := newUoW(t)
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			create(t, ctx, repo, "outer")
+			innerErr := uow.Do(ctx, func(ctx context.Context) error {
+				create(t, ctx, repo, "inner")
+				return errBoom
+			})
+			assert.ErrorIs(t, innerErr, errBoom)
+			// 外层忽略内层错误继续提交
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"outer"}, list(t, ctx, repo))
+	})
+
+	t.Run("NestedCommitOuterRollback", func(t *testing.T) {
+		uow, repo := newUoW(t)
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			create(t, ctx, repo, "outer")
+			require.NoError(t, uow.Do(ctx, func(ctx context.Context) error {
+				create(t, ctx, repo, "inner")
+				return nil
+			}))
+			return errBoom
+		})
+		assert.ErrorIs(t, err, errBoom)
+		// 外层回滚时，已提交到保存点的内层写入同样被撤销
+		assert.Empty(t, list(t, ctx, repo))
+	})
+}
+
+func TestGormUnitOfWork(t *testing.T) {
+	runUnitOfWorkConformance(t, func(t *testing.T) (UnitOfWork, HistoryRepository) {
+		db := setupTestDB(t)
+		return NewUnitOfWork(db), NewHistoryRepository(db)
+	})
+}
+
+func TestMemoryUnitOfWork(t *testing.T) {
+	runUnitOfWorkConformance(t, func(_ *testing.T) (UnitOfWork, HistoryRepository) {
+		return NewMemoryUnitOfWork(), NewMemoryHistoryRepository()
+	})
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/internal/repository"
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+const (
+	// apiKeyPrefix 明文密钥格式：pg_<prefix>_<secret>
+	apiKeyPrefix = "pg_"
+	// lastUsedInterval 最近使用时间的最小更新间隔，避免每个请求都写库
+	lastUsedInterval = time.Minute
+)
+
+var errInvalidAPIKey = errors.New(errors.ErrTypeUnauthorized, "Invalid API key")
+
+// APIKeyService API 密钥的创建、吊销与校验
+type APIKeyService struct {
+	repo repository.APIKeyRepository
+	now  func() time.Time
+}
+
+// NewAPIKeyService 创建 APIKeyService 实例
+func NewAPIKeyService(repo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{repo: repo, now: time.Now}
+}
+
+// Create 创建 API 密钥，返回的明文密钥只会出现这一次
+func (s *APIKeyService) Create(ctx context.Context, name string, scopes []string) (string, *model.APIKey, error) {
+	if name == "" {
+		return "", nil, errors.New(errors.ErrTypeValidation, "Name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New(errors.ErrTypeValidation, "At least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return "", nil, errors.NewWithDetails(errors.ErrTypeValidation, "Invalid scope", scope)
+		}
+	}
+
+	prefix, err := randomHex(6)
+	if err != nil {
+		return "", nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	plaintext := apiKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &model.APIKey{
+		Name:   name,
+		Prefix: prefix,
+		Hash:   hashAPIKey(plaintext),
+		Scopes: strings.Join(scopes, ","),
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// List 列出全部密钥
+func (s *APIKeyService) List(ctx context.Context) ([]model.APIKey, error) {
+	return s.repo.List(ctx)
+}
+
+// Revoke 吊销密钥
+func (s *APIKeyService) Revoke(ctx context.Context, id uint) error {
+	return s.repo.Revoke(ctx, id, s.now())
+}
+
+// VerifyAPIKey 校验明文密钥，实现 auth.APIKeyVerifier
+// 密钥不存在、不匹配或已吊销时统一返回 ErrTypeUnauthorized，不区分具体原因
+func (s *APIKeyService) VerifyAPIKey(ctx context.Context, plaintext string) (*auth.Principal, error) {
+	rest, ok := strings.CutPrefix(plaintext, apiKeyPrefix)
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+	prefix, _, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" {
+		return nil, errInvalidAPIKey
+	}
+
+	key, err := s.repo.GetByPrefix(ctx, prefix)
+	if errors.IsNotFoundError(err) {
+		return nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(plaintext))) != 1 || key.Revoked() {
+		return nil, errInvalidAPIKey
+	}
+
+	now := s.now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedInterval {
+		if err := s.repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			logger.FromContext(ctx).Warn("Failed to update api key last used time", zap.Uint("id", key.ID), zap.Error(err))
+		}
+	}
+
+	scopes := key.ScopeList()
+	return &auth.Principal{
+		ID:     "apikey:" + strconv.FormatUint(uint64(key.ID), 10),
+		Name:   key.Name,
+		Method: auth.MethodAPIKey,
+		Scopes: scopes,
+		// API 密钥没有独立的角色，scope 同时作为 RBAC 角色参与授权
+		Roles: scopes,
+	}, nil
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
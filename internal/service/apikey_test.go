@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/internal/auth"
+	"github.com/exiaohu/go-demo/internal/repository"
+	apperrors "github.com/exiaohu/go-demo/pkg/errors"
+)
+
+func TestAPIKeyService_CreateAndVerify(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAPIKeyService(repository.NewMemoryAPIKeyRepository())
+
+	plaintext, key, err := svc.Create(ctx, "ci", []string{auth.ScopeCalculate})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(plaintext, "pg_"+key.Prefix+"_"))
+	assert.NotContains(t, key.Hash, plaintext, "plaintext must not be stored")
+
+	p, err := svc.VerifyAPIKey(ctx, plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", p.Name)
+	assert.Equal(t, auth.MethodAPIKey, p.Method)
+	assert.True(t, p.HasScope(auth.ScopeCalculate))
+	assert.False(t, p.HasScope(auth.ScopeReadHistory))
+
+	keys, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	invalid := []string{
+		"",
+		"not-a-key",
+		"pg_",
+		"pg_" + key.Prefix + "_wrong-secret",
+		"pg_unknown_" + strings.Repeat("a", 43),
+	}
+	for _, k := range invalid {
+		_, err := svc.VerifyAPIKey(ctx, k)
+		assert.True(t, apperrors.IsUnauthorizedError(err), "key %q", k)
+	}
+
+	require.NoError(t, svc.Revoke(ctx, key.ID))
+	_, err = svc.VerifyAPIKey(ctx, plaintext)
+	assert.True(t, apperrors.IsUnauthorizedError(err))
+}
+
+func TestAPIKeyService_CreateValidation(t *testing.T) {
+	svc := NewAPIKeyService(repository.NewMemoryAPIKeyRepository())
+
+	_, _, err := svc.Create(context.Background(), "", []string{auth.ScopeAdmin})
+	assert.True(t, apperrors.IsValidationError(err))
+	_, _, err = svc.Create(context.Background(), "ci", nil)
+	assert.True(t, apperrors.IsValidationError(err))
+	_, _, err = svc.Create(context.Background(), "ci", []string{"root"})
+	assert.True(t, apperrors.IsValidationError(err))
+}
@@ -2,52 +2,171 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"github.com/exiaohu/go-demo/internal/cache"
 	"github.com/exiaohu/go-demo/internal/math"
 	"github.com/exiaohu/go-demo/internal/model"
 	"github.com/exiaohu/go-demo/internal/repository"
+	"github.com/exiaohu/go-demo/pkg/errors"
 	"github.com/exiaohu/go-demo/pkg/logger"
 )
 
+// MaxBatchSize 单次批量计算允许的最大操作数
+const MaxBatchSize = 100
+
 // CalculatorService 定义计算服务接口
 type CalculatorService interface {
 	Add(ctx context.Context, a, b int, ip string) (int, error)
 	Subtract(ctx context.Context, a, b int, ip string) (int, error)
 	Multiply(ctx context.Context, a, b int, ip string) (int, error)
 	Divide(ctx context.Context, a, b int, ip string) (int, error)
+	Batch(ctx context.Context, ops []BatchOperation, ip string) ([]BatchResult, error)
 	GetHistory(ctx context.Context, limit int) ([]model.CalculationHistory, error)
+	DeleteHistory(ctx context.Context, id uint) error
 	Close() error
 }
 
+// BatchOperation 批量计算中的单个操作
+type BatchOperation struct {
+	Operation string `json:"operation"`
+	A         int    `json:"a"`
+	B         int    `json:"b"`
+}
+
+// BatchResult 批量计算中单个操作的结果
+type BatchResult struct {
+	Operation string `json:"operation"`
+	A         int    `json:"a"`
+	B         int    `json:"b"`
+	Result    int    `json:"result"`
+}
+
+// operations 支持的运算
+var operations = map[string]func(int, int) (int, error){
+	"add":      math.Add,
+	"subtract": math.Subtract,
+	"multiply": math.Multiply,
+	"divide":   math.Divide,
+}
+
 type StandardCalculatorService struct {
-	repo repository.HistoryRepository
-	wg   sync.WaitGroup
+	repo    repository.HistoryRepository
+	uow     repository.UnitOfWork
+	cache   cache.Cache
+	metrics *Metrics
+	wg      sync.WaitGroup
 }
 
 // NewCalculatorService 创建 CalculatorService 实例
-func NewCalculatorService(repo repository.HistoryRepository) *StandardCalculatorService {
-	return &StandardCalculatorService{repo: repo}
+// resultCache 为 nil 时不缓存计算结果，metrics 为 nil 时不记录业务指标
+func NewCalculatorService(
+	repo repository.HistoryRepository,
+	uow repository.UnitOfWork,
+	resultCache cache.Cache,
+	metrics *Metrics,
+) *StandardCalculatorService {
+	return &StandardCalculatorService{repo: repo, uow: uow, cache: resultCache, metrics: metrics}
 }
 
-func (s *StandardCalculatorService) calculateAndRecord(
-	_ context.Context,
+// compute 执行计算并记录业务指标，命中缓存的计算同样计入
+func (s *StandardCalculatorService) compute(
+	ctx context.Context,
+	opName string,
+	a, b int,
+	op func(int, int) (int, error),
+) (int, error) {
+	result, err := s.computeCached(ctx, opName, a, b, op)
+	s.metrics.calculated(opName, a, b, err)
+	return result, err
+}
+
+// computeCached 执行计算，结果缓存位于 math 层之前
+// 缓存读写失败只记录日志，不影响计算本身
+func (s *StandardCalculatorService) computeCached(
+	ctx context.Context,
 	opName string,
 	a, b int,
-	ip string,
 	op func(int, int) (int, error),
 ) (int, error) {
+	if s.cache == nil {
+		return calculate(ctx, opName, a, b, op)
+	}
+
+	key := cache.Key(opName, a, b)
+	if !cache.IsBypassed(ctx) {
+		result, ok, err := s.cache.Get(ctx, key)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Failed to read calculation cache", zap.String("key", key), zap.Error(err))
+		} else if ok {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", true))
+			return result, nil
+		}
+	}
+
+	result, err := calculate(ctx, opName, a, b, op)
+	if err != nil {
+		// 错误结果不缓存
+		return 0, err
+	}
+
+	if err := s.cache.Set(ctx, key, result); err != nil {
+		logger.FromContext(ctx).Warn("Failed to write calculation cache", zap.String("key", key), zap.Error(err))
+	}
+	return result, nil
+}
+
+// calculate 在 math 层的 span 中执行计算
+func calculate(ctx context.Context, opName string, a, b int, op func(int, int) (int, error)) (int, error) {
+	_, span := otel.Tracer("service").Start(ctx, "math."+opName)
 	result, err := op(a, b)
+	endSpan(span, err)
+	return result, err
+}
+
+func (s *StandardCalculatorService) calculateAndRecord(
+	ctx context.Context,
+	opName string,
+	a, b int,
+	ip string,
+	op func(int, int) (int, error),
+) (result int, err error) {
+	ctx, span := startSpan(ctx, opName,
+		attribute.String("operation", opName),
+		attribute.Int("a", a),
+		attribute.Int("b", b),
+	)
+	defer func() { endSpan(span, err) }()
+
+	result, err = s.compute(ctx, opName, a, b, op)
 	if err != nil {
 		return 0, err
 	}
+	span.SetAttributes(attribute.Int("result", result))
 
 	// 异步记录历史
+	// 写入可能晚于请求结束，因此不作为请求 span 的子 span，而是新的根 span，通过 span link 关联发起的请求；
+	// context 不随请求取消，但保留请求 ID 等值
 	s.wg.Add(1)
+	s.metrics.asyncStarted()
+	link := trace.LinkFromContext(ctx)
+	asyncCtx := context.WithoutCancel(ctx)
 	go func() {
 		defer s.wg.Done()
+		defer s.metrics.asyncFinished()
+		ctx, span := otel.Tracer("service").Start(asyncCtx, "CalculatorService.saveHistory",
+			trace.WithNewRoot(),
+			trace.WithLinks(link),
+			trace.WithAttributes(attribute.String("operation", opName)),
+		)
 		history := &model.CalculationHistory{
 			Operation: opName,
 			A:         a,
@@ -55,9 +174,12 @@ func (s *StandardCalculatorService) calculateAndRecord(
 			Result:    result,
 			ClientIP:  ip,
 		}
-		// 使用 Background context
-		if err := s.repo.Create(context.Background(), history); err != nil {
-			logger.Error("Failed to save history", zap.Error(err))
+		start := time.Now()
+		err := s.repo.Create(ctx, history)
+		s.metrics.historyWritten(HistoryWriteAsync, time.Since(start), err)
+		endSpan(span, err)
+		if err != nil {
+			logger.FromContext(ctx).Error("Failed to save history", zap.Error(err))
 		}
 	}()
 
@@ -85,6 +207,82 @@ func (s *StandardCalculatorService) Divide(ctx context.Context, a, b int, ip str
 	return s.calculateAndRecord(ctx, "divide", a, b, ip, math.Divide)
 }
 
+// Batch 批量计算
+// 所有操作先全部计算，任一失败则不写入任何历史；
+// 全部成功后在同一事务中同步写入历史，保证要么全部记录、要么全部不记录
+func (s *StandardCalculatorService) Batch(ctx context.Context, ops []BatchOperation, ip string) (_ []BatchResult, err error) {
+	ctx, span := startSpan(ctx, "batch", attribute.Int("batch.size", len(ops)))
+	defer func() { endSpan(span, err) }()
+
+	if len(ops) == 0 {
+		return nil, errors.New(errors.ErrTypeValidation, "Operations are required")
+	}
+	if len(ops) > MaxBatchSize {
+		return nil, errors.New(errors.ErrTypeValidation, fmt.Sprintf("Too many operations, max %d", MaxBatchSize))
+	}
+
+	results := make([]BatchResult, 0, len(ops))
+	for i, o := range ops {
+		op, ok := operations[o.Operation]
+		if !ok {
+			return nil, errors.NewWithDetails(errors.ErrTypeValidation, "Unsupported operation", fmt.Sprintf("operations[%d]: %q", i, o.Operation))
+		}
+		result, err := s.compute(ctx, o.Operation, o.A, o.B, op)
+		if err != nil {
+			return nil, errors.NewWithDetails(errors.ErrTypeValidation, "Operation failed", fmt.Sprintf("operations[%d]: %v", i, err))
+		}
+		results = append(results, BatchResult{Operation: o.Operation, A: o.A, B: o.B, Result: result})
+	}
+
+	start := time.Now()
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		for _, r := range results {
+			history := &model.CalculationHistory{
+				Operation: r.Operation,
+				A:         r.A,
+				B:         r.B,
+				Result:    r.Result,
+				ClientIP:  ip,
+			}
+			if err := s.repo.Create(ctx, history); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	s.metrics.historyWritten(HistoryWriteBatch, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save batch history: %w", err)
+	}
+
+	return results, nil
+}
+
 func (s *StandardCalculatorService) GetHistory(ctx context.Context, limit int) ([]model.CalculationHistory, error) {
-	return s.repo.List(ctx, limit)
+	ctx, span := startSpan(ctx, "getHistory", attribute.Int("limit", limit))
+	history, err := s.repo.List(ctx, limit)
+	endSpan(span, err)
+	return history, err
+}
+
+// DeleteHistory 删除（软删除）一条计算历史
+func (s *StandardCalculatorService) DeleteHistory(ctx context.Context, id uint) error {
+	ctx, span := startSpan(ctx, "deleteHistory", attribute.Int("id", int(id)))
+	err := s.repo.Delete(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+// startSpan 创建服务层操作的 span，名称为 CalculatorService.<name>
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer("service").Start(ctx, "CalculatorService."+name, trace.WithAttributes(attrs...))
+}
+
+// endSpan 记录错误后结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
 }
@@ -3,13 +3,31 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/exiaohu/go-demo/internal/cache"
 	"github.com/exiaohu/go-demo/internal/model"
+	"github.com/exiaohu/go-demo/internal/repository"
+	apperrors "github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+func init() {
+	_ = logger.Initialize(true)
+}
+
 // MockHistoryRepository 模拟 HistoryRepository
 type MockHistoryRepository struct {
 	mock.Mock
@@ -25,9 +43,14 @@ func (m *MockHistoryRepository) List(ctx context.Context, limit int) ([]model.Ca
 	return args.Get(0).([]model.CalculationHistory), args.Error(1)
 }
 
+func (m *MockHistoryRepository) Delete(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func TestCalculatorService_Add(t *testing.T) {
 	mockRepo := new(MockHistoryRepository)
-	svc := NewCalculatorService(mockRepo)
+	svc := NewCalculatorService(mockRepo, repository.NewMemoryUnitOfWork(), nil, nil)
 	defer svc.Close()
 
 	// 预期 Create 会被异步调用，这里我们不强制检查异步调用是否完成
@@ -41,7 +64,7 @@ func TestCalculatorService_Add(t *testing.T) {
 
 func TestCalculatorService_Divide_Error(t *testing.T) {
 	mockRepo := new(MockHistoryRepository)
-	svc := NewCalculatorService(mockRepo)
+	svc := NewCalculatorService(mockRepo, repository.NewMemoryUnitOfWork(), nil, nil)
 	defer svc.Close()
 
 	result, err := svc.Divide(context.Background(), 10, 0, "127.0.0.1")
@@ -53,7 +76,7 @@ func TestCalculatorService_Divide_Error(t *testing.T) {
 
 func TestCalculatorService_GetHistory(t *testing.T) {
 	mockRepo := new(MockHistoryRepository)
-	svc := NewCalculatorService(mockRepo)
+	svc := NewCalculatorService(mockRepo, repository.NewMemoryUnitOfWork(), nil, nil)
 	defer svc.Close()
 
 	expectedHistory := []model.CalculationHistory{
@@ -69,7 +92,7 @@ func TestCalculatorService_GetHistory(t *testing.T) {
 
 func TestCalculatorService_GetHistory_Error(t *testing.T) {
 	mockRepo := new(MockHistoryRepository)
-	svc := NewCalculatorService(mockRepo)
+	svc := NewCalculatorService(mockRepo, repository.NewMemoryUnitOfWork(), nil, nil)
 	defer svc.Close()
 
 	mockRepo.On("List", mock.Anything, 10).Return([]model.CalculationHistory{}, errors.New("db error"))
@@ -79,3 +102,230 @@ func TestCalculatorService_GetHistory_Error(t *testing.T) {
 	assert.Empty(t, history)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestCalculatorService_DeleteHistory(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	svc := NewCalculatorService(mockRepo, repository.NewMemoryUnitOfWork(), nil, nil)
+	defer svc.Close()
+
+	mockRepo.On("Delete", mock.Anything, uint(7)).Return(nil)
+
+	assert.NoError(t, svc.DeleteHistory(context.Background(), 7))
+	mockRepo.AssertExpectations(t)
+}
+
+// failingHistoryRepository 在第 failAt 次 Create 时返回错误
+type failingHistoryRepository struct {
+	*repository.MemoryHistoryRepository
+	calls  int
+	failAt int
+}
+
+func (r *failingHistoryRepository) Create(ctx context.Context, history *model.CalculationHistory) error {
+	r.calls++
+	if r.calls == r.failAt {
+		return errors.New("disk full")
+	}
+	return r.MemoryHistoryRepository.Create(ctx, history)
+}
+
+func TestCalculatorService_Batch(t *testing.T) {
+	repo := repository.NewMemoryHistoryRepository()
+	svc := NewCalculatorService(repo, repository.NewMemoryUnitOfWork(), nil, nil)
+	defer svc.Close()
+
+	results, err := svc.Batch(context.Background(), []BatchOperation{
+		{Operation: "add", A: 1, B: 2},
+		{Operation: "multiply", A: 3, B: 4},
+	}, "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, []BatchResult{
+		{Operation: "add", A: 1, B: 2, Result: 3},
+		{Operation: "multiply", A: 3, B: 4, Result: 12},
+	}, results)
+
+	// 批量计算同步写入历史
+	history, err := repo.List(context.Background(), -1)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestCalculatorService_Batch_ValidationError(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []BatchOperation
+	}{
+		{name: "Empty", ops: nil},
+		{name: "Unsupported operation", ops: []BatchOperation{{Operation: "add", A: 1, B: 1}, {Operation: "pow", A: 2, B: 3}}},
+		{name: "Division by zero", ops: []BatchOperation{{Operation: "add", A: 1, B: 1}, {Operation: "divide", A: 1, B: 0}}},
+		{name: "Too many operations", ops: make([]BatchOperation, MaxBatchSize+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewMemoryHistoryRepository()
+			svc := NewCalculatorService(repo, repository.NewMemoryUnitOfWork(), nil, nil)
+			defer svc.Close()
+
+			_, err := svc.Batch(context.Background(), tt.ops, "127.0.0.1")
+			assert.True(t, apperrors.IsValidationError(err))
+
+			history, err := repo.List(context.Background(), -1)
+			require.NoError(t, err)
+			assert.Empty(t, history)
+		})
+	}
+}
+
+func TestCalculatorService_Batch_RollbackOnWriteFailure(t *testing.T) {
+	repo := &failingHistoryRepository{MemoryHistoryRepository: repository.NewMemoryHistoryRepository(), failAt: 2}
+	svc := NewCalculatorService(repo, repository.NewMemoryUnitOfWork(), nil, nil)
+	defer svc.Close()
+
+	_, err := svc.Batch(context.Background(), []BatchOperation{
+		{Operation: "add", A: 1, B: 2},
+		{Operation: "subtract", A: 3, B: 4},
+	}, "127.0.0.1")
+	assert.Error(t, err)
+
+	// 第一条已写入的记录随事务一起回滚
+	history, err := repo.List(context.Background(), -1)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+// countingOp 统计底层运算被调用的次数
+func countingOp(calls *int) func(int, int) (int, error) {
+	return func(a, b int) (int, error) {
+		*calls++
+		return a + b, nil
+	}
+}
+
+func TestCalculatorService_Cache(t *testing.T) {
+	svc := NewCalculatorService(repository.NewMemoryHistoryRepository(), repository.NewMemoryUnitOfWork(), cache.NewLRU(10, 0, nil), nil)
+	defer svc.Close()
+
+	ctx := context.Background()
+	calls := 0
+	op := countingOp(&calls)
+
+	for i := 0; i < 3; i++ {
+		result, err := svc.compute(ctx, "add", 1, 2, op)
+		require.NoError(t, err)
+		assert.Equal(t, 3, result)
+	}
+	assert.Equal(t, 1, calls, "subsequent calls should be served from cache")
+
+	// 跳过缓存时重新计算
+	_, err := svc.compute(cache.WithBypass(ctx), "add", 1, 2, op)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// 不同参数不命中
+	_, err = svc.compute(ctx, "add", 2, 1, op)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestCalculatorService_Cache_ErrorsNotCached(t *testing.T) {
+	c := cache.NewLRU(10, 0, nil)
+	svc := NewCalculatorService(repository.NewMemoryHistoryRepository(), repository.NewMemoryUnitOfWork(), c, nil)
+	defer svc.Close()
+
+	_, err := svc.Divide(context.Background(), 1, 0, "127.0.0.1")
+	assert.Error(t, err)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCalculatorService_Metrics(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry())
+	mockRepo := new(MockHistoryRepository)
+	svc := NewCalculatorService(mockRepo, repository.NewMemoryUnitOfWork(), nil, metrics)
+
+	release := make(chan struct{})
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("db down")).Run(func(mock.Arguments) {
+		<-release
+	})
+
+	ctx := context.Background()
+	_, err := svc.Add(ctx, 1, -200, "127.0.0.1")
+	require.NoError(t, err)
+	_, err = svc.Divide(ctx, 1, 0, "127.0.0.1")
+	require.Error(t, err)
+
+	// 写入历史的 goroutine 仍在运行
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.asyncGoroutines), 0)
+	close(release)
+	require.NoError(t, svc.Close())
+	assert.InDelta(t, 0, testutil.ToFloat64(metrics.asyncGoroutines), 0)
+
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.calculations.WithLabelValues("add", OutcomeSuccess)), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.calculations.WithLabelValues("divide", OutcomeError)), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.writeFailures.WithLabelValues(HistoryWriteAsync)), 0)
+
+	expected := `
+# HELP calculation_operand_magnitude Distribution of the absolute value of calculation operands
+# TYPE calculation_operand_magnitude histogram
+calculation_operand_magnitude_bucket{operation="add",le="1"} 1
+calculation_operand_magnitude_bucket{operation="add",le="10"} 1
+calculation_operand_magnitude_bucket{operation="add",le="100"} 1
+calculation_operand_magnitude_bucket{operation="add",le="1000"} 2
+`
+	// 只比较 add 的前几个桶
+	got, err := testutil.CollectAndFormat(metrics.operands, expfmt.TypeTextPlain, "calculation_operand_magnitude")
+	require.NoError(t, err)
+	for _, line := range strings.Split(strings.TrimSpace(expected), "\n") {
+		assert.Contains(t, string(got), line)
+	}
+
+	// 批量写入在同一事务中完成
+	repo := repository.NewMemoryHistoryRepository()
+	batch := NewCalculatorService(repo, repository.NewMemoryUnitOfWork(), nil, metrics)
+	_, err = batch.Batch(ctx, []BatchOperation{{Operation: "add", A: 1, B: 2}, {Operation: "multiply", A: 3, B: 4}}, "127.0.0.1")
+	require.NoError(t, err)
+	assert.InDelta(t, 2, testutil.ToFloat64(metrics.calculations.WithLabelValues("add", OutcomeSuccess)), 0)
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.writeDuration))
+	assert.InDelta(t, 0, testutil.ToFloat64(metrics.writeFailures.WithLabelValues(HistoryWriteBatch)), 0)
+}
+
+func TestCalculatorService_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	mockRepo := new(MockHistoryRepository)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	svc := NewCalculatorService(mockRepo, repository.NewMemoryUnitOfWork(), nil, nil)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	_, err := svc.Add(ctx, 1, 2, "127.0.0.1")
+	require.NoError(t, err)
+	parent.End()
+	require.NoError(t, svc.Close())
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	require.Contains(t, spans, "CalculatorService.add")
+	require.Contains(t, spans, "math.add")
+	require.Contains(t, spans, "CalculatorService.saveHistory")
+
+	// 服务层 span 是请求 span 的子 span，math 层 span 是服务层 span 的子 span
+	op := spans["CalculatorService.add"]
+	assert.Equal(t, parent.SpanContext().SpanID(), op.Parent().SpanID())
+	assert.Equal(t, op.SpanContext().SpanID(), spans["math.add"].Parent().SpanID())
+
+	// 异步写入历史是新的 trace，通过 span link 关联发起的服务层 span
+	save := spans["CalculatorService.saveHistory"]
+	assert.NotEqual(t, parent.SpanContext().TraceID(), save.SpanContext().TraceID())
+	require.Len(t, save.Links(), 1)
+	assert.Equal(t, op.SpanContext().SpanID(), save.Links()[0].SpanContext.SpanID())
+
+	// 写入历史时使用异步 span 的 context
+	createCtx := mockRepo.Calls[0].Arguments.Get(0).(context.Context)
+	assert.Equal(t, save.SpanContext().SpanID(), trace.SpanContextFromContext(createCtx).SpanID())
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/exiaohu/go-demo/pkg/metrics"
+)
+
+// 计算结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// 历史记录写入方式
+const (
+	HistoryWriteAsync = "async"
+	HistoryWriteBatch = "batch"
+)
+
+// Metrics 计算服务的业务指标
+type Metrics struct {
+	calculations    *prometheus.CounterVec
+	operands        *prometheus.HistogramVec
+	writeDuration   *prometheus.HistogramVec
+	writeFailures   *prometheus.CounterVec
+	asyncGoroutines prometheus.Gauge
+}
+
+// NewMetrics 创建并注册计算服务指标
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	return &Metrics{
+		calculations: metrics.MustRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calculations_total",
+			Help: "Total number of calculations by operation and outcome",
+		}, []string{"operation", "outcome"})),
+		operands: metrics.MustNewHistogramVec(reg, prometheus.HistogramOpts{
+			Name:    "calculation_operand_magnitude",
+			Help:    "Distribution of the absolute value of calculation operands",
+			Buckets: prometheus.ExponentialBuckets(1, 10, 19),
+		}, []string{"operation"}),
+		writeDuration: metrics.MustNewHistogramVec(reg, prometheus.HistogramOpts{
+			Name:    "calculation_history_write_duration_seconds",
+			Help:    "Latency of writing calculation history",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"mode"}),
+		writeFailures: metrics.MustRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calculation_history_write_failures_total",
+			Help: "Total number of failed calculation history writes",
+		}, []string{"mode"})),
+		asyncGoroutines: metrics.MustRegister(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "calculation_history_async_goroutines",
+			Help: "Number of goroutines currently writing calculation history asynchronously",
+		})),
+	}
+}
+
+// calculated 记录一次计算及其操作数
+func (m *Metrics) calculated(operation string, a, b int, err error) {
+	if m == nil {
+		return
+	}
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+	m.calculations.WithLabelValues(operation, outcome).Inc()
+	operands := m.operands.WithLabelValues(operation)
+	// 先转换为浮点数再取绝对值，避免 math.MinInt 取反溢出
+	operands.Observe(math.Abs(float64(a)))
+	operands.Observe(math.Abs(float64(b)))
+}
+
+// historyWritten 记录一次历史写入的耗时与结果
+func (m *Metrics) historyWritten(mode string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.writeDuration.WithLabelValues(mode).Observe(elapsed.Seconds())
+	if err != nil {
+		m.writeFailures.WithLabelValues(mode).Inc()
+	}
+}
+
+func (m *Metrics) asyncStarted() {
+	if m != nil {
+		m.asyncGoroutines.Inc()
+	}
+}
+
+func (m *Metrics) asyncFinished() {
+	if m != nil {
+		m.asyncGoroutines.Dec()
+	}
+}
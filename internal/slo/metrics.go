@@ -0,0 +1,50 @@
+package slo
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/exiaohu/go-demo/pkg/metrics"
+)
+
+// Metrics SLO Prometheus 指标
+type Metrics struct {
+	objective *prometheus.GaugeVec
+	budget    *prometheus.GaugeVec
+	burnRate  *prometheus.GaugeVec
+}
+
+// NewMetrics 创建并注册 SLO 指标
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	return &Metrics{
+		objective: metrics.MustRegister(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "slo_objective_ratio",
+			Help: "Target ratio of good requests for the service level objective",
+		}, []string{"slo", "sli"})),
+		budget: metrics.MustRegister(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "slo_error_budget_remaining_ratio",
+			Help: "Fraction of the error budget remaining in the SLO period, negative once exhausted",
+		}, []string{"slo", "sli"})),
+		burnRate: metrics.MustRegister(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "slo_burn_rate",
+			Help: "Rate at which the error budget is consumed over the window, 1 exhausts it exactly at the end of the period",
+		}, []string{"slo", "sli", "window"})),
+	}
+}
+
+func (m *Metrics) setObjective(name string, sli SLI, objective float64) {
+	if m != nil {
+		m.objective.WithLabelValues(name, string(sli)).Set(objective)
+	}
+}
+
+func (m *Metrics) setBudget(name string, sli SLI, remaining float64) {
+	if m != nil {
+		m.budget.WithLabelValues(name, string(sli)).Set(remaining)
+	}
+}
+
+func (m *Metrics) setBurnRate(name string, sli SLI, window string, rate float64) {
+	if m != nil {
+		m.burnRate.WithLabelValues(name, string(sli), window).Set(rate)
+	}
+}
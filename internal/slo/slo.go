@@ -0,0 +1,445 @@
+// Package slo 根据 HTTP 请求指标跟踪服务等级目标（SLO）：
+// 定期采样 http_requests_total 与 http_request_duration_seconds，计算错误预算剩余比例与多个窗口的消耗速率（burn rate）
+package slo
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+// 采样的请求指标，由 middleware.HTTPMetrics 导出
+const (
+	requestsMetric = "http_requests_total"
+	durationMetric = "http_request_duration_seconds"
+)
+
+// SLI 服务等级指标类型
+type SLI string
+
+const (
+	// SLIAvailability 可用性：状态码小于 500 的请求占比
+	SLIAvailability SLI = "availability"
+	// SLILatency 延迟：耗时不超过阈值的请求占比
+	SLILatency SLI = "latency"
+)
+
+// DefaultWindows 默认的 burn rate 窗口，覆盖常用的多窗口告警组合（1h/5m、6h/30m、1d/2h、3d/6h）
+var DefaultWindows = []time.Duration{
+	5 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 6 * time.Hour, 24 * time.Hour, 72 * time.Hour,
+}
+
+// Objective 一组路由的服务等级目标
+type Objective struct {
+	// Name 目标名称，用作指标标签
+	Name string
+	// Routes 计入的路由模式（与 HTTP 指标的 route 标签一致），为空时计入全部请求
+	Routes []string
+	// Availability 可用性目标，例如 0.999；为 0 时不跟踪可用性
+	Availability float64
+	// LatencyThreshold 延迟阈值，必须是请求耗时直方图的桶边界；为 0 时不跟踪延迟
+	LatencyThreshold time.Duration
+	// LatencyTarget 耗时不超过阈值的请求占比目标，例如 0.99
+	LatencyTarget float64
+}
+
+// Options Tracker 的配置，零值字段使用默认值
+type Options struct {
+	// Gatherer 读取请求指标的来源，默认 prometheus.DefaultGatherer
+	Gatherer prometheus.Gatherer
+	// Interval 采样间隔，默认 1 分钟
+	Interval time.Duration
+	// Period 错误预算的统计周期，默认 30 天
+	Period time.Duration
+	// Windows burn rate 窗口，默认 DefaultWindows
+	Windows []time.Duration
+	// DurationBuckets 请求耗时直方图的桶（秒），非空时校验延迟阈值是否落在桶边界上
+	DurationBuckets []float64
+	// Metrics 为 nil 时不导出指标
+	Metrics *Metrics
+	// Now 采样时间，默认 time.Now，测试中可替换为可控时钟
+	Now func() time.Time
+}
+
+// Report 各目标的当前状态
+type Report struct {
+	// Period 错误预算的统计周期
+	Period string `json:"period"`
+	// Objectives 各目标的状态
+	Objectives []ObjectiveReport `json:"objectives"`
+}
+
+// ObjectiveReport 一个目标的状态
+type ObjectiveReport struct {
+	Name   string      `json:"name"`
+	Routes []string    `json:"routes"`
+	SLIs   []SLIReport `json:"slis"`
+}
+
+// SLIReport 一个服务等级指标在统计周期内的状态
+type SLIReport struct {
+	SLI SLI `json:"sli"`
+	// Objective 目标占比
+	Objective float64 `json:"objective"`
+	// Threshold 延迟阈值，仅延迟指标
+	Threshold string `json:"threshold,omitempty"`
+	// Total、Good 统计周期内的请求数与达标请求数
+	Total float64 `json:"total"`
+	Good  float64 `json:"good"`
+	// Ratio 达标请求占比，没有请求时为 1
+	Ratio float64 `json:"ratio"`
+	// ErrorBudgetRemaining 剩余错误预算占比，预算耗尽后为负数
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRates 各窗口的错误预算消耗速率，1 表示恰好在统计周期结束时耗尽
+	BurnRates map[string]float64 `json:"burn_rates"`
+}
+
+// Tracker 定期采样请求指标并计算各目标的错误预算，可安全地并发使用
+type Tracker struct {
+	objectives []Objective
+	opts       Options
+	now        func() time.Time
+
+	mu      sync.Mutex
+	samples ring
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New 校验目标并创建 Tracker，在后台按 Interval 采样直到调用 Stop
+func New(objectives []Objective, opts Options) (*Tracker, error) {
+	opts.Gatherer = cmp.Or[prometheus.Gatherer](opts.Gatherer, prometheus.DefaultGatherer)
+	opts.Interval = cmp.Or(opts.Interval, time.Minute)
+	opts.Period = cmp.Or(opts.Period, 30*24*time.Hour)
+	if opts.Windows == nil {
+		opts.Windows = DefaultWindows
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Interval < 0 || opts.Period < opts.Interval {
+		return nil, fmt.Errorf("slo: invalid interval %v or period %v", opts.Interval, opts.Period)
+	}
+	for _, w := range opts.Windows {
+		if w < opts.Interval || w > opts.Period {
+			return nil, fmt.Errorf("slo: burn rate window %v must be between interval %v and period %v", w, opts.Interval, opts.Period)
+		}
+	}
+
+	names := make(map[string]bool, len(objectives))
+	for _, o := range objectives {
+		if err := validate(o, opts.DurationBuckets); err != nil {
+			return nil, fmt.Errorf("slo: objective %q: %w", o.Name, err)
+		}
+		if names[o.Name] {
+			return nil, fmt.Errorf("slo: duplicate objective %q", o.Name)
+		}
+		names[o.Name] = true
+	}
+
+	t := &Tracker{
+		objectives: objectives,
+		opts:       opts,
+		now:        opts.Now,
+		samples:    newRing(int(opts.Period/opts.Interval) + 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, o := range objectives {
+		if o.Availability > 0 {
+			opts.Metrics.setObjective(o.Name, SLIAvailability, o.Availability)
+		}
+		if o.LatencyThreshold > 0 {
+			opts.Metrics.setObjective(o.Name, SLILatency, o.LatencyTarget)
+		}
+	}
+	if err := t.collect(); err != nil {
+		return nil, err
+	}
+	go t.run()
+	return t, nil
+}
+
+func validate(o Objective, buckets []float64) error {
+	if o.Name == "" {
+		return errors.New("name is required")
+	}
+	if o.Availability == 0 && o.LatencyThreshold == 0 {
+		return errors.New("neither availability nor latency is set")
+	}
+	if o.Availability < 0 || o.Availability >= 1 {
+		return fmt.Errorf("availability must be in (0, 1), got %v", o.Availability)
+	}
+	if o.LatencyThreshold < 0 {
+		return fmt.Errorf("invalid latency threshold %v", o.LatencyThreshold)
+	}
+	if o.LatencyThreshold > 0 {
+		if o.LatencyTarget <= 0 || o.LatencyTarget >= 1 {
+			return fmt.Errorf("latency target must be in (0, 1), got %v", o.LatencyTarget)
+		}
+		if len(buckets) > 0 && !slices.ContainsFunc(buckets, func(b float64) bool { return sameBound(b, o.LatencyThreshold) }) {
+			return fmt.Errorf("latency threshold %v is not a duration histogram bucket boundary %v", o.LatencyThreshold, buckets)
+		}
+	}
+	return nil
+}
+
+// Stop 停止后台采样，可重复调用
+func (t *Tracker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+	<-t.done
+}
+
+func (t *Tracker) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			if err := t.collect(); err != nil {
+				logger.Warn("Failed to collect SLO metrics", zap.Error(err))
+			}
+		}
+	}
+}
+
+// collect 采样一次请求指标并更新导出的指标
+func (t *Tracker) collect() error {
+	s, err := t.sample()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.samples.push(s)
+	t.mu.Unlock()
+
+	for _, o := range t.report(s).Objectives {
+		for _, sli := range o.SLIs {
+			t.opts.Metrics.setBudget(o.Name, sli.SLI, sli.ErrorBudgetRemaining)
+			for window, rate := range sli.BurnRates {
+				t.opts.Metrics.setBurnRate(o.Name, sli.SLI, window, rate)
+			}
+		}
+	}
+	return nil
+}
+
+// Report 返回各目标截至当前的状态
+func (t *Tracker) Report() (Report, error) {
+	s, err := t.sample()
+	if err != nil {
+		return Report{}, err
+	}
+	return t.report(s), nil
+}
+
+func (t *Tracker) report(current sample) Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := Report{Period: formatWindow(t.opts.Period), Objectives: make([]ObjectiveReport, 0, len(t.objectives))}
+	periodBase := t.samples.since(current.at.Add(-t.opts.Period))
+	windowBases := make([]sample, len(t.opts.Windows))
+	for i, w := range t.opts.Windows {
+		windowBases[i] = t.samples.since(current.at.Add(-w))
+	}
+
+	for i, o := range t.objectives {
+		or := ObjectiveReport{Name: o.Name, Routes: o.Routes}
+		if or.Routes == nil {
+			or.Routes = []string{}
+		}
+		sli := func(kind SLI, objective float64, threshold string) SLIReport {
+			total, good := current.counts[i].delta(periodBase.counts[i], kind)
+			r := SLIReport{
+				SLI:                  kind,
+				Objective:            objective,
+				Threshold:            threshold,
+				Total:                total,
+				Good:                 good,
+				Ratio:                1,
+				ErrorBudgetRemaining: 1,
+				BurnRates:            make(map[string]float64, len(t.opts.Windows)),
+			}
+			if total > 0 {
+				r.Ratio = good / total
+				r.ErrorBudgetRemaining = 1 - (1-r.Ratio)/(1-objective)
+			}
+			for j, w := range t.opts.Windows {
+				total, good := current.counts[i].delta(windowBases[j].counts[i], kind)
+				rate := 0.0
+				if total > 0 {
+					rate = (1 - good/total) / (1 - objective)
+				}
+				r.BurnRates[formatWindow(w)] = rate
+			}
+			return r
+		}
+		if o.Availability > 0 {
+			or.SLIs = append(or.SLIs, sli(SLIAvailability, o.Availability, ""))
+		}
+		if o.LatencyThreshold > 0 {
+			or.SLIs = append(or.SLIs, sli(SLILatency, o.LatencyTarget, o.LatencyThreshold.String()))
+		}
+		report.Objectives = append(report.Objectives, or)
+	}
+	return report
+}
+
+// sample 读取当前各目标的累计请求数
+func (t *Tracker) sample() (sample, error) {
+	families, err := t.opts.Gatherer.Gather()
+	if err != nil {
+		return sample{}, fmt.Errorf("slo: gather metrics: %w", err)
+	}
+
+	s := sample{at: t.now(), counts: make([]counts, len(t.objectives))}
+	for _, mf := range families {
+		switch mf.GetName() {
+		case requestsMetric:
+			for _, m := range mf.GetMetric() {
+				status, _ := strconv.Atoi(label(m, "status"))
+				for i, o := range t.objectives {
+					if o.matches(label(m, "route")) {
+						s.counts[i].requests += m.GetCounter().GetValue()
+						if status >= 500 {
+							s.counts[i].failed += m.GetCounter().GetValue()
+						}
+					}
+				}
+			}
+		case durationMetric:
+			for _, m := range mf.GetMetric() {
+				for i, o := range t.objectives {
+					if o.LatencyThreshold > 0 && o.matches(label(m, "route")) {
+						s.counts[i].observed += float64(m.GetHistogram().GetSampleCount())
+						s.counts[i].fast += fastCount(m.GetHistogram(), o.LatencyThreshold)
+					}
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+func (o Objective) matches(route string) bool {
+	return len(o.Routes) == 0 || slices.Contains(o.Routes, route)
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// fastCount 返回耗时不超过阈值的请求数
+// 阈值不在桶边界上时取不超过阈值的最大边界，宁可低估达标请求
+func fastCount(h *dto.Histogram, threshold time.Duration) float64 {
+	var count uint64
+	for _, b := range h.GetBucket() {
+		if b.GetUpperBound() > threshold.Seconds() && !sameBound(b.GetUpperBound(), threshold) {
+			break
+		}
+		count = b.GetCumulativeCount()
+	}
+	return float64(count)
+}
+
+func sameBound(bound float64, threshold time.Duration) bool {
+	return math.Abs(bound-threshold.Seconds()) < 1e-9
+}
+
+// formatWindow 将窗口格式化为 5m、6h、3d 这样的形式
+func formatWindow(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d%day == 0:
+		return strconv.FormatInt(int64(d/day), 10) + "d"
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	default:
+		return d.String()
+	}
+}
+
+// counts 一个目标的累计请求数
+type counts struct {
+	// requests、failed 全部请求数与状态码不小于 500 的请求数
+	requests float64
+	failed   float64
+	// observed、fast 耗时直方图中的请求数与耗时不超过阈值的请求数
+	observed float64
+	fast     float64
+}
+
+// delta 返回两次采样之间的请求数与达标请求数
+func (c counts) delta(base counts, kind SLI) (total, good float64) {
+	if kind == SLILatency {
+		return c.observed - base.observed, c.fast - base.fast
+	}
+	total = c.requests - base.requests
+	return total, total - (c.failed - base.failed)
+}
+
+// sample 一次采样
+type sample struct {
+	at     time.Time
+	counts []counts
+}
+
+// ring 按时间顺序保存最近的采样，容量满后覆盖最早的采样
+type ring struct {
+	buf   []sample
+	start int
+	size  int
+}
+
+func newRing(capacity int) ring {
+	return ring{buf: make([]sample, capacity)}
+}
+
+func (r *ring) push(s sample) {
+	if r.size < len(r.buf) {
+		r.buf[(r.start+r.size)%len(r.buf)] = s
+		r.size++
+		return
+	}
+	r.buf[r.start] = s
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+func (r *ring) at(i int) sample {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+// since 返回不早于 from 的最早一次采样；采样历史不足时窗口从最早的采样开始，
+// 所有采样都早于 from 时返回最近一次采样
+func (r *ring) since(from time.Time) sample {
+	i := sort.Search(r.size, func(i int) bool { return !r.at(i).at.Before(from) })
+	return r.at(min(i, r.size-1))
+}
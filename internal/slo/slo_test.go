@@ -0,0 +1,200 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+func init() {
+	_ = logger.Initialize(true)
+}
+
+// testClock 可手动推进的时钟
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// testServer 模拟 middleware.HTTPMetrics 导出的请求指标
+type testServer struct {
+	reg      *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newTestServer() *testServer {
+	s := &testServer{
+		reg: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: requestsMetric,
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    durationMetric,
+			Buckets: []float64{0.1, 0.25, 1},
+		}, []string{"method", "route"}),
+	}
+	s.reg.MustRegister(s.requests, s.duration)
+	return s
+}
+
+func (s *testServer) serve(route, status string, latency time.Duration, n int) {
+	for range n {
+		s.requests.WithLabelValues("GET", route, status).Inc()
+		s.duration.WithLabelValues("GET", route).Observe(latency.Seconds())
+	}
+}
+
+// newTestTracker 创建使用可控时钟的 Tracker 并停止后台采样，由测试推进时钟并手动采样
+func newTestTracker(t *testing.T, s *testServer, objectives []Objective, opts Options) (*Tracker, *testClock) {
+	t.Helper()
+
+	clock := &testClock{t: time.Unix(0, 0)}
+	opts.Gatherer = s.reg
+	opts.Now = clock.now
+	tr, err := New(objectives, opts)
+	require.NoError(t, err)
+	tr.Stop()
+	return tr, clock
+}
+
+func TestTracker_Availability(t *testing.T) {
+	s := newTestServer()
+	s.serve("/add", "200", 0, 10)
+	metrics := NewMetrics(prometheus.NewRegistry())
+	tr, clock := newTestTracker(t, s, []Objective{{Name: "api", Routes: []string{"/add"}, Availability: 0.99}}, Options{
+		Period:  time.Hour,
+		Windows: []time.Duration{5 * time.Minute, time.Hour},
+		Metrics: metrics,
+	})
+
+	// 创建前已有的请求不计入
+	report, err := tr.Report()
+	require.NoError(t, err)
+	sli := report.Objectives[0].SLIs[0]
+	assert.Equal(t, SLIAvailability, sli.SLI)
+	assert.InDelta(t, 0, sli.Total, 0)
+	assert.InDelta(t, 1, sli.ErrorBudgetRemaining, 0)
+
+	// 前 55 分钟 990 个请求全部成功
+	for range 11 {
+		clock.advance(5 * time.Minute)
+		s.serve("/add", "200", 0, 90)
+		require.NoError(t, tr.collect())
+	}
+	// 最近 5 分钟 100 个请求中 5 个失败，其他路由的失败不计入
+	clock.advance(5 * time.Minute)
+	s.serve("/add", "200", 0, 95)
+	s.serve("/add", "503", 0, 5)
+	s.serve("/divide", "500", 0, 50)
+	require.NoError(t, tr.collect())
+
+	report, err = tr.Report()
+	require.NoError(t, err)
+	assert.Equal(t, "1h", report.Period)
+	sli = report.Objectives[0].SLIs[0]
+	assert.InDelta(t, 1090, sli.Total, 0)
+	assert.InDelta(t, 1085, sli.Good, 0)
+	// 预算为 1% 的请求，已消耗 (5/1090)/0.01
+	assert.InDelta(t, 1-(5.0/1090)/0.01, sli.ErrorBudgetRemaining, 1e-9)
+	assert.InDelta(t, 5, sli.BurnRates["5m"], 1e-9)
+	assert.InDelta(t, (5.0/1090)/0.01, sli.BurnRates["1h"], 1e-9)
+
+	assert.InDelta(t, 0.99, testutil.ToFloat64(metrics.objective.WithLabelValues("api", "availability")), 0)
+	assert.InDelta(t, sli.ErrorBudgetRemaining, testutil.ToFloat64(metrics.budget.WithLabelValues("api", "availability")), 1e-9)
+	assert.InDelta(t, 5, testutil.ToFloat64(metrics.burnRate.WithLabelValues("api", "availability", "5m")), 1e-9)
+
+	// 之后的 5 分钟没有失败，短窗口恢复，长窗口仍在消耗预算
+	clock.advance(5 * time.Minute)
+	s.serve("/add", "200", 0, 100)
+	require.NoError(t, tr.collect())
+	assert.InDelta(t, 0, testutil.ToFloat64(metrics.burnRate.WithLabelValues("api", "availability", "5m")), 0)
+	assert.Positive(t, testutil.ToFloat64(metrics.burnRate.WithLabelValues("api", "availability", "1h")))
+}
+
+func TestTracker_Latency(t *testing.T) {
+	s := newTestServer()
+	tr, clock := newTestTracker(t, s, []Objective{{
+		Name:             "all",
+		Availability:     0.999,
+		LatencyThreshold: 250 * time.Millisecond,
+		LatencyTarget:    0.9,
+	}}, Options{Period: time.Hour, Windows: []time.Duration{5 * time.Minute}, DurationBuckets: []float64{0.1, 0.25, 1}})
+
+	clock.advance(time.Minute)
+	s.serve("/add", "200", 50*time.Millisecond, 60)
+	s.serve("/divide", "200", 250*time.Millisecond, 20)
+	s.serve("/batch", "200", 800*time.Millisecond, 20)
+
+	report, err := tr.Report()
+	require.NoError(t, err)
+	require.Len(t, report.Objectives[0].SLIs, 2)
+	latency := report.Objectives[0].SLIs[1]
+	assert.Equal(t, SLILatency, latency.SLI)
+	assert.Equal(t, "250ms", latency.Threshold)
+	assert.InDelta(t, 100, latency.Total, 0)
+	// 阈值为桶的上界（含），恰好 250ms 的请求达标
+	assert.InDelta(t, 80, latency.Good, 0)
+	assert.InDelta(t, 0.8, latency.Ratio, 1e-9)
+	assert.InDelta(t, -1, latency.ErrorBudgetRemaining, 1e-9)
+	assert.InDelta(t, 2, latency.BurnRates["5m"], 1e-9)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	buckets := Options{Gatherer: reg, DurationBuckets: []float64{0.1, 0.25, 1}}
+	tests := []struct {
+		name      string
+		objective Objective
+		opts      Options
+		err       string
+	}{
+		{"no name", Objective{Availability: 0.99}, buckets, "name is required"},
+		{"empty", Objective{Name: "api"}, buckets, "neither availability nor latency"},
+		{"availability", Objective{Name: "api", Availability: 1}, buckets, "availability must be in (0, 1)"},
+		{"latency target", Objective{Name: "api", LatencyThreshold: time.Second}, buckets, "latency target"},
+		{"bucket", Objective{Name: "api", LatencyThreshold: 300 * time.Millisecond, LatencyTarget: 0.99}, buckets, "not a duration histogram bucket"},
+		{"window", Objective{Name: "api", Availability: 0.99}, Options{Gatherer: reg, Period: time.Hour, Windows: []time.Duration{2 * time.Hour}}, "burn rate window"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]Objective{tt.objective}, tt.opts)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	_, err := New([]Objective{{Name: "api", Availability: 0.9}, {Name: "api", Availability: 0.99}}, buckets)
+	assert.ErrorContains(t, err, "duplicate objective")
+}
+
+func TestRing(t *testing.T) {
+	r := newRing(3)
+	base := time.Unix(0, 0)
+	for i := range 5 {
+		r.push(sample{at: base.Add(time.Duration(i) * time.Minute)})
+	}
+	// 只保留最近 3 次采样
+	assert.Equal(t, base.Add(2*time.Minute), r.since(base).at)
+	assert.Equal(t, base.Add(3*time.Minute), r.since(base.Add(150*time.Second)).at)
+	assert.Equal(t, base.Add(4*time.Minute), r.since(base.Add(time.Hour)).at)
+}
+
+func TestFormatWindow(t *testing.T) {
+	assert.Equal(t, "5m", formatWindow(5*time.Minute))
+	assert.Equal(t, "6h", formatWindow(6*time.Hour))
+	assert.Equal(t, "3d", formatWindow(72*time.Hour))
+	assert.Equal(t, "1m30s", formatWindow(90*time.Second))
+}
@@ -5,42 +5,44 @@ import (
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
 
 	"github.com/exiaohu/go-demo/config"
 )
 
-var DB *gorm.DB
-
-// Initialize 初始化数据库连接
-func Initialize(cfg *config.Config) error {
-	var err error
+// New 根据配置创建数据库连接
+func New(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	// 使用 SQLite，如果需要其他数据库，可以在这里扩展
-	DB, err = gorm.Open(sqlite.Open(cfg.Database.Name), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(cfg.Name), &gorm.Config{Logger: NewLogger()})
 	if err != nil {
-		return fmt.Errorf("failed to connect database: %w", err)
+		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
-	sqlDB, err := DB.DB()
+	sqlDB, err := db.DB()
 	if err != nil {
-		return fmt.Errorf("failed to get sql db: %w", err)
+		return nil, fmt.Errorf("failed to get sql db: %w", err)
+	}
+	if err := db.Use(NewTracingPlugin(otel.GetTracerProvider())); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
 	}
 
 	// 设置连接池
-	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
-	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
-	sqlDB.SetConnMaxLifetime(time.Duration(cfg.Database.MaxLifeTime) * time.Second)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.MaxLifeTime) * time.Second)
 
-	return nil
+	return db, nil
 }
 
 // Close 关闭数据库连接
-func Close() error {
-	if DB == nil {
+func Close(db *gorm.DB) error {
+	if db == nil {
 		return nil
 	}
 
-	sqlDB, err := DB.DB()
+	sqlDB, err := db.DB()
 	if err != nil {
 		return err
 	}
@@ -49,9 +51,9 @@ func Close() error {
 }
 
 // AutoMigrate 自动迁移数据库结构
-func AutoMigrate(models ...interface{}) error {
-	if DB == nil {
+func AutoMigrate(db *gorm.DB, models ...interface{}) error {
+	if db == nil {
 		return fmt.Errorf("database not initialized")
 	}
-	return DB.AutoMigrate(models...)
+	return db.AutoMigrate(models...)
 }
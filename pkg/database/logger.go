@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/exiaohu/go-demo/pkg/logger"
+)
+
+// SlowThreshold 超过该耗时的 SQL 记录为慢查询
+const SlowThreshold = 200 * time.Millisecond
+
+// zapLogger 将 GORM 日志写入语句 context 中的请求级 logger，
+// 使 SQL 错误与慢查询日志带有请求 ID、trace ID 等关联字段
+type zapLogger struct {
+	level gormlogger.LogLevel
+}
+
+// NewLogger 创建输出 SQL 错误与慢查询的 GORM logger
+func NewLogger() gormlogger.Interface {
+	return &zapLogger{level: gormlogger.Warn}
+}
+
+func (l *zapLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &zapLogger{level: level}
+}
+
+func (l *zapLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logger.FromContext(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *zapLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logger.FromContext(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *zapLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logger.FromContext(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace 记录执行出错（查询不到记录除外）与超过 SlowThreshold 的 SQL，Info 级别时记录全部 SQL
+func (l *zapLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	fields := func() []zap.Field {
+		sql, rows := fc()
+		return []zap.Field{zap.String("sql", sql), zap.Int64("rows", rows), zap.Duration("duration", elapsed)}
+	}
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		logger.FromContext(ctx).Error("SQL failed", append(fields(), zap.Error(err))...)
+	case elapsed > SlowThreshold && l.level >= gormlogger.Warn:
+		logger.FromContext(ctx).Warn("Slow SQL", fields()...)
+	case l.level >= gormlogger.Info:
+		logger.FromContext(ctx).Debug("SQL", fields()...)
+	}
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+)
+
+type record struct {
+	ID   uint
+	Name string
+}
+
+func attrs(span sdktrace.ReadOnlySpan) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range span.Attributes() {
+		m[string(kv.Key)] = kv.Value.Emit()
+	}
+	return m
+}
+
+func TestTracingPlugin(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(NewTracingPlugin(tp)))
+	require.NoError(t, db.AutoMigrate(&record{}))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	require.NoError(t, db.WithContext(ctx).Create(&record{Name: "a"}).Error)
+	var got record
+	require.NoError(t, db.WithContext(ctx).First(&got).Error)
+	// 查询不到记录不标记为错误
+	assert.ErrorIs(t, db.WithContext(ctx).First(&got, 100).Error, gorm.ErrRecordNotFound)
+	assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+	parent.End()
+
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Parent().SpanID() == parent.SpanContext().SpanID() {
+			spans = append(spans, s)
+		}
+	}
+	require.Len(t, spans, 4)
+
+	assert.Equal(t, "gorm.create", spans[0].Name())
+	a := attrs(spans[0])
+	assert.Equal(t, "sqlite", a["db.system"])
+	assert.Equal(t, "create", a["db.operation"])
+	assert.Equal(t, "records", a["db.sql.table"])
+	assert.Equal(t, "1", a["db.rows_affected"])
+	assert.Contains(t, a["db.statement"], "INSERT INTO `records`")
+	assert.NotContains(t, a["db.statement"], `"a"`)
+
+	assert.Equal(t, "gorm.query", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[2].Status().Code)
+
+	assert.Equal(t, "gorm.raw", spans[3].Name())
+	assert.Equal(t, codes.Error, spans[3].Status().Code)
+}
@@ -22,6 +22,10 @@ const (
 	ErrTypeForbidden
 	// ErrTypeInternal 内部服务器错误
 	ErrTypeInternal
+	// ErrTypeRequestTooLarge 请求体过大
+	ErrTypeRequestTooLarge
+	// ErrTypeUnsupportedMediaType 不支持的请求体格式或编码
+	ErrTypeUnsupportedMediaType
 )
 
 // AppError 自定义应用程序错误
@@ -73,6 +77,10 @@ func errorCode(errType ErrorType) int {
 		return 403
 	case ErrTypeInternal:
 		return 500
+	case ErrTypeRequestTooLarge:
+		return 413
+	case ErrTypeUnsupportedMediaType:
+		return 415
 	case ErrTypeUnknown:
 		return 500
 	default:
@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+// NewContext 返回携带 l 的 context，之后通过 FromContext 取出
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext 返回 ctx 中的请求级 logger，同一请求的日志共享请求 ID、trace ID 等关联字段；
+// ctx 中没有 logger 时返回全局 logger，并附带 ctx 中 span 的 trace_id 与 span_id
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return zap.L().With(Context(ctx))
+}
+
+// With 为 ctx 中的 logger 追加字段，返回携带新 logger 的 context
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(fields...))
+}
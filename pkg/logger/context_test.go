@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(newTraceCore(core)))()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	// 没有请求级 logger 时使用全局 logger，并带上 trace 信息
+	FromContext(ctx).Info("global")
+	// With 追加的字段在之后取出的 logger 中保留
+	ctx = With(ctx, zap.String("request_id", "req-1"))
+	ctx = With(ctx, zap.String("principal", "apikey:1"))
+	FromContext(ctx).Info("scoped")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, sc.TraceID().String(), entries[0].ContextMap()["trace_id"])
+
+	fields := entries[1].ContextMap()
+	assert.Equal(t, sc.TraceID().String(), fields["trace_id"])
+	assert.Equal(t, "req-1", fields["request_id"])
+	assert.Equal(t, "apikey:1", fields["principal"])
+	assert.Len(t, entries[1].Context, 4)
+}
@@ -21,9 +21,9 @@ func Initialize(debug bool) error {
 	config.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	config.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
 
-	// 创建 logger
+	// 创建 logger，通过 Context 字段关联 trace
 	var err error
-	log, err = config.Build()
+	log, err = config.Build(zap.WrapCore(newTraceCore))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// contextFieldKey Context 字段的键，字段本身不会输出
+const contextFieldKey = "context"
+
+// contextValue 包装 context，避免与其他 SkipType 字段混淆
+type contextValue struct {
+	ctx context.Context
+}
+
+// Context 返回携带 ctx 的日志字段，输出时替换为 ctx 中 span 的 trace_id 与 span_id，
+// 使日志可以与 trace 和指标的 exemplar 关联；ctx 中没有有效的 span 时不输出任何字段
+func Context(ctx context.Context) zap.Field {
+	return zap.Field{Key: contextFieldKey, Type: zapcore.SkipType, Interface: contextValue{ctx: ctx}}
+}
+
+// traceCore 将 Context 字段展开为 trace_id 与 span_id
+type traceCore struct {
+	zapcore.Core
+}
+
+func newTraceCore(core zapcore.Core) zapcore.Core {
+	return &traceCore{Core: core}
+}
+
+func (c *traceCore) With(fields []zapcore.Field) zapcore.Core {
+	return &traceCore{Core: c.Core.With(traceFields(fields))}
+}
+
+func (c *traceCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *traceCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, traceFields(fields))
+}
+
+// traceFields 替换 Context 字段，没有 Context 字段时原样返回
+func traceFields(fields []zapcore.Field) []zapcore.Field {
+	if !slices.ContainsFunc(fields, isContextField) {
+		return fields
+	}
+
+	out := make([]zapcore.Field, 0, len(fields)+1)
+	for _, f := range fields {
+		if !isContextField(f) {
+			out = append(out, f)
+			continue
+		}
+		cv, _ := f.Interface.(contextValue)
+		if sc := trace.SpanContextFromContext(cv.ctx); sc.IsValid() {
+			out = append(out, zap.String("trace_id", sc.TraceID().String()), zap.String("span_id", sc.SpanID().String()))
+		}
+	}
+	return out
+}
+
+func isContextField(f zapcore.Field) bool {
+	_, ok := f.Interface.(contextValue)
+	return ok && f.Type == zapcore.SkipType
+}
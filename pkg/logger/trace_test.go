@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContext(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(newTraceCore(core))
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	// 有效的 span 展开为 trace_id 与 span_id
+	log.Info("with span", Context(ctx), zap.String("op", "add"))
+	// 没有 span 时不输出任何字段
+	log.Info("without span", Context(context.Background()))
+	// With 预先绑定的字段同样展开
+	log.With(Context(ctx)).Info("bound")
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, sc.TraceID().String(), fields["trace_id"])
+	assert.Equal(t, sc.SpanID().String(), fields["span_id"])
+	assert.Equal(t, "add", fields["op"])
+	assert.NotContains(t, fields, contextFieldKey)
+
+	assert.Empty(t, entries[1].ContextMap())
+
+	fields = entries[2].ContextMap()
+	assert.Equal(t, sc.TraceID().String(), fields["trace_id"])
+	assert.Equal(t, sc.SpanID().String(), fields["span_id"])
+}
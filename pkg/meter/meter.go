@@ -0,0 +1,38 @@
+// Package meter 创建 OpenTelemetry MeterProvider，通过 Prometheus 导出器桥接到 Prometheus Registerer，
+// 使 OTel 指标与 Prometheus 客户端直接注册的指标一起由 /metrics 输出
+package meter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// InitMeter 创建 MeterProvider 并注册为全局 MeterProvider
+// 返回的函数在服务退出时调用
+func InitMeter(reg prometheus.Registerer, res *resource.Resource) (func(context.Context) error, error) {
+	mp, err := NewProvider(reg, res)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetMeterProvider(mp)
+	return mp.Shutdown, nil
+}
+
+// NewProvider 创建指标注册在 reg 上的 MeterProvider
+// 在已采样的 span 中记录的测量值附带 trace_id 作为 exemplar，以 OpenMetrics 格式抓取时可以从指标跳转到 trace
+func NewProvider(reg prometheus.Registerer, res *resource.Resource) (*sdkmetric.MeterProvider, error) {
+	exporter, err := otelprom.New(otelprom.WithRegisterer(reg))
+	if err != nil {
+		return nil, fmt.Errorf("meter: create prometheus exporter: %w", err)
+	}
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exporter),
+		sdkmetric.WithResource(res),
+	), nil
+}
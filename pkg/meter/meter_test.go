@@ -0,0 +1,50 @@
+package meter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNewProvider(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	// 桥接后的 OTel 指标与直接注册的 Prometheus 指标共存
+	direct := prometheus.NewCounter(prometheus.CounterOpts{Name: "direct_total", Help: "direct"})
+	reg.MustRegister(direct)
+	direct.Inc()
+
+	res := resource.NewSchemaless(attribute.String("service.name", "playground"))
+	mp, err := NewProvider(reg, res)
+	require.NoError(t, err)
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+
+	counter, err := mp.Meter("test").Int64Counter("calculations")
+	require.NoError(t, err)
+
+	// 在已采样的 span 中记录，附带 exemplar
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	counter.Add(ctx, 3, metric.WithAttributes(attribute.String("operation", "add")))
+	span.End()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(w, req)
+
+	body := w.Body.String()
+	assert.Contains(t, body, "direct_total 1")
+	assert.Contains(t, body, `calculations_total{operation="add",otel_scope_name="test"`)
+	assert.Contains(t, body, `target_info{service_name="playground"} 1`)
+	assert.Contains(t, body, `# {trace_id="`+span.SpanContext().TraceID().String()+`"`)
+}
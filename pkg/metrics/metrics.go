@@ -0,0 +1,90 @@
+// Package metrics 提供 Prometheus 指标的注册辅助函数
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register 在 reg 上注册 c 并返回实际使用的指标
+// 已注册描述符完全一致的同类型指标时返回已注册的实例，使多次创建的组件共享同一组指标，
+// 便于同一进程中存在多个使用同一 Registerer 的应用实例；
+// 类型或描述符（名称、帮助文本、标签）不一致时返回错误
+func Register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+	var are prometheus.AlreadyRegisteredError
+	if !errors.As(err, &are) {
+		return c, err
+	}
+	existing, ok := are.ExistingCollector.(T)
+	if !ok {
+		return c, fmt.Errorf("metric already registered as %T: %w", are.ExistingCollector, err)
+	}
+	if want, got := describe(c), describe(existing); !slices.Equal(want, got) {
+		return c, fmt.Errorf("metric already registered with descriptors %v, want %v: %w", got, want, err)
+	}
+	return existing, nil
+}
+
+// MustRegister 同 Register，出错时 panic
+func MustRegister[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	c, err := Register(reg, c)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// histogramVec 记录创建时的桶边界，以便复用已注册的直方图前进行比较
+type histogramVec struct {
+	*prometheus.HistogramVec
+	buckets []float64
+}
+
+// NewHistogramVec 创建并注册 HistogramVec
+// 除 Register 的校验外，已注册的同名直方图桶边界不一致时同样返回错误
+func NewHistogramVec(reg prometheus.Registerer, opts prometheus.HistogramOpts, labels []string) (*prometheus.HistogramVec, error) {
+	buckets := opts.Buckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+	h, err := Register(reg, &histogramVec{HistogramVec: prometheus.NewHistogramVec(opts, labels), buckets: buckets})
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(h.buckets, buckets) {
+		return nil, fmt.Errorf("histogram %q already registered with buckets %v, want %v",
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), h.buckets, buckets)
+	}
+	return h.HistogramVec, nil
+}
+
+// MustNewHistogramVec 同 NewHistogramVec，出错时 panic
+func MustNewHistogramVec(reg prometheus.Registerer, opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	h, err := NewHistogramVec(reg, opts, labels)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// describe 返回指标全部描述符的字符串形式
+func describe(c prometheus.Collector) []string {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	var descs []string
+	for d := range ch {
+		descs = append(descs, d.String())
+	}
+	slices.Sort(descs)
+	return descs
+}
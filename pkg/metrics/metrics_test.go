@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := prometheus.CounterOpts{Name: "test_total", Help: "Test counter"}
+
+	first, err := Register(reg, prometheus.NewCounterVec(opts, []string{"op"}))
+	require.NoError(t, err)
+
+	t.Run("ReusesIdentical", func(t *testing.T) {
+		second, err := Register(reg, prometheus.NewCounterVec(opts, []string{"op"}))
+		require.NoError(t, err)
+		assert.Same(t, first, second)
+	})
+
+	t.Run("DifferentType", func(t *testing.T) {
+		_, err := Register(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_total", Help: "Test counter"}, []string{"op"}))
+		assert.Error(t, err)
+	})
+
+	t.Run("DifferentHelp", func(t *testing.T) {
+		_, err := Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "Other"}, []string{"op"}))
+		assert.Error(t, err)
+	})
+
+	t.Run("DifferentLabels", func(t *testing.T) {
+		_, err := Register(reg, prometheus.NewCounterVec(opts, []string{"route"}))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewHistogramVec(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := prometheus.HistogramOpts{Name: "test_seconds", Help: "Test histogram", Buckets: []float64{0.1, 1}}
+
+	first, err := NewHistogramVec(reg, opts, []string{"op"})
+	require.NoError(t, err)
+
+	second, err := NewHistogramVec(reg, opts, []string{"op"})
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	opts.Buckets = []float64{0.5, 5}
+	_, err = NewHistogramVec(reg, opts, []string{"op"})
+	assert.ErrorContains(t, err, "buckets")
+
+	// 注册后的直方图正常采集
+	first.WithLabelValues("add").Observe(0.2)
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, uint64(1), families[0].GetMetric()[0].GetHistogram().GetSampleCount())
+}
+
+func TestMustRegister_Panics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	MustRegister(reg, prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "Test counter"}))
+	assert.Panics(t, func() {
+		MustRegister(reg, prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_total", Help: "Test counter"}))
+	})
+}
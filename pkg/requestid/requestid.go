@@ -0,0 +1,18 @@
+package requestid
+
+import "context"
+
+type contextKey struct{}
+
+// NewContext 将 Request ID 注入到 Context
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext 从 Context 中获取 Request ID，不存在时返回空字符串
+func FromContext(ctx context.Context) string {
+	if val, ok := ctx.Value(contextKey{}).(string); ok {
+		return val
+	}
+	return ""
+}
@@ -2,9 +2,11 @@ package response
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 
-	"github.com/exiaohu/go-demo/internal/middleware"
+	"github.com/exiaohu/go-demo/pkg/errors"
+	"github.com/exiaohu/go-demo/pkg/requestid"
 )
 
 // Response 统一响应结构
@@ -24,7 +26,7 @@ func JSON(w http.ResponseWriter, r *http.Request, status int, data interface{})
 		Code:      status,
 		Message:   http.StatusText(status),
 		Data:      data,
-		RequestID: middleware.GetRequestID(r.Context()),
+		RequestID: requestid.FromContext(r.Context()),
 	}
 
 	json.NewEncoder(w).Encode(resp)
@@ -38,7 +40,7 @@ func Error(w http.ResponseWriter, r *http.Request, status int, message string) {
 	resp := Response{
 		Code:      status,
 		Message:   message,
-		RequestID: middleware.GetRequestID(r.Context()),
+		RequestID: requestid.FromContext(r.Context()),
 	}
 
 	json.NewEncoder(w).Encode(resp)
@@ -48,3 +50,14 @@ func Error(w http.ResponseWriter, r *http.Request, status int, message string) {
 func Success(w http.ResponseWriter, r *http.Request, data interface{}) {
 	JSON(w, r, http.StatusOK, data)
 }
+
+// FromError 根据错误类型发送错误响应
+// AppError 使用其对应的 HTTP 状态码，其他错误按 500 处理且不暴露错误细节
+func FromError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		Error(w, r, appErr.Code, appErr.Error())
+		return
+	}
+	Error(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
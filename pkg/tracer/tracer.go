@@ -2,52 +2,188 @@ package tracer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+
+	"github.com/exiaohu/go-demo/config"
 )
 
-// InitTracer initializes an OpenTelemetry tracer.
-// For this demo, we use stdout exporter. In production, use OTLP or Jaeger.
-func InitTracer(serviceName string) (func(context.Context) error, error) {
-	// Create stdout exporter to be able to retrieve
-	// the collected spans.
-	exporter, err := stdouttrace.New(
-		stdouttrace.WithPrettyPrint(),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
-	}
+// BuildInfo 服务的构建信息，写入 trace 的资源属性
+type BuildInfo struct {
+	ServiceName string
+	Version     string
+	// GitCommit、BuildTime 编译时通过 -ldflags 注入，GitCommit 缺失时从二进制内嵌的 VCS 信息读取
+	GitCommit string
+	BuildTime string
+}
 
-	// Identify your application resource.
-	res, err := resource.New(context.Background(),
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("1.0.0"),
-		),
-	)
+// InitTracer 根据配置创建 TracerProvider 并注册为全局 TracerProvider 与 W3C trace context 传播器
+// 返回的函数在服务退出时调用，导出尚未发送的 span 并关闭导出器
+func InitTracer(ctx context.Context, cfg config.TracingConfig, build BuildInfo) (func(context.Context) error, error) {
+	tp, err := NewProvider(ctx, cfg, build)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		return nil, err
 	}
-
-	// Register the tracer provider with the global provider.
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-	)
 	otel.SetTracerProvider(tp)
-
-	// Set global propagator to tracecontext (the default is no-op).
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
 		propagation.Baggage{},
 	))
-
-	// Return a shutdown function to be called on service exit.
 	return tp.Shutdown, nil
 }
+
+// NewProvider 根据配置创建 TracerProvider
+// 根 span 按 SampleRatio 采样，有上游 span 时沿用上游的采样决定；
+// 导出方式为 none 时仍然生成 trace 上下文，便于传播与日志关联，只是不导出 span
+func NewProvider(ctx context.Context, cfg config.TracingConfig, build BuildInfo) (*sdktrace.TracerProvider, error) {
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing: sample ratio must be in [0, 1], got %v", cfg.SampleRatio)
+	}
+	res, err := NewResource(ctx, cfg.ResourceAttributes, build)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// newExporter 创建 span 导出器，导出方式为 none 时返回 nil
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case config.TracingExporterNone, "":
+		return nil, nil
+	case config.TracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case config.TracingExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: open trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, file: f}, nil
+	case config.TracingExporterOTLPGRPC:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(cfg.Headers)}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlptracegrpc.WithTimeout(cfg.Timeout))
+		}
+		switch {
+		case strings.Contains(cfg.Endpoint, "://"):
+			opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		case cfg.Endpoint != "":
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case config.TracingExporterOTLPHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(cfg.Headers)}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlptracehttp.WithTimeout(cfg.Timeout))
+		}
+		switch {
+		case strings.Contains(cfg.Endpoint, "://"):
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		case cfg.Endpoint != "":
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("tracing: unsupported exporter %q", cfg.Exporter)
+	}
+}
+
+// fileExporter 关闭导出器时一并关闭文件
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file io.Closer
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
+
+// NewResource 创建描述服务的资源：服务名称与版本、构建信息、运行时与主机信息，
+// 以及 key=value 形式的附加属性和 OTEL_RESOURCE_ATTRIBUTES 环境变量中的属性（优先级依次升高）。
+// trace 与指标使用同一份资源，便于按服务与版本关联
+func NewResource(ctx context.Context, extra []string, build BuildInfo) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceName(build.ServiceName)}
+	if build.Version != "" {
+		attrs = append(attrs, semconv.ServiceVersion(build.Version))
+	}
+	if commit := gitCommit(build.GitCommit); commit != "" {
+		attrs = append(attrs, attribute.String("build.git_commit", commit))
+	}
+	if build.BuildTime != "" && build.BuildTime != "unknown" {
+		attrs = append(attrs, attribute.String("build.time", build.BuildTime))
+	}
+	for _, kv := range extra {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("tracing: invalid resource attribute %q, want key=value", kv)
+		}
+		attrs = append(attrs, attribute.String(strings.TrimSpace(key), strings.TrimSpace(value)))
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithAttributes(attrs...),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: create resource: %w", err)
+	}
+	return res, nil
+}
+
+// gitCommit 返回注入的提交号，未注入时读取 go build 内嵌的 vcs.revision
+func gitCommit(injected string) string {
+	if injected != "" && injected != "unknown" {
+		return injected
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,212 @@
+package tracer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/exiaohu/go-demo/config"
+)
+
+var testBuild = BuildInfo{ServiceName: "playground", Version: "1.2.3", GitCommit: "abc123", BuildTime: "2026-01-01T00:00:00Z"}
+
+// receiver 进程内的 OTLP 接收端，记录收到的 span 与请求头
+type receiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	mu       sync.Mutex
+	requests []*coltracepb.ExportTraceServiceRequest
+	headers  map[string]string
+}
+
+func (r *receiver) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := make(map[string]string, len(md))
+	for k, v := range md {
+		headers[k] = strings.Join(v, ",")
+	}
+	r.record(req, headers)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var export coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	headers := make(map[string]string, len(req.Header))
+	for k := range req.Header {
+		headers[strings.ToLower(k)] = req.Header.Get(k)
+	}
+	r.record(&export, headers)
+
+	resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+func (r *receiver) record(req *coltracepb.ExportTraceServiceRequest, headers map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.headers = headers
+}
+
+// spans 返回收到的 span 与最后一个 ResourceSpans 的资源属性
+func (r *receiver) spans() ([]*tracepb.Span, map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var spans []*tracepb.Span
+	attrs := make(map[string]string)
+	for _, req := range r.requests {
+		for _, rs := range req.GetResourceSpans() {
+			attrs = attributes(rs.GetResource().GetAttributes())
+			for _, ss := range rs.GetScopeSpans() {
+				spans = append(spans, ss.GetSpans()...)
+			}
+		}
+	}
+	return spans, attrs
+}
+
+func attributes(kvs []*commonpb.KeyValue) map[string]string {
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	return attrs
+}
+
+// emit 创建一个 span 并关闭 TracerProvider，确保 span 已导出
+func emit(t *testing.T, cfg config.TracingConfig) {
+	t.Helper()
+
+	tp, err := NewProvider(context.Background(), cfg, testBuild)
+	require.NoError(t, err)
+	_, span := tp.Tracer("test").Start(context.Background(), "calculate")
+	span.End()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, tp.Shutdown(ctx))
+}
+
+func TestNewProvider_OTLPGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	rcv := &receiver{}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, rcv)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	emit(t, config.TracingConfig{
+		Exporter:           config.TracingExporterOTLPGRPC,
+		Endpoint:           lis.Addr().String(),
+		Insecure:           true,
+		Headers:            map[string]string{"authorization": "Bearer secret"},
+		SampleRatio:        1,
+		ResourceAttributes: []string{"deployment.environment=test"},
+	})
+
+	spans, attrs := rcv.spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "calculate", spans[0].GetName())
+	assert.Equal(t, "Bearer secret", rcv.headers["authorization"])
+	assert.Equal(t, "playground", attrs["service.name"])
+	assert.Equal(t, "1.2.3", attrs["service.version"])
+	assert.Equal(t, "abc123", attrs["build.git_commit"])
+	assert.Equal(t, "2026-01-01T00:00:00Z", attrs["build.time"])
+	assert.Equal(t, "test", attrs["deployment.environment"])
+	assert.Equal(t, "go", attrs["process.runtime.name"])
+}
+
+func TestNewProvider_OTLPHTTP(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	emit(t, config.TracingConfig{
+		Exporter:    config.TracingExporterOTLPHTTP,
+		Endpoint:    srv.URL + "/v1/traces",
+		Headers:     map[string]string{"x-api-key": "secret"},
+		SampleRatio: 1,
+	})
+
+	spans, attrs := rcv.spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "calculate", spans[0].GetName())
+	assert.Equal(t, "secret", rcv.headers["x-api-key"])
+	assert.Equal(t, "playground", attrs["service.name"])
+}
+
+func TestNewProvider_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	emit(t, config.TracingConfig{Exporter: config.TracingExporterFile, File: file, SampleRatio: 1})
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var span struct {
+		Name string
+	}
+	require.NoError(t, json.Unmarshal(data, &span))
+	assert.Equal(t, "calculate", span.Name)
+}
+
+func TestNewProvider_Sampling(t *testing.T) {
+	tp, err := NewProvider(context.Background(), config.TracingConfig{Exporter: config.TracingExporterNone, SampleRatio: 0}, testBuild)
+	require.NoError(t, err)
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	tr := tp.Tracer("test")
+
+	// 根 span 按比例采样，未采样时仍然生成 trace 上下文
+	_, root := tr.Start(context.Background(), "root")
+	assert.False(t, root.SpanContext().IsSampled())
+	assert.True(t, root.SpanContext().IsValid())
+
+	// 上游已采样时沿用上游的决定
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	_, child := tr.Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "child")
+	assert.True(t, child.SpanContext().IsSampled())
+	assert.Equal(t, parent.TraceID(), child.SpanContext().TraceID())
+}
+
+func TestNewProvider_Invalid(t *testing.T) {
+	_, err := NewProvider(context.Background(), config.TracingConfig{Exporter: "jaeger", SampleRatio: 1}, testBuild)
+	assert.ErrorContains(t, err, "unsupported exporter")
+
+	_, err = NewProvider(context.Background(), config.TracingConfig{SampleRatio: 1.5}, testBuild)
+	assert.ErrorContains(t, err, "sample ratio")
+
+	_, err = NewProvider(context.Background(), config.TracingConfig{SampleRatio: 1, ResourceAttributes: []string{"team"}}, testBuild)
+	assert.ErrorContains(t, err, "want key=value")
+}
@@ -1,37 +1,248 @@
 package ip
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 )
 
+// 读取客户端 IP 的转发头
+const (
+	// HeaderXForwardedFor X-Forwarded-For，默认值，nginx、Kubernetes Ingress 与 ALB 等代理追加该头
+	HeaderXForwardedFor = "x-forwarded-for"
+	// HeaderForwarded RFC 7239 Forwarded
+	HeaderForwarded = "forwarded"
+	// HeaderXRealIP X-Real-IP，由代理覆盖为单个地址
+	HeaderXRealIP = "x-real-ip"
+)
+
+// Resolver 根据可信代理列表解析客户端真实 IP
+//
+// 只有直连对端（RemoteAddr）属于可信代理时才读取转发头，并且只读取配置的一种转发头：
+// 代理通常只维护其中一种，原样透传客户端发送的其他转发头，读取其他头会让客户端伪造 IP。
+// 转发链从右向左遍历，跳过可信代理，返回第一个不可信的地址：客户端可以在转发头最左侧伪造任意内容，
+// 但无法伪造可信代理追加在右侧的条目。
+type Resolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// NewResolver 创建解析器，trusted 为可信代理的 CIDR 或单个 IP，
+// header 为可信代理设置的转发头（HeaderXForwardedFor、HeaderForwarded 或 HeaderXRealIP），为空时使用 X-Forwarded-For
+func NewResolver(trusted []string, header string) (*Resolver, error) {
+	header = strings.ToLower(strings.TrimSpace(header))
+	switch header {
+	case "":
+		header = HeaderXForwardedFor
+	case HeaderXForwardedFor, HeaderForwarded, HeaderXRealIP:
+	default:
+		return nil, fmt.Errorf("unsupported client ip header %q", header)
+	}
+
+	res := &Resolver{trusted: make([]netip.Prefix, 0, len(trusted)), header: header}
+	for _, s := range trusted {
+		prefix, err := ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+		}
+		res.trusted = append(res.trusted, prefix)
+	}
+	return res, nil
+}
+
+// ParsePrefix 解析 CIDR 或单个 IP（视为 /32 或 /128），IPv4 映射的 IPv6 地址按 IPv4 处理
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = Normalize(addr)
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if addr := prefix.Addr(); addr.Is4In6() {
+		bits := prefix.Bits() - 96
+		if bits < 0 {
+			return netip.Prefix{}, fmt.Errorf("%q: prefix too short for an IPv4-mapped address", s)
+		}
+		prefix = netip.PrefixFrom(addr.Unmap(), bits)
+	}
+	return prefix.Masked(), nil
+}
+
+// Normalize 将 IPv4 映射的 IPv6 地址转换为 IPv4，并去掉 IPv6 zone
+func Normalize(addr netip.Addr) netip.Addr {
+	return addr.Unmap().WithZone("")
+}
+
+// ClientIP 返回请求的客户端 IP
+func (res *Resolver) ClientIP(r *http.Request) string {
+	remote, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		return remoteHost(r)
+	}
+	if !res.isTrusted(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	switch res.header {
+	case HeaderForwarded:
+		hops = forwardedFor(r.Header)
+	case HeaderXRealIP:
+		if realIP, ok := parseHop(r.Header.Get("X-Real-IP")); ok {
+			return realIP.String()
+		}
+		return remote.String()
+	default:
+		hops = forwardedList(r.Header.Values("X-Forwarded-For"))
+	}
+	if hops == nil {
+		return remote.String()
+	}
+
+	// 从右向左跳过可信代理；遇到无法解析的条目（如 "unknown"）时停止，使用其右侧最近的地址
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !res.isTrusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (res *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// NewContext 返回携带客户端 IP 的 context
+func NewContext(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, contextKey{}, clientIP)
+}
+
 // GetClientIP 获取客户端真实 IP
+// 优先使用中间件通过 Resolver 解析并保存在 context 中的结果；
+// 未经解析的请求不信任任何转发头，直接使用 RemoteAddr
 func GetClientIP(r *http.Request) string {
-	// 1. 尝试从 X-Forwarded-For 获取
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		// X-Forwarded-For 可能包含多个 IP，第一个是真实客户端 IP
-		ips := strings.Split(xForwardedFor, ",")
-		if len(ips) > 0 {
-			clientIP := strings.TrimSpace(ips[0])
-			if clientIP != "" {
-				return clientIP
+	if clientIP, ok := r.Context().Value(contextKey{}).(string); ok {
+		return clientIP
+	}
+	if addr, ok := parseHop(r.RemoteAddr); ok {
+		return addr.String()
+	}
+	return remoteHost(r)
+}
+
+// remoteHost 返回 RemoteAddr 的主机部分，无法解析时原样返回
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedList 拆分 X-Forwarded-For，多个同名头按出现顺序拼接
+func forwardedList(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwardedFor 提取 RFC 7239 Forwarded 头中每个元素的 for 参数，没有该头时返回 nil
+// 缺少 for 参数的元素记为空字符串，遍历时按无法解析处理
+func forwardedFor(h http.Header) []string {
+	values := h.Values("Forwarded")
+	if len(values) == 0 {
+		return nil
+	}
+
+	var hops []string
+	for _, v := range values {
+		for _, element := range splitQuoted(v, ',') {
+			hop := ""
+			for _, pair := range splitQuoted(element, ';') {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+					hop = strings.TrimSpace(value)
+				}
 			}
+			hops = append(hops, hop)
 		}
 	}
+	return hops
+}
 
-	// 2. 尝试从 X-Real-IP 获取
-	xRealIP := r.Header.Get("X-Real-IP")
-	if xRealIP != "" {
-		return xRealIP
+// splitQuoted 按 sep 拆分字符串，忽略引号内的分隔符
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
 	}
+	return append(parts, s[start:])
+}
 
-	// 3. 回退到 RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		// 如果 RemoteAddr 不包含端口（虽然不太可能），直接返回
-		return r.RemoteAddr
+// parseHop 解析转发链中的一个地址，支持可选的端口、IPv6 方括号与 Forwarded 的引号，
+// 返回规范化后的地址；"unknown" 与混淆标识（"_hidden"）等无法解析
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	if s == "" {
+		return netip.Addr{}, false
+	}
+
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return Normalize(addr), true
+	}
+	// 带端口的地址，IPv6 需要方括号，例如 "[2001:db8::1]:4711"
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			return Normalize(addr), true
+		}
+	}
+	// 不带端口的方括号 IPv6
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		if addr, err := netip.ParseAddr(s[1 : len(s)-1]); err == nil {
+			return Normalize(addr), true
+		}
 	}
-	return ip
+	return netip.Addr{}, false
 }
@@ -1,10 +1,12 @@
 package ip
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetClientIP(t *testing.T) {
@@ -15,22 +17,17 @@ func TestGetClientIP(t *testing.T) {
 		expected string
 	}{
 		{
-			name:     "X-Forwarded-For",
+			// 未经 Resolver 解析时不信任任何转发头
+			name:     "X-Forwarded-For ignored",
 			header:   map[string]string{"X-Forwarded-For": "10.0.0.1, 10.0.0.2"},
 			remote:   "127.0.0.1:12345",
-			expected: "10.0.0.1",
+			expected: "127.0.0.1",
 		},
 		{
-			name:     "X-Real-IP",
+			name:     "X-Real-IP ignored",
 			header:   map[string]string{"X-Real-IP": "10.0.0.3"},
 			remote:   "127.0.0.1:12345",
-			expected: "10.0.0.3",
-		},
-		{
-			name:     "Both Headers (X-Forwarded-For preferred)",
-			header:   map[string]string{"X-Forwarded-For": "10.0.0.1", "X-Real-IP": "10.0.0.3"},
-			remote:   "127.0.0.1:12345",
-			expected: "10.0.0.1",
+			expected: "127.0.0.1",
 		},
 		{
 			name:     "No Headers",
@@ -44,6 +41,16 @@ func TestGetClientIP(t *testing.T) {
 			remote:   "[::1]:12345",
 			expected: "::1",
 		},
+		{
+			name:     "IPv4-mapped IPv6",
+			remote:   "[::ffff:10.0.0.1]:12345",
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "No Port",
+			remote:   "pipe",
+			expected: "pipe",
+		},
 	}
 
 	for _, tt := range tests {
@@ -59,3 +66,262 @@ func TestGetClientIP(t *testing.T) {
 		})
 	}
 }
+
+func TestGetClientIP_FromContext(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(NewContext(req.Context(), "203.0.113.7"))
+	assert.Equal(t, "203.0.113.7", GetClientIP(req))
+}
+
+// TestResolver_ClientIP 伪造场景矩阵：可信代理为 10.0.0.0/8 与 2001:db8:ffff::/48
+func TestResolver_ClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		// source 读取的转发头，为空时使用 X-Forwarded-For
+		source   string
+		header   http.Header
+		expected string
+	}{
+		{
+			name:     "DirectClient",
+			remote:   "203.0.113.7:4711",
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "DirectClientSpoofsXFF",
+			remote:   "203.0.113.7:4711",
+			header:   http.Header{"X-Forwarded-For": {"1.2.3.4"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "DirectClientSpoofsForwarded",
+			remote:   "203.0.113.7:4711",
+			source:   HeaderForwarded,
+			header:   http.Header{"Forwarded": {"for=1.2.3.4"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "DirectClientSpoofsRealIP",
+			remote:   "203.0.113.7:4711",
+			source:   HeaderXRealIP,
+			header:   http.Header{"X-Real-IP": {"1.2.3.4"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "TrustedProxy",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"203.0.113.7"}},
+			expected: "203.0.113.7",
+		},
+		{
+			// 客户端伪造的最左侧条目被跳过，返回可信代理追加的地址
+			name:     "TrustedProxyClientPrependsSpoof",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"1.2.3.4, 203.0.113.7"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "ProxyChain",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7, 10.1.1.1"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "MultipleXFFHeaders",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1", "203.0.113.7, 10.1.1.1"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "AllHopsTrusted",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"10.3.3.3, 10.1.1.1"}},
+			expected: "10.3.3.3",
+		},
+		{
+			name:     "GarbageInChain",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"203.0.113.7, not-an-ip, 10.1.1.1"}},
+			expected: "10.1.1.1",
+		},
+		{
+			name:     "EmptyXFFEntry",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {""}},
+			expected: "10.0.0.2",
+		},
+		{
+			name:     "XFFWithPort",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"203.0.113.7:5555"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "RealIPFromTrustedProxy",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderXRealIP,
+			header:   http.Header{"X-Real-IP": {"203.0.113.7"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "InvalidRealIP",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderXRealIP,
+			header:   http.Header{"X-Real-IP": {"unknown"}},
+			expected: "10.0.0.2",
+		},
+		{
+			// 只读取配置的转发头，客户端透传的 X-Real-IP 不生效
+			name:     "RealIPIgnoredForXFF",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"X-Forwarded-For": {"203.0.113.7"}, "X-Real-IP": {"1.2.3.4"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "XFFIgnoredForRealIP",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderXRealIP,
+			header:   http.Header{"X-Forwarded-For": {"1.2.3.4"}, "X-Real-IP": {"203.0.113.7"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "Forwarded",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderForwarded,
+			header:   http.Header{"Forwarded": {"for=192.0.2.60;proto=http;by=203.0.113.43"}},
+			expected: "192.0.2.60",
+		},
+		{
+			name:   "ForwardedChainWithSpoof",
+			remote: "10.0.0.2:4711",
+			source: HeaderForwarded,
+			header: http.Header{"Forwarded": {
+				`for=1.2.3.4, for=192.0.2.60;proto=https, For="10.1.1.1:8080"`,
+			}},
+			expected: "192.0.2.60",
+		},
+		{
+			name:     "ForwardedQuotedIPv6",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderForwarded,
+			header:   http.Header{"Forwarded": {`for="[2001:DB8:cafe::17]:4711"`}},
+			expected: "2001:db8:cafe::17",
+		},
+		{
+			name:     "ForwardedQuotedSeparators",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderForwarded,
+			header:   http.Header{"Forwarded": {`for=192.0.2.60;ext="a,b;c"`}},
+			expected: "192.0.2.60",
+		},
+		{
+			name:     "ForwardedUnknown",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderForwarded,
+			header:   http.Header{"Forwarded": {"for=unknown, for=10.1.1.1"}},
+			expected: "10.1.1.1",
+		},
+		{
+			name:     "ForwardedObfuscated",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderForwarded,
+			header:   http.Header{"Forwarded": {"for=_hidden"}},
+			expected: "10.0.0.2",
+		},
+		{
+			// 可信代理只追加 X-Forwarded-For，原样透传客户端伪造的 Forwarded
+			name:     "TrustedProxyPassesForgedForwarded",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"Forwarded": {"for=1.2.3.4"}, "X-Forwarded-For": {"203.0.113.7"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "ForgedForwardedWithoutXFF",
+			remote:   "10.0.0.2:4711",
+			header:   http.Header{"Forwarded": {"for=1.2.3.4"}},
+			expected: "10.0.0.2",
+		},
+		{
+			name:     "XFFIgnoredForForwarded",
+			remote:   "10.0.0.2:4711",
+			source:   HeaderForwarded,
+			header:   http.Header{"Forwarded": {"for=192.0.2.60"}, "X-Forwarded-For": {"1.2.3.4"}},
+			expected: "192.0.2.60",
+		},
+		{
+			name:     "TrustedIPv6Proxy",
+			remote:   "[2001:db8:ffff::1]:4711",
+			header:   http.Header{"X-Forwarded-For": {"2001:DB8:0:0:0:0:0:7"}},
+			expected: "2001:db8::7",
+		},
+		{
+			name:     "IPv4MappedProxy",
+			remote:   "[::ffff:10.0.0.2]:4711",
+			header:   http.Header{"X-Forwarded-For": {"::ffff:203.0.113.7"}},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "IPv6Zone",
+			remote:   "[fe80::1%eth0]:4711",
+			expected: "fe80::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := NewResolver([]string{"10.0.0.0/8", "2001:db8:ffff::/48"}, tt.source)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for k, values := range tt.header {
+				for _, v := range values {
+					req.Header.Add(k, v)
+				}
+			}
+			assert.Equal(t, tt.expected, res.ClientIP(req))
+		})
+	}
+}
+
+func TestResolver_NoTrustedProxies(t *testing.T) {
+	res, err := NewResolver(nil, "")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:4711"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	req.Header.Set("Forwarded", "for=1.2.3.4")
+	assert.Equal(t, "10.0.0.2", res.ClientIP(req))
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.0/8":          "10.0.0.0/8",
+		"10.1.2.3/8":          "10.0.0.0/8",
+		"192.168.1.1":         "192.168.1.1/32",
+		"::1":                 "::1/128",
+		"2001:DB8::/32":       "2001:db8::/32",
+		"::ffff:10.0.0.0/104": "10.0.0.0/8",
+		" 172.16.0.0/12 ":     "172.16.0.0/12",
+	}
+	for in, want := range tests {
+		t.Run(in, func(t *testing.T) {
+			prefix, err := ParsePrefix(in)
+			require.NoError(t, err)
+			assert.Equal(t, want, prefix.String())
+		})
+	}
+
+	for _, in := range []string{"", "10.0.0.0/33", "example.com", "::ffff:10.0.0.0/64"} {
+		_, err := ParsePrefix(in)
+		assert.Error(t, err, in)
+	}
+
+	_, err := NewResolver([]string{"10.0.0.0/8", "bogus"}, "")
+	assert.Error(t, err)
+
+	_, err = NewResolver([]string{"10.0.0.0/8"}, "cf-connecting-ip")
+	assert.ErrorContains(t, err, "unsupported client ip header")
+}